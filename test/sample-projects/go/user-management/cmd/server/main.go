@@ -1,38 +1,102 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/example/user-management/internal/auth"
+	"github.com/example/user-management/internal/metrics"
 	"github.com/example/user-management/internal/models"
 	"github.com/example/user-management/internal/services"
 	"github.com/example/user-management/internal/utils"
 	"github.com/example/user-management/pkg/api"
 	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 func main() {
+	migrate := flag.Bool("migrate", false, "apply database migrations and exit")
+	flag.Parse()
+
+	config := loadConfig()
+	logger := utils.NewLogger(os.Stdout, config.LogFormat, config.LogLevel)
+	services.SetLogger(logger)
+	api.SetLogger(logger)
+	if err := applyPasswordHashing(config.Users); err != nil {
+		log.Fatal("Failed to configure password hashing:", err)
+	}
+	models.MinAge = config.Users.MinAge
+	if err := models.SetUsernamePolicy(config.Users.UsernamePattern, config.Users.ReservedUsernames); err != nil {
+		log.Fatal("Failed to configure username policy:", err)
+	}
+	applyPagination(config.Server)
+	if err := applyEncryption(config.Encryption); err != nil {
+		log.Fatal("Failed to configure encryption:", err)
+	}
+
 	// Initialize database
-	db, err := initDatabase()
+	db, err := initDatabase(config.Database)
 	if err != nil {
 		log.Fatal("Failed to initialize database:", err)
 	}
 
+	if *migrate {
+		if err := migrateDatabase(db, config.Users.UniqueMetadataKeys); err != nil {
+			log.Fatal("Failed to migrate database:", err)
+		}
+		log.Println("Migrations applied")
+		return
+	}
+	if err := prepareSchema(db, config); err != nil {
+		log.Fatal("Failed to initialize database:", err)
+	}
+
 	// Initialize services
-	userService := services.NewUserService(db)
+	userService := services.NewUserService(db, config.Users)
+	tokenService := services.NewTokenService(db, config.Sessions)
+
+	// Side effects of user events
+	bus := services.NewEventBus(config.Events.Async)
+	subscribeMetrics(bus)
+	services.SubscribeAuditLog(bus, db)
+	services.SubscribeLoginHistory(bus, db)
+	if len(config.Webhooks.URLs) > 0 {
+		services.NewWebhookService(config.Webhooks).Subscribe(bus)
+	}
+	userService.SetEventBus(bus)
+	if err := applyMetadataSchema(userService, config.Users.MetadataSchemaFile); err != nil {
+		log.Fatal("Failed to load metadata schema:", err)
+	}
+
+	// Initialize authentication
+	jwtManager := auth.NewJWTManager(config.JWT)
 
 	// Initialize API handlers
-	userHandler := api.NewUserHandler(userService)
+	userHandler := api.NewUserHandler(userService, tokenService, jwtManager)
 
 	// Setup routes
-	router := setupRoutes(userHandler)
+	router := setupRoutes(userHandler, userService, jwtManager, tokenService, config.Server, accessLogMiddleware(config.LogFormat, logger))
 
-	// Create sample data
-	createSampleData(userService)
+	// Create sample data, or leave the first admin to be bootstrapped
+	if config.Users.BootstrapFirstAdmin {
+		bootstrapAdmin(userService)
+	} else {
+		createSampleData(userService)
+	}
+
+	// Purge soft-deleted users past retention
+	startPurgeJob(userService, config.Users)
+
+	// Anonymize users inactive past retention
+	startAnonymizeJob(userService, config.Users)
 
 	// Start server
 	log.Println("Starting server on :8080")
@@ -41,56 +105,490 @@ func main() {
 	}
 }
 
-func initDatabase() (*gorm.DB, error) {
-	db, err := gorm.Open(sqlite.Open("users.db"), &gorm.Config{})
+func loadConfig() *utils.Config {
+	config := &utils.Config{
+		Database: utils.DatabaseConfig{
+			// Migrating on startup is convenient in development; production
+			// sets AUTO_MIGRATE=false and runs the server with --migrate as
+			// a separate deploy step
+			AutoMigrate: os.Getenv("AUTO_MIGRATE") != "false",
+		},
+		Server: utils.ServerConfig{
+			MaxBodyBytes:         1 << 20,
+			MaxConcurrentExports: 2,
+			ReadOnly:             os.Getenv("READ_ONLY") == "true",
+			ReadOnlyBlocksLogin:  os.Getenv("READ_ONLY_BLOCKS_LOGIN") == "true",
+			BodyLimits: map[string]int64{
+				"auth": 16 << 10,
+			},
+		},
+		JWT: utils.JWTConfig{
+			SecretKey:        os.Getenv("JWT_SECRET_KEY"),
+			ExpirationHours:  24,
+			RefreshHours:     168,
+			Issuer:           "user-management",
+			SigningAlgorithm: "HS256",
+
+			ImpersonationMinutes: 15,
+		},
+		Users: utils.UserServiceConfig{
+			AuditOnHardDelete:   os.Getenv("AUDIT_ON_HARD_DELETE"),
+			HardDeleteByDefault: os.Getenv("HARD_DELETE_BY_DEFAULT") == "true",
+			BootstrapFirstAdmin: os.Getenv("BOOTSTRAP_FIRST_ADMIN") == "true",
+			QueryTimeout:        10,
+			IdempotencyWindow:   24,
+			BcryptCost:          bcrypt.DefaultCost,
+			PasswordAlgorithm:   models.AlgorithmBcrypt,
+
+			UnifiedLoginIdentifiers: os.Getenv("UNIFIED_LOGIN_IDENTIFIERS") == "true",
+
+			PasswordChangeLimit:  5,
+			PasswordChangeWindow: 60,
+
+			DeletedRetention: 30,
+			PurgeBatchSize:   100,
+			AnonymizeAfter:   365,
+
+			MinSearchLength:   2,
+			ShortSearchPolicy: utils.ShortSearchReject,
+			SearchSortBy:      "username",
+			SearchSortDir:     "asc",
+
+			MetadataMaxBytes: 16 << 10,
+			MetadataMaxDepth: 5,
+
+			StatsCacheTTL: 10,
+		},
+		Sessions: utils.SessionConfig{
+			IdleTimeout:     24,
+			AbsoluteTimeout: 72,
+			LimitPolicy:     utils.SessionLimitEvict,
+		},
+		LogLevel:  "info",
+		LogFormat: utils.LogFormatText,
+	}
+
+	if format := os.Getenv("LOG_FORMAT"); format != "" {
+		config.LogFormat = format
+	}
+	if level := os.Getenv("LOG_LEVEL"); level != "" {
+		config.LogLevel = level
+	}
+
+	config.Users.MetadataSchemaFile = os.Getenv("METADATA_SCHEMA_FILE")
+	config.Users.UsernamePattern = os.Getenv("USERNAME_PATTERN")
+	if algorithm := os.Getenv("PASSWORD_ALGORITHM"); algorithm != "" {
+		config.Users.PasswordAlgorithm = algorithm
+	}
+	if names := os.Getenv("RESERVED_USERNAMES"); names != "" {
+		config.Users.ReservedUsernames = strings.Split(names, ",")
+	}
+	if permissions := os.Getenv("PERMISSION_CATALOG"); permissions != "" {
+		config.Users.PermissionCatalog = strings.Split(permissions, ",")
+	}
+
+	if keys := os.Getenv("UNIQUE_METADATA_KEYS"); keys != "" {
+		config.Users.UniqueMetadataKeys = strings.Split(keys, ",")
+	}
+
+	// METADATA_ENCRYPTION_KEYS is a list of id:base64key pairs; the first
+	// key is the active one
+	if keys := os.Getenv("METADATA_ENCRYPTION_KEYS"); keys != "" {
+		config.Encryption.Keys = make(map[string]string)
+		for i, pair := range strings.Split(keys, ",") {
+			id, key, _ := strings.Cut(pair, ":")
+			config.Encryption.Keys[id] = key
+			if i == 0 {
+				config.Encryption.ActiveKey = id
+			}
+		}
+		config.Encryption.MetadataKeys = strings.Split(os.Getenv("ENCRYPTED_METADATA_KEYS"), ",")
+	}
+
+	if urls := os.Getenv("WEBHOOK_URLS"); urls != "" {
+		config.Webhooks.URLs = strings.Split(urls, ",")
+		config.Webhooks.Secret = os.Getenv("WEBHOOK_SECRET")
+	}
+
+	if config.JWT.SecretKey == "" {
+		log.Println("JWT_SECRET_KEY not set, using insecure development key")
+		config.JWT.SecretKey = "dev-secret-change-me"
+	}
+
+	return config
+}
+
+// subscribeMetrics counts login outcomes published on bus
+func subscribeMetrics(bus *services.EventBus) {
+	services.Subscribe(bus, func(context.Context, services.LoginSucceeded) error {
+		metrics.LoginSuccesses.Inc()
+		return nil
+	})
+	services.Subscribe(bus, func(_ context.Context, e services.LoginFailed) error {
+		metrics.LoginFailures.Inc()
+		if e.Locked {
+			metrics.Lockouts.Inc()
+		}
+		return nil
+	})
+}
+
+// applyEncryption enables encryption of the configured metadata keys. It
+// does nothing if no keys are configured.
+func applyEncryption(config utils.EncryptionConfig) error {
+	if len(config.Keys) == 0 {
+		return nil
+	}
+
+	cipher, err := utils.NewFieldCipher(config.Keys, config.ActiveKey)
+	if err != nil {
+		return err
+	}
+
+	models.MetadataCipher = cipher
+	models.EncryptedMetadataKeys = config.MetadataKeys
+	return nil
+}
+
+// applyPagination sets the list page size default and cap
+func applyPagination(config utils.ServerConfig) {
+	if config.DefaultPageSize > 0 {
+		utils.DefaultPageSize = config.DefaultPageSize
+	}
+	if config.MaxPageSize > 0 {
+		utils.MaxPageSize = config.MaxPageSize
+	}
+}
+
+// applyPasswordHashing sets the algorithm and bcrypt cost for new hashes.
+// Existing hashes are upgraded on the user's next login.
+func applyPasswordHashing(config utils.UserServiceConfig) error {
+	if config.PasswordAlgorithm != "" {
+		hasher, err := models.HasherFor(config.PasswordAlgorithm)
+		if err != nil {
+			return err
+		}
+		models.PasswordHasher = hasher
+	}
+	if config.BcryptCost >= bcrypt.MinCost && config.BcryptCost <= bcrypt.MaxCost {
+		models.PasswordCost = config.BcryptCost
+	}
+	models.PrepareDummyHash()
+	return nil
+}
+
+// applyMetadataSchema compiles the metadata JSON Schema at path once and
+// installs it on the user service. An empty path leaves metadata free-form.
+func applyMetadataSchema(userService *services.UserService, path string) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	schema, err := utils.CompileJSONSchema(data)
+	if err != nil {
+		return err
+	}
+
+	userService.SetMetadataSchema(schema)
+	return nil
+}
+
+// startPurgeJob periodically hard-deletes users that have been soft-deleted
+// for longer than the configured retention. It does nothing if no purge
+// interval is configured.
+func startPurgeJob(userService *services.UserService, config utils.UserServiceConfig) {
+	if config.PurgeInterval <= 0 {
+		return
+	}
+
+	interval := time.Duration(config.PurgeInterval) * time.Minute
+	retention := time.Duration(config.DeletedRetention) * 24 * time.Hour
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			purged, err := userService.PurgeDeletedBefore(context.Background(), time.Now().Add(-retention))
+			if err != nil {
+				log.Printf("Purge of deleted users failed after removing %d: %v", purged, err)
+				continue
+			}
+			log.Printf("Purged %d deleted users", purged)
+		}
+	}()
+}
+
+// startAnonymizeJob periodically anonymizes users that have been inactive
+// for longer than the configured period. It does nothing if no
+// anonymization interval is configured.
+func startAnonymizeJob(userService *services.UserService, config utils.UserServiceConfig) {
+	if config.AnonymizeInterval <= 0 {
+		return
+	}
+
+	interval := time.Duration(config.AnonymizeInterval) * time.Minute
+	period := time.Duration(config.AnonymizeAfter) * 24 * time.Hour
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			anonymized, err := userService.AnonymizeInactiveBefore(context.Background(), time.Now().Add(-period))
+			if err != nil {
+				log.Printf("Anonymization of inactive users failed after %d: %v", anonymized, err)
+				continue
+			}
+			log.Printf("Anonymized %d inactive users", anonymized)
+		}
+	}()
+}
+
+// Connection pool defaults used when the database config leaves them unset
+const (
+	// defaultMaxOpenConns stays well below common server limits (Postgres
+	// allows 100 by default) so several instances can share a database
+	defaultMaxOpenConns = 25
+	// defaultMaxIdleConns keeps a few warm connections without holding
+	// many idle ones between bursts
+	defaultMaxIdleConns = 5
+	// defaultConnMaxLifetime recycles connections so failovers and load
+	// balancer changes are picked up
+	defaultConnMaxLifetime = 30 * time.Minute
+)
+
+// Connection retry defaults used when the database config leaves them unset
+const (
+	// defaultConnectTimeout gives an orchestrator time to bring the
+	// database up alongside the server
+	defaultConnectTimeout = 30 * time.Second
+	// defaultConnectBackoff is the wait before the first retry; it doubles
+	// after each failed attempt up to maxConnectBackoff
+	defaultConnectBackoff = 500 * time.Millisecond
+	maxConnectBackoff     = 10 * time.Second
+)
+
+func initDatabase(config utils.DatabaseConfig) (*gorm.DB, error) {
+	db, err := connectDatabase(config)
 	if err != nil {
 		return nil, err
 	}
 
-	// Auto migrate
-	if err := db.AutoMigrate(&models.User{}); err != nil {
+	if err := configurePool(db, config); err != nil {
 		return nil, err
 	}
 
 	return db, nil
 }
 
-func setupRoutes(userHandler *api.UserHandler) *gin.Engine {
-	router := gin.Default()
+// migrateDatabase applies the schema migrations and the unique indexes for
+// the configured metadata keys
+func migrateDatabase(db *gorm.DB, uniqueMetadataKeys []string) error {
+	if err := services.RunMigrations(db); err != nil {
+		return err
+	}
+	return services.EnsureMetadataIndexes(db, uniqueMetadataKeys)
+}
+
+// prepareSchema migrates the database when auto-migrate is on. Otherwise
+// it leaves the schema alone and only checks that migrations have run.
+func prepareSchema(db *gorm.DB, config *utils.Config) error {
+	if config.Database.AutoMigrate {
+		return migrateDatabase(db, config.Users.UniqueMetadataKeys)
+	}
+	return services.CheckSchema(db)
+}
+
+// connectDatabase opens the database, retrying with exponential backoff
+// while it is unavailable so the server can start before the database is
+// up. It gives up once the next retry would pass the connect timeout.
+func connectDatabase(config utils.DatabaseConfig) (*gorm.DB, error) {
+	timeout := time.Duration(config.ConnectTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultConnectTimeout
+	}
+	backoff := time.Duration(config.ConnectBackoff) * time.Millisecond
+	if backoff <= 0 {
+		backoff = defaultConnectBackoff
+	}
+
+	deadline := time.Now().Add(timeout)
+	for attempt := 1; ; attempt++ {
+		// gorm pings the database on open, so an unreachable database
+		// fails here rather than on the first query
+		db, err := gorm.Open(sqlite.Open("users.db"), &gorm.Config{})
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("Connected to database on attempt %d", attempt)
+			}
+			return db, nil
+		}
+		if db != nil {
+			if sqlDB, dbErr := db.DB(); dbErr == nil {
+				sqlDB.Close()
+			}
+		}
+
+		if time.Now().Add(backoff).After(deadline) {
+			log.Printf("Database connection attempt %d failed: %v; giving up", attempt, err)
+			return nil, fmt.Errorf("database unavailable after %d attempts within %s: %w", attempt, timeout, err)
+		}
+
+		log.Printf("Database connection attempt %d failed: %v; retrying in %s", attempt, err, backoff)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+}
+
+// configurePool applies the connection pool settings to the underlying sql.DB
+func configurePool(db *gorm.DB, config utils.DatabaseConfig) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database handle: %w", err)
+	}
+
+	maxOpen := config.MaxOpenConns
+	if maxOpen <= 0 {
+		maxOpen = defaultMaxOpenConns
+	}
+	maxIdle := config.MaxIdleConns
+	if maxIdle <= 0 {
+		maxIdle = defaultMaxIdleConns
+	}
+	lifetime := time.Duration(config.ConnMaxLifetime) * time.Minute
+	if lifetime <= 0 {
+		lifetime = defaultConnMaxLifetime
+	}
+
+	sqlDB.SetMaxOpenConns(maxOpen)
+	sqlDB.SetMaxIdleConns(maxIdle)
+	sqlDB.SetConnMaxLifetime(lifetime)
+
+	return nil
+}
+
+// exportQueueWait is how long an export waits for a free slot before it is
+// rejected with 429
+const exportQueueWait = 2 * time.Second
+
+// bodyLimit returns the request body limit for a route group, falling back
+// to the server-wide limit
+func bodyLimit(config utils.ServerConfig, group string) int64 {
+	if limit, ok := config.BodyLimits[group]; ok {
+		return limit
+	}
+	return config.MaxBodyBytes
+}
+
+func setupRoutes(userHandler *api.UserHandler, userService *services.UserService, jwtManager *auth.JWTManager, tokenService *services.TokenService, serverConfig utils.ServerConfig, accessLog gin.HandlerFunc) *gin.Engine {
+	router := gin.New()
 
 	// Middleware
+	router.Use(gin.Recovery())
+	router.Use(api.RequestIDMiddleware())
 	router.Use(corsMiddleware())
-	router.Use(loggingMiddleware())
+	router.Use(accessLog)
+	router.Use(metrics.Middleware())
+	router.Use(api.ActivityMiddleware(userService))
+	router.Use(api.ImpersonationAuditMiddleware(userService))
+
+	// Read-only maintenance mode. POSTs that only read stay allowed, as does
+	// the endpoint that turns the mode off.
+	readOnly := api.NewReadOnlyMode(userService, serverConfig.ReadOnly, serverConfig.ReadOnlyBlocksLogin,
+		[]string{"/api/v1/admin/read-only", "/api/v1/users/batch-get", "/api/v1/auth/password-strength"},
+		[]string{"/api/v1/auth/login", "/api/v1/auth/refresh", "/api/v1/auth/logout"},
+	)
+	router.Use(readOnly.Middleware())
 
 	// Health check
 	router.GET("/health", healthCheck)
 
+	// Prometheus metrics
+	router.GET("/metrics", metrics.Handler())
+
 	// API routes
 	v1 := router.Group("/api/v1")
 	{
 		users := v1.Group("/users")
+		users.Use(api.BodyLimitMiddleware(bodyLimit(serverConfig, "users")))
 		{
 			users.POST("", userHandler.CreateUser)
+			users.POST("/batch-get", userHandler.BatchGetUsers)
 			users.GET("", userHandler.GetUsers)
+			users.HEAD("", userHandler.GetUsers)
 			users.GET("/:id", userHandler.GetUser)
-			users.PUT("/:id", userHandler.UpdateUser)
-			users.DELETE("/:id", userHandler.DeleteUser)
+			users.PUT("/:id", api.AuthMiddleware(jwtManager, tokenService), userHandler.UpdateUser)
+			users.PATCH("/:id/metadata", api.AuthMiddleware(jwtManager, tokenService), userHandler.UpdateMetadata)
+			users.GET("/:id/preferences", api.AuthMiddleware(jwtManager, tokenService), userHandler.GetPreferences)
+			users.PATCH("/:id/preferences", api.AuthMiddleware(jwtManager, tokenService), userHandler.UpdatePreferences)
+			users.GET("/:id/data-export", api.AuthMiddleware(jwtManager, tokenService), userHandler.ExportUserData)
+			users.GET("/:id/permissions", api.AuthMiddleware(jwtManager, tokenService), userHandler.ListPermissions)
+			users.GET("/:id/can", api.AuthMiddleware(jwtManager, tokenService), userHandler.CheckPermission)
+			users.GET("/:id/login-history", api.AuthMiddleware(jwtManager, tokenService), userHandler.LoginHistory)
+			users.GET("/:id/emails", api.AuthMiddleware(jwtManager, tokenService), userHandler.ListEmails)
+			users.POST("/:id/emails", api.AuthMiddleware(jwtManager, tokenService), userHandler.AddEmail)
+			users.PUT("/:id/emails/primary", api.AuthMiddleware(jwtManager, tokenService), userHandler.SetPrimaryEmail)
+			users.DELETE("/:id/emails/:email", api.AuthMiddleware(jwtManager, tokenService), userHandler.RemoveEmail)
+			users.DELETE("/:id", api.AuthMiddleware(jwtManager, tokenService), userHandler.DeleteUser)
 			users.GET("/search", userHandler.SearchUsers)
+			users.HEAD("/search", userHandler.SearchUsers)
 			users.GET("/stats", userHandler.GetUserStats)
-			users.GET("/export", userHandler.ExportUsers)
+			users.GET("/stats/age-distribution", userHandler.AgeDistribution)
+			users.GET("/stats/signups", userHandler.SignupsOverTime)
+			users.GET("/export", api.ConcurrencyLimitMiddleware(serverConfig.MaxConcurrentExports, exportQueueWait), userHandler.ExportUsers)
+			users.GET("/availability", userHandler.CheckAvailability)
 		}
 
-		auth := v1.Group("/auth")
+		authGroup := v1.Group("/auth")
+		authGroup.Use(api.BodyLimitMiddleware(bodyLimit(serverConfig, "auth")))
 		{
-			auth.POST("/login", userHandler.Login)
-			auth.POST("/logout", userHandler.Logout)
-			auth.POST("/change-password", userHandler.ChangePassword)
+			authGroup.POST("/login", userHandler.Login)
+			authGroup.POST("/logout", userHandler.Logout)
+			authGroup.POST("/refresh", userHandler.RefreshToken)
+			authGroup.POST("/change-password", api.AuthMiddleware(jwtManager, tokenService), userHandler.ChangePassword)
+			authGroup.POST("/2fa/setup", api.AuthMiddleware(jwtManager, tokenService), userHandler.SetupTwoFactor)
+			authGroup.POST("/2fa/enable", api.AuthMiddleware(jwtManager, tokenService), userHandler.EnableTwoFactor)
+			authGroup.POST("/2fa/disable", api.AuthMiddleware(jwtManager, tokenService), userHandler.DisableTwoFactor)
+			authGroup.POST("/backup-codes", api.AuthMiddleware(jwtManager, tokenService), userHandler.RegenerateBackupCodes)
+			authGroup.GET("/me", api.AuthMiddleware(jwtManager, tokenService), userHandler.Me)
+			authGroup.POST("/password-strength", userHandler.PasswordStrength)
 		}
 
 		admin := v1.Group("/admin")
+		admin.Use(api.BodyLimitMiddleware(bodyLimit(serverConfig, "admin")), api.AuthMiddleware(jwtManager, tokenService), api.AdminMiddleware())
 		{
+			admin.GET("/read-only", readOnly.Status)
+			admin.PUT("/read-only", readOnly.Update)
+			admin.POST("/users", userHandler.CreateUserAsAdmin)
+			admin.POST("/users/import", userHandler.ImportUsers)
+			admin.POST("/users/bulk-status", userHandler.BulkUpdateStatus)
+			admin.POST("/users/bulk-permissions", userHandler.GrantPermissionToUsers)
+			admin.GET("/permissions", userHandler.ListPermissionCatalog)
+			admin.GET("/users/by-metadata", userHandler.SearchByMetadata)
+			admin.GET("/users/by-email", userHandler.GetUserByEmail)
+			admin.GET("/users/by-role/:role", userHandler.GetUsersByRole)
+			admin.GET("/users/deleted-summary", userHandler.DeletedSummary)
+			admin.GET("/users/:id", userHandler.GetUserAsAdmin)
+			admin.DELETE("/users/:id", userHandler.DeleteUser)
 			admin.POST("/users/:id/reset-password", userHandler.ResetPassword)
+			admin.POST("/users/:id/logout-all", userHandler.LogoutAll)
+			admin.POST("/users/:id/activate", userHandler.ActivateUser)
+			admin.POST("/users/:id/deactivate", userHandler.DeactivateUser)
+			admin.POST("/users/:id/suspend", userHandler.SuspendUser)
+			admin.POST("/users/:id/unlock", userHandler.UnlockUser)
+			admin.POST("/users/:id/shadow", userHandler.ShadowUser)
+			admin.POST("/users/:id/unshadow", userHandler.UnshadowUser)
+			admin.POST("/users/:id/restore", userHandler.RestoreUser)
+			admin.POST("/users/:id/impersonate", userHandler.Impersonate)
+			admin.PATCH("/users/:id/status", userHandler.UpdateStatus)
 			admin.POST("/users/:id/permissions", userHandler.AddPermission)
+			admin.PUT("/users/:id/permissions", userHandler.SetPermissions)
 			admin.DELETE("/users/:id/permissions", userHandler.RemovePermission)
 		}
 	}
@@ -106,11 +604,26 @@ func healthCheck(c *gin.Context) {
 	})
 }
 
+// corsAllowedHeaders are the request headers browsers may send: besides
+// the body type and credentials, the idempotency key, the envelope switch
+// and the conditional request validators
+var corsAllowedHeaders = strings.Join([]string{
+	"Content-Type", "Authorization", "Idempotency-Key", api.ResponseEnvelopeHeader,
+	"If-None-Match", "If-Modified-Since", api.RequestIDHeader,
+}, ", ")
+
+// corsExposedHeaders are the response headers browser scripts may read
+var corsExposedHeaders = strings.Join([]string{
+	"X-Total-Count", "X-Page", "X-Page-Size", "X-Total-Pages",
+	"ETag", "Last-Modified", api.RequestIDHeader, "Retry-After",
+}, ", ")
+
 func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", corsAllowedHeaders)
+		c.Header("Access-Control-Expose-Headers", corsExposedHeaders)
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusOK)
@@ -121,6 +634,16 @@ func corsMiddleware() gin.HandlerFunc {
 	}
 }
 
+// accessLogMiddleware picks the access log for the configured format:
+// structured JSON entries through logger, or the plain-text line for local
+// development
+func accessLogMiddleware(format string, logger utils.Logger) gin.HandlerFunc {
+	if format == utils.LogFormatJSON {
+		return api.AccessLogMiddleware(logger)
+	}
+	return loggingMiddleware()
+}
+
 func loggingMiddleware() gin.HandlerFunc {
 	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
 		return fmt.Sprintf("%s - [%s] \"%s %s %s %d %s \"%s\" %s\"\n",
@@ -137,9 +660,36 @@ func loggingMiddleware() gin.HandlerFunc {
 	})
 }
 
+// bootstrapAdmin creates the admin given by the BOOTSTRAP_ADMIN_* variables
+// if the database is still empty. Without them the first user to sign up
+// becomes admin.
+func bootstrapAdmin(userService *services.UserService) {
+	username := os.Getenv("BOOTSTRAP_ADMIN_USERNAME")
+	password := os.Getenv("BOOTSTRAP_ADMIN_PASSWORD")
+	if username == "" || password == "" {
+		return
+	}
+
+	user, err := userService.BootstrapAdmin(context.Background(), &models.UserRequest{
+		Username: username,
+		Email:    os.Getenv("BOOTSTRAP_ADMIN_EMAIL"),
+		Name:     "Administrator",
+		Password: password,
+	})
+	if err != nil {
+		log.Printf("Failed to bootstrap admin user: %v", err)
+		return
+	}
+	if user != nil {
+		log.Printf("Bootstrapped admin user %s", user.Username)
+	}
+}
+
 func createSampleData(userService *services.UserService) {
+	ctx := context.Background()
+
 	// Check if admin user already exists
-	if _, err := userService.GetUserByUsername("admin"); err == nil {
+	if _, err := userService.GetUserByUsername(ctx, "admin"); err == nil {
 		return // Admin user already exists
 	}
 
@@ -153,27 +703,12 @@ func createSampleData(userService *services.UserService) {
 		Role:     models.RoleAdmin,
 	}
 
-	admin, err := userService.CreateUser(adminReq)
-	if err != nil {
+	// Admin permissions come from the role defaults
+	if _, err := userService.CreateUser(ctx, adminReq); err != nil {
 		log.Printf("Failed to create admin user: %v", err)
 		return
 	}
 
-	// Add admin permissions
-	permissions := []string{
-		"user_management",
-		"system_admin",
-		"user_read",
-		"user_write",
-		"user_delete",
-	}
-
-	for _, perm := range permissions {
-		if err := userService.AddPermission(admin.ID, perm); err != nil {
-			log.Printf("Failed to add permission %s to admin: %v", perm, err)
-		}
-	}
-
 	// Create sample users
 	sampleUsers := []*models.UserRequest{
 		{
@@ -203,7 +738,7 @@ func createSampleData(userService *services.UserService) {
 	}
 
 	for _, userReq := range sampleUsers {
-		if _, err := userService.CreateUser(userReq); err != nil {
+		if _, err := userService.CreateUser(ctx, userReq); err != nil {
 			log.Printf("Failed to create user %s: %v", userReq.Username, err)
 		}
 	}
@@ -213,7 +748,9 @@ func createSampleData(userService *services.UserService) {
 
 // Helper functions for demo
 func printUserStats(userService *services.UserService) {
-	stats, err := userService.GetUserStats()
+	ctx := context.Background()
+
+	stats, err := userService.GetUserStats(ctx)
 	if err != nil {
 		log.Printf("Failed to get user stats: %v", err)
 		return
@@ -229,10 +766,12 @@ func printUserStats(userService *services.UserService) {
 }
 
 func demonstrateUserOperations(userService *services.UserService) {
+	ctx := context.Background()
+
 	log.Println("\n=== User Management Demo ===")
 
 	// Get all users
-	users, total, err := userService.GetAllUsers(1, 10)
+	users, total, err := userService.GetAllUsers(ctx, 1, 10)
 	if err != nil {
 		log.Printf("Failed to get users: %v", err)
 		return
@@ -246,17 +785,17 @@ func demonstrateUserOperations(userService *services.UserService) {
 
 	// Test authentication
 	log.Println("\n=== Authentication Test ===")
-	user, err := userService.AuthenticateUser("admin", "admin123")
+	user, err := userService.AuthenticateUser(ctx, "admin", "admin123", services.SecondFactor{}, "127.0.0.1")
 	if err != nil {
 		log.Printf("Authentication failed: %v", err)
 	} else {
 		log.Printf("Authentication successful for: %s", user.Username)
-		log.Printf("Last login: %v", user.LastLogin)
+		log.Printf("Last login: %v from %s", user.LastLogin, user.LastLoginIP)
 	}
 
 	// Test search
 	log.Println("\n=== Search Test ===")
-	searchResults, _, err := userService.SearchUsers("john", 1, 10)
+	searchResults, _, err := userService.SearchUsers(ctx, &utils.SearchParams{Query: "john", Page: 1, PageSize: 10}, nil)
 	if err != nil {
 		log.Printf("Search failed: %v", err)
 	} else {
@@ -275,18 +814,36 @@ func demonstrateUserOperations(userService *services.UserService) {
 func runDemo() {
 	log.Println("Running User Management Demo...")
 
+	config := loadConfig()
+
 	// Initialize database
-	db, err := initDatabase()
+	db, err := initDatabase(config.Database)
 	if err != nil {
 		log.Fatal("Failed to initialize database:", err)
 	}
+	if err := prepareSchema(db, config); err != nil {
+		log.Fatal("Failed to initialize database:", err)
+	}
 
 	// Initialize services
-	userService := services.NewUserService(db)
+	if err := applyPasswordHashing(config.Users); err != nil {
+		log.Fatal("Failed to configure password hashing:", err)
+	}
+	models.MinAge = config.Users.MinAge
+	if err := models.SetUsernamePolicy(config.Users.UsernamePattern, config.Users.ReservedUsernames); err != nil {
+		log.Fatal("Failed to configure username policy:", err)
+	}
+	userService := services.NewUserService(db, config.Users)
 
 	// Create sample data
 	createSampleData(userService)
 
+	// Purge soft-deleted users past retention
+	startPurgeJob(userService, config.Users)
+
+	// Anonymize users inactive past retention
+	startAnonymizeJob(userService, config.Users)
+
 	// Demonstrate operations
 	demonstrateUserOperations(userService)
 
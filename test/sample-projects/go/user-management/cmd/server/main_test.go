@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/example/user-management/internal/utils"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestConfigurePool(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   utils.DatabaseConfig
+		wantOpen int
+		wantIdle int
+	}{
+		{"defaults", utils.DatabaseConfig{}, defaultMaxOpenConns, defaultMaxIdleConns},
+		{"configured", utils.DatabaseConfig{MaxOpenConns: 4, MaxIdleConns: 2, ConnMaxLifetime: 1}, 4, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "users.db")), &gorm.Config{Logger: gormlogger.Discard})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sqlDB, err := db.DB()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sqlDB.Close()
+
+			if err := configurePool(db, tt.config); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := sqlDB.Stats().MaxOpenConnections; got != tt.wantOpen {
+				t.Errorf("MaxOpenConnections = %d, want %d", got, tt.wantOpen)
+			}
+
+			// Take every connection the pool allows; one more has to wait
+			ctx := context.Background()
+			conns := make([]*sql.Conn, tt.wantOpen)
+			for i := range conns {
+				if conns[i], err = sqlDB.Conn(ctx); err != nil {
+					t.Fatal(err)
+				}
+			}
+			waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+			defer cancel()
+			if _, err := sqlDB.Conn(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("connection beyond the limit: err = %v, want deadline exceeded", err)
+			}
+
+			// Returning them keeps only the idle limit open
+			for _, conn := range conns {
+				conn.Close()
+			}
+			stats := sqlDB.Stats()
+			if stats.Idle != tt.wantIdle {
+				t.Errorf("Idle = %d, want %d", stats.Idle, tt.wantIdle)
+			}
+			if want := int64(tt.wantOpen - tt.wantIdle); stats.MaxIdleClosed != want {
+				t.Errorf("MaxIdleClosed = %d, want %d", stats.MaxIdleClosed, want)
+			}
+		})
+	}
+}
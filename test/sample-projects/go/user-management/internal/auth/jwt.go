@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Token types carried in the typ claim
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+// Claims represents the claims carried by an access or refresh token
+type Claims struct {
+	UserID   uuid.UUID       `json:"uid"`
+	Username string          `json:"username"`
+	Role     models.UserRole `json:"role"`
+	Type     string          `json:"typ"`
+	// Version is the user's token version at issuance. Tokens carrying an
+	// older version than the stored one are rejected.
+	Version int `json:"ver"`
+	// ImpersonatedBy is the admin acting as the user, set only on
+	// impersonation tokens
+	ImpersonatedBy *uuid.UUID `json:"impersonated_by,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// JWTManager issues and validates JSON Web Tokens
+type JWTManager struct {
+	config utils.JWTConfig
+}
+
+// NewJWTManager creates a new JWT manager
+func NewJWTManager(config utils.JWTConfig) *JWTManager {
+	if config.SigningAlgorithm == "" {
+		config.SigningAlgorithm = jwt.SigningMethodHS256.Alg()
+	}
+	if config.ExpirationHours <= 0 {
+		config.ExpirationHours = 24
+	}
+	if config.RefreshHours <= 0 {
+		config.RefreshHours = 168
+	}
+	if config.ImpersonationMinutes <= 0 {
+		config.ImpersonationMinutes = 15
+	}
+	return &JWTManager{config: config}
+}
+
+// GenerateToken creates a signed short-lived access token for the user
+func (m *JWTManager) GenerateToken(user *models.User) (string, *Claims, error) {
+	return m.generate(user, TokenTypeAccess, time.Duration(m.config.ExpirationHours)*time.Hour, nil)
+}
+
+// GenerateRefreshToken creates a signed long-lived refresh token for the user.
+// The returned claims' ID must be stored so the token can be revoked.
+func (m *JWTManager) GenerateRefreshToken(user *models.User) (string, *Claims, error) {
+	return m.generate(user, TokenTypeRefresh, time.Duration(m.config.RefreshHours)*time.Hour, nil)
+}
+
+// GenerateImpersonationToken creates a signed access token that lets the
+// admin adminID act as user. It lives for ImpersonationMinutes and has no
+// refresh token, so it can't be extended.
+func (m *JWTManager) GenerateImpersonationToken(user *models.User, adminID uuid.UUID) (string, *Claims, error) {
+	return m.generate(user, TokenTypeAccess, time.Duration(m.config.ImpersonationMinutes)*time.Minute, &adminID)
+}
+
+func (m *JWTManager) generate(user *models.User, tokenType string, ttl time.Duration, impersonatedBy *uuid.UUID) (string, *Claims, error) {
+	method := jwt.GetSigningMethod(m.config.SigningAlgorithm)
+	if _, ok := method.(*jwt.SigningMethodHMAC); !ok {
+		return "", nil, fmt.Errorf("unsupported signing algorithm: %s", m.config.SigningAlgorithm)
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		UserID:         user.ID,
+		Username:       user.Username,
+		Role:           user.Role,
+		Type:           tokenType,
+		Version:        user.TokenVersion,
+		ImpersonatedBy: impersonatedBy,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   user.ID.String(),
+			Issuer:    m.config.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(method, claims).SignedString([]byte(m.config.SecretKey))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return token, claims, nil
+}
+
+// ValidateToken parses and validates a token of the expected type,
+// returning its claims
+func (m *JWTManager) ValidateToken(tokenString, tokenType string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(m.config.SecretKey), nil
+	}, jwt.WithValidMethods([]string{m.config.SigningAlgorithm}))
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	if claims.Type != tokenType {
+		return nil, fmt.Errorf("invalid token type: expected %s", tokenType)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// HTTPRequests counts HTTP requests by method, route pattern and status
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Total number of HTTP requests.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration observes HTTP request latency by method, route pattern and status
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency in seconds.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// LoginSuccesses counts successful logins
+	LoginSuccesses = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "auth_login_success_total",
+		Help: "Total number of successful logins.",
+	})
+
+	// LoginFailures counts failed logins
+	LoginFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "auth_login_failure_total",
+		Help: "Total number of failed logins.",
+	})
+
+	// Lockouts counts accounts locked after too many failed logins
+	Lockouts = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "auth_lockout_total",
+		Help: "Total number of accounts locked due to failed logins.",
+	})
+)
+
+// Middleware records request count and latency. Requests are labelled with
+// the route pattern (e.g. /api/v1/users/:id) rather than the raw path to
+// keep label cardinality bounded.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		HTTPRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		HTTPRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the metrics of the default Prometheus registry
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BackupCodeCount is how many recovery codes are issued per generation
+const BackupCodeCount = 10
+
+// backupCodeCost is the bcrypt cost for backup codes. Each code carries 40
+// random bits, so it doesn't need a password's work factor, and a login may
+// compare against every remaining code.
+const backupCodeCost = bcrypt.MinCost
+
+var backupCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateBackupCodes replaces the user's backup codes with a fresh set and
+// returns the plaintext codes. Only their hashes are kept, so the returned
+// slice is the one chance to show them to the user.
+func (u *User) GenerateBackupCodes() ([]string, error) {
+	codes := make([]string, BackupCodeCount)
+	hashes := make([]string, BackupCodeCount)
+
+	for i := range codes {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+
+		code := strings.ToLower(backupCodeEncoding.EncodeToString(raw))
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), backupCodeCost)
+		if err != nil {
+			return nil, err
+		}
+
+		codes[i] = code[:4] + "-" + code[4:]
+		hashes[i] = string(hash)
+	}
+
+	u.BackupCodes = hashes
+	return codes, nil
+}
+
+// ConsumeBackupCode checks code against the user's remaining backup codes
+// and removes it if it matches, so each code works only once
+func (u *User) ConsumeBackupCode(code string) bool {
+	code = normalizeBackupCode(code)
+	if code == "" {
+		return false
+	}
+
+	for i, hash := range u.BackupCodes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(code)) == nil {
+			u.BackupCodes = append(u.BackupCodes[:i:i], u.BackupCodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeBackupCode strips the separators and case a user may type
+func normalizeBackupCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/example/user-management/internal/utils"
+	"gorm.io/gorm/schema"
+)
+
+// MetadataCipher encrypts the values of EncryptedMetadataKeys before user
+// metadata is stored and decrypts them when it is loaded. Encryption is
+// off while it is nil.
+var (
+	MetadataCipher        *utils.FieldCipher
+	EncryptedMetadataKeys []string
+)
+
+func init() {
+	schema.RegisterSerializer("metadata", metadataSerializer{})
+}
+
+// metadataSerializer stores metadata as JSON like the json serializer, with
+// the values of encrypted keys replaced by ciphertext
+type metadataSerializer struct{}
+
+// Scan implements schema.SerializerInterface
+func (metadataSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	if err := (schema.JSONSerializer{}).Scan(ctx, field, dst, dbValue); err != nil {
+		return err
+	}
+
+	metadata, _ := field.ReflectValueOf(ctx, dst).Interface().(map[string]interface{})
+	return decryptMetadata(metadata)
+}
+
+// Value implements schema.SerializerValuerInterface
+func (metadataSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	metadata, ok := fieldValue.(map[string]interface{})
+	if ok {
+		encrypted, err := encryptMetadata(metadata)
+		if err != nil {
+			return nil, err
+		}
+		fieldValue = encrypted
+	}
+
+	return (schema.JSONSerializer{}).Value(ctx, field, dst, fieldValue)
+}
+
+// encryptMetadata returns a copy of metadata with the values of encrypted
+// keys replaced by ciphertext. The caller's map is left untouched.
+func encryptMetadata(metadata map[string]interface{}) (map[string]interface{}, error) {
+	if MetadataCipher == nil || len(EncryptedMetadataKeys) == 0 || metadata == nil {
+		return metadata, nil
+	}
+
+	encrypted := make(map[string]interface{}, len(metadata))
+	for key, value := range metadata {
+		encrypted[key] = value
+	}
+
+	for _, key := range EncryptedMetadataKeys {
+		value, ok := metadata[key]
+		if !ok || value == nil {
+			continue
+		}
+
+		plaintext, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode metadata %s: %w", key, err)
+		}
+		if encrypted[key], err = MetadataCipher.Encrypt(plaintext); err != nil {
+			return nil, fmt.Errorf("failed to encrypt metadata %s: %w", key, err)
+		}
+	}
+
+	return encrypted, nil
+}
+
+// decryptMetadata replaces ciphertext values in metadata with their
+// plaintext in place. Values stored before encryption was enabled are left
+// as they are.
+func decryptMetadata(metadata map[string]interface{}) error {
+	for key, value := range metadata {
+		text, ok := value.(string)
+		if !ok || !utils.IsEncrypted(text) {
+			continue
+		}
+		if MetadataCipher == nil {
+			return fmt.Errorf("metadata %s is encrypted but no encryption keys are configured", key)
+		}
+
+		plaintext, err := MetadataCipher.Decrypt(text)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt metadata %s: %w", key, err)
+		}
+
+		var decoded interface{}
+		if err := json.Unmarshal(plaintext, &decoded); err != nil {
+			return fmt.Errorf("failed to decode metadata %s: %w", key, err)
+		}
+		metadata[key] = decoded
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyKey records the user created for a client-supplied
+// Idempotency-Key so retries can return the original result
+type IdempotencyKey struct {
+	Key         string    `json:"key" gorm:"primary_key"`
+	RequestHash string    `json:"request_hash" gorm:"not null"`
+	UserID      uuid.UUID `json:"user_id" gorm:"type:uuid;not null"`
+	ExpiresAt   time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// IsExpired checks if the idempotency key is expired
+func (k *IdempotencyKey) IsExpired() bool {
+	return time.Now().After(k.ExpiresAt)
+}
+
+// TableName returns the table name for GORM
+func (k *IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LoginEvent records one authentication attempt against a known user
+type LoginEvent struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index:idx_login_events_user_time,priority:1"`
+	Success   bool      `json:"success"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at" gorm:"index:idx_login_events_user_time,priority:2"`
+}
+
+// TableName returns the table name for GORM
+func (e *LoginEvent) TableName() string {
+	return "login_events"
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing algorithms
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+// Hasher hashes and verifies passwords with one algorithm. Hashes carry
+// their algorithm as a prefix ($2a$ for bcrypt, $argon2id$ for argon2id),
+// so a stored hash can be verified whichever hasher made it.
+type Hasher interface {
+	// Algorithm names the algorithm, as in the password_algorithm setting
+	Algorithm() string
+
+	// Hash returns an encoded hash of password
+	Hash(password string) (string, error)
+
+	// Owns reports whether hash was made by this algorithm
+	Owns(hash string) bool
+
+	// Verify reports whether password matches hash
+	Verify(hash, password string) bool
+
+	// Outdated reports whether hash was made with parameters other than
+	// the ones Hash uses now
+	Outdated(hash string) bool
+}
+
+// PasswordHasher hashes new passwords. Hashes made by the other hashers
+// still verify and are upgraded on the user's next login.
+var PasswordHasher Hasher = BcryptHasher{}
+
+// passwordHashers are every hasher a stored hash may come from
+var passwordHashers = []Hasher{BcryptHasher{}, Argon2idHasher{}}
+
+// HasherFor returns the hasher for an algorithm name
+func HasherFor(algorithm string) (Hasher, error) {
+	for _, hasher := range passwordHashers {
+		if hasher.Algorithm() == algorithm {
+			return hasher, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown password algorithm %q", algorithm)
+}
+
+// hasherOf returns the hasher that made hash, or nil if none did.
+// PasswordHasher is asked first so a replacement hasher verifies its own
+// hashes.
+func hasherOf(hash string) Hasher {
+	if PasswordHasher.Owns(hash) {
+		return PasswordHasher
+	}
+	for _, hasher := range passwordHashers {
+		if hasher.Owns(hash) {
+			return hasher
+		}
+	}
+	return nil
+}
+
+// BcryptHasher hashes passwords with bcrypt at PasswordCost
+type BcryptHasher struct{}
+
+func (BcryptHasher) Algorithm() string { return AlgorithmBcrypt }
+
+func (BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), PasswordCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (BcryptHasher) Owns(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (BcryptHasher) Verify(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func (BcryptHasher) Outdated(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err == nil && cost != PasswordCost
+}
+
+// Argon2id parameters, following the second recommended option of RFC 9106
+const (
+	argon2idTime    = 3
+	argon2idMemory  = 64 * 1024 // KiB
+	argon2idThreads = 4
+	argon2idSaltLen = 16
+	argon2idKeyLen  = 32
+)
+
+// Argon2idHasher hashes passwords with argon2id. Hashes are encoded in the
+// PHC string format: $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>.
+type Argon2idHasher struct{}
+
+func (Argon2idHasher) Algorithm() string { return AlgorithmArgon2id }
+
+func (Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2idSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, argon2idTime, argon2idMemory, argon2idThreads, argon2idKeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argon2idMemory, argon2idTime, argon2idThreads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (Argon2idHasher) Owns(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func (Argon2idHasher) Verify(hash, password string) bool {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(computed, key) == 1
+}
+
+func (Argon2idHasher) Outdated(hash string) bool {
+	params, _, _, err := decodeArgon2id(hash)
+	return err == nil && params != argon2idParams{argon2idTime, argon2idMemory, argon2idThreads}
+}
+
+type argon2idParams struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+// decodeArgon2id splits an argon2id hash into its parameters, salt and key
+func decodeArgon2id(hash string) (argon2idParams, []byte, []byte, error) {
+	var params argon2idParams
+	invalid := errors.New("invalid argon2id hash")
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return params, nil, nil, invalid
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, invalid
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return params, nil, nil, invalid
+	}
+	if params.time == 0 || params.threads == 0 {
+		return params, nil, nil, invalid
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, invalid
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, invalid
+	}
+	return params, salt, key, nil
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	// Embed the IANA time zone database so timezone preferences validate
+	// the same on hosts without zoneinfo installed
+	_ "time/tzdata"
+)
+
+// Preference defaults applied when a user hasn't set a value
+const (
+	DefaultLocale   = "en-US"
+	DefaultTimezone = "UTC"
+)
+
+// localePattern matches a BCP 47 language tag made of a language, an
+// optional script and an optional region, such as en, en-US or zh-Hant-TW
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z][a-z]{3})?(-([A-Z]{2}|[0-9]{3}))?$`)
+
+// UserPreferences are a user's application settings, kept apart from the
+// free-form metadata. Unset fields are nil and take their default; an
+// update only changes the fields it sets.
+type UserPreferences struct {
+	Locale        *string                  `json:"locale,omitempty"`
+	Timezone      *string                  `json:"timezone,omitempty"`
+	Notifications *NotificationPreferences `json:"notifications,omitempty"`
+}
+
+// NotificationPreferences are the kinds of notification a user opted into
+type NotificationPreferences struct {
+	// Email covers notifications about activity concerning the user
+	Email *bool `json:"email,omitempty"`
+
+	// Security covers alerts such as new logins and password changes
+	Security *bool `json:"security,omitempty"`
+
+	// Marketing covers product news and offers; it is opt-in
+	Marketing *bool `json:"marketing,omitempty"`
+}
+
+// Merge sets the fields that update sets, leaving the others as they are
+func (p *UserPreferences) Merge(update UserPreferences) {
+	if update.Locale != nil {
+		p.Locale = update.Locale
+	}
+	if update.Timezone != nil {
+		p.Timezone = update.Timezone
+	}
+	if update.Notifications != nil {
+		if p.Notifications == nil {
+			p.Notifications = &NotificationPreferences{}
+		}
+		n := update.Notifications
+		if n.Email != nil {
+			p.Notifications.Email = n.Email
+		}
+		if n.Security != nil {
+			p.Notifications.Security = n.Security
+		}
+		if n.Marketing != nil {
+			p.Notifications.Marketing = n.Marketing
+		}
+	}
+}
+
+// WithDefaults returns the preferences with every unset field filled in
+// with its default
+func (p UserPreferences) WithDefaults() UserPreferences {
+	resolved := UserPreferences{
+		Locale:   stringOr(p.Locale, DefaultLocale),
+		Timezone: stringOr(p.Timezone, DefaultTimezone),
+	}
+
+	var n NotificationPreferences
+	if p.Notifications != nil {
+		n = *p.Notifications
+	}
+	resolved.Notifications = &NotificationPreferences{
+		Email:     boolOr(n.Email, true),
+		Security:  boolOr(n.Security, true),
+		Marketing: boolOr(n.Marketing, false),
+	}
+	return resolved
+}
+
+// Validate checks the locale is a language tag and the timezone an IANA
+// time zone name
+func (p *UserPreferences) Validate() error {
+	if p.Locale != nil && !localePattern.MatchString(*p.Locale) {
+		return fmt.Errorf("invalid locale %q: must be a language tag such as en or en-US", *p.Locale)
+	}
+	if p.Timezone != nil {
+		// LoadLocation also accepts "" and "Local", which mean UTC and the
+		// server's zone rather than a zone the user chose
+		tz := *p.Timezone
+		if _, err := time.LoadLocation(tz); err != nil || tz == "" || tz == "Local" {
+			return fmt.Errorf("invalid timezone %q: must be an IANA time zone name such as Europe/Paris", tz)
+		}
+	}
+	return nil
+}
+
+func stringOr(value *string, def string) *string {
+	if value == nil {
+		return &def
+	}
+	return value
+}
+
+func boolOr(value *bool, def bool) *bool {
+	if value == nil {
+		return &def
+	}
+	return value
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken represents an issued refresh token that can be revoked
+type RefreshToken struct {
+	ID        string     `json:"id" gorm:"primary_key"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:uuid;index;not null"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	// LastUsedAt is when the token was last exchanged for an access token
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// IsRevoked checks if the refresh token has been revoked
+func (t *RefreshToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
+
+// IsExpired checks if the refresh token is expired
+func (t *RefreshToken) IsExpired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
+
+// LastActivity returns when the session was last used, falling back to
+// its creation time
+func (t *RefreshToken) LastActivity() time.Time {
+	if t.LastUsedAt != nil {
+		return *t.LastUsedAt
+	}
+	return t.CreatedAt
+}
+
+// IsActive checks if the refresh token can still be used
+func (t *RefreshToken) IsActive() bool {
+	return !t.IsRevoked() && !t.IsExpired()
+}
+
+// TableName returns the table name for GORM
+func (t *RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// SystemFlag marks a one-time system operation as done. The primary key
+// makes claiming a flag atomic: only one insert of a given key succeeds.
+type SystemFlag struct {
+	Key       string    `json:"key" gorm:"primary_key"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName returns the table name for GORM
+func (f *SystemFlag) TableName() string {
+	return "system_flags"
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 secret of the RFC 6238 test vectors,
+// "12345678901234567890", base32 encoded
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// The RFC's 8 digit values, cut to their last 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := TOTPCode(rfc6238Secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestConsumeTOTPCode(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	codeAt := func(t *testing.T, at time.Time) string {
+		code, err := TOTPCode(rfc6238Secret, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name string
+		code func(t *testing.T) string
+		want bool
+	}{
+		{"current", func(t *testing.T) string { return codeAt(t, now) }, true},
+		{"previous period", func(t *testing.T) string { return codeAt(t, now.Add(-30*time.Second)) }, true},
+		{"next period", func(t *testing.T) string { return codeAt(t, now.Add(30*time.Second)) }, true},
+		{"two periods old", func(t *testing.T) string { return codeAt(t, now.Add(-60*time.Second)) }, false},
+		{"wrong", func(t *testing.T) string { return "000000" }, false},
+		{"empty", func(t *testing.T) string { return "" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &User{TOTPSecret: rfc6238Secret}
+			code := tt.code(t)
+			if got := user.ConsumeTOTPCode(code, now); got != tt.want {
+				t.Fatalf("ConsumeTOTPCode = %v, want %v", got, tt.want)
+			}
+			if tt.want && user.ConsumeTOTPCode(code, now) {
+				t.Error("code accepted a second time")
+			}
+		})
+	}
+}
+
+func TestConsumeTOTPCodeWithoutSecret(t *testing.T) {
+	user := &User{}
+	code, err := TOTPCode("", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ConsumeTOTPCode(code, time.Now()) {
+		t.Error("code accepted for a user without a secret")
+	}
+}
@@ -3,8 +3,13 @@ package models
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/example/user-management/internal/utils"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -19,6 +24,33 @@ const (
 	RoleGuest UserRole = "guest"
 )
 
+// roleRank orders the roles from least to most privileged. A role satisfies
+// any requirement for a role ranked at or below it.
+var roleRank = map[UserRole]int{
+	RoleGuest: 1,
+	RoleUser:  2,
+	RoleAdmin: 3,
+}
+
+// Valid reports whether r is a known role
+func (r UserRole) Valid() bool {
+	_, ok := roleRank[r]
+	return ok
+}
+
+// AtLeast reports whether r ranks at or above min, so an admin satisfies a
+// user or guest requirement. Unknown roles satisfy nothing.
+func (r UserRole) AtLeast(min UserRole) bool {
+	rank, ok := roleRank[r]
+	return ok && rank >= roleRank[min]
+}
+
+// PasswordCost is the bcrypt cost used when hashing passwords
+var PasswordCost = bcrypt.DefaultCost
+
+// MinAge is the minimum age a user must have; 0 disables the check
+var MinAge = 0
+
 // UserStatus represents the status of a user
 type UserStatus string
 
@@ -29,27 +61,104 @@ const (
 	StatusDeleted   UserStatus = "deleted"
 )
 
+// SuspensionCause records why a suspended user was suspended
+type SuspensionCause string
+
+const (
+	// SuspensionCauseLockout is the automatic lockout after too many
+	// failed logins
+	SuspensionCauseLockout SuspensionCause = "lockout"
+	// SuspensionCauseAdmin is a deliberate suspension by an admin
+	SuspensionCauseAdmin SuspensionCause = "admin"
+	// SuspensionCauseExpiry is a suspension because the account expired
+	SuspensionCauseExpiry SuspensionCause = "expiry"
+)
+
+// RolePermissions maps each role to the permissions it grants by default
+var RolePermissions = map[UserRole][]string{
+	RoleAdmin: {
+		"user_management",
+		"system_admin",
+		"user_read",
+		"user_write",
+		"user_delete",
+	},
+	RoleUser:  {"user_read"},
+	RoleGuest: {},
+}
+
+// RoleAllowedPermissions maps each role to the permissions it may be
+// granted. Roles without an entry, such as admin, may hold any permission.
+var RoleAllowedPermissions = map[UserRole][]string{
+	RoleUser:  {"user_read", "user_write"},
+	RoleGuest: {"user_read"},
+}
+
 // User represents a user in the system
 type User struct {
-	ID           uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
-	Username     string     `json:"username" gorm:"uniqueIndex;not null"`
-	Email        string     `json:"email" gorm:"uniqueIndex"`
-	Name         string     `json:"name" gorm:"not null"`
-	Age          int        `json:"age"`
-	PasswordHash string     `json:"-" gorm:"not null"`
-	Role         UserRole   `json:"role" gorm:"default:user"`
-	Status       UserStatus `json:"status" gorm:"default:active"`
-	LastLogin    *time.Time `json:"last_login"`
-	LoginAttempts int       `json:"login_attempts" gorm:"default:0"`
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+	ID            uuid.UUID      `json:"id" gorm:"type:uuid;primary_key"`
+	Username      string         `json:"username" gorm:"not null"`
+	Email         string         `json:"email" gorm:"index:idx_users_email_lookup"`
+	EmailVerified bool           `json:"email_verified" gorm:"default:false"`
+	Name          string         `json:"name" gorm:"not null"`
+	Age           int            `json:"age"`
+	PasswordHash  string         `json:"-" gorm:"not null"`
+	Role          UserRole       `json:"role" gorm:"default:user"`
+	Status        UserStatus     `json:"status" gorm:"default:active"`
+	LastLogin     *time.Time     `json:"last_login"`
+	LastLoginIP   string         `json:"last_login_ip"`
+	LastSeenAt    *time.Time     `json:"last_seen_at"`
+	LoginAttempts int            `json:"login_attempts" gorm:"default:0"`
+	LockedAt      *time.Time     `json:"locked_at"`
+	TokenVersion  int            `json:"-" gorm:"not null;default:0"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// SuspensionCause is why a suspended user was suspended; it is empty
+	// for users in any other status
+	SuspensionCause SuspensionCause `json:"suspension_cause,omitempty" gorm:"size:20"`
+
+	// Shadowed marks a shadow banned user. They can still log in and use
+	// the app, but the application should hide their contributions from
+	// others. Only admins see or change it.
+	Shadowed bool `json:"-" gorm:"not null;default:false"`
+
+	// AnonymizedAt is when the user's personal data was replaced with
+	// placeholders by Anonymize
+	AnonymizedAt *time.Time `json:"anonymized_at,omitempty"`
+
+	// PasswordChangedAt is when the password was last set, by the user or
+	// an admin reset
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+
+	// DeletionReason is the optional reason given when the user was deleted
+	DeletionReason string `json:"deletion_reason,omitempty" gorm:"size:500"`
 
 	// Permissions is a JSON field containing user permissions
-	Permissions []string `json:"permissions" gorm:"type:json"`
+	Permissions []string `json:"permissions" gorm:"type:json;serializer:json"`
 
 	// Metadata for additional user information
-	Metadata map[string]interface{} `json:"metadata" gorm:"type:json"`
+	Metadata map[string]interface{} `json:"metadata" gorm:"type:json;serializer:metadata"`
+
+	// TwoFactorEnabled requires a second factor at login: a code from the
+	// user's authenticator app or, to recover without it, a backup code
+	TwoFactorEnabled bool `json:"two_factor_enabled" gorm:"not null;default:false"`
+
+	// TOTPSecret is the base32 secret shared with the authenticator app.
+	// It is set at setup and only used once TwoFactorEnabled is.
+	TOTPSecret string `json:"-"`
+
+	// TOTPLastStep is the time step of the last accepted authenticator
+	// code; codes from that step or earlier are refused
+	TOTPLastStep int64 `json:"-" gorm:"not null;default:0"`
+
+	// BackupCodes holds bcrypt hashes of the unused 2FA recovery codes
+	BackupCodes []string `json:"-" gorm:"type:json;serializer:json"`
+
+	// Preferences holds the settings the user chose; see GetPreferences
+	// for the values with defaults applied
+	Preferences UserPreferences `json:"-" gorm:"type:json;serializer:json"`
 }
 
 // UserRequest represents a request to create or update a user
@@ -63,20 +172,110 @@ type UserRequest struct {
 	Metadata map[string]interface{} `json:"metadata"`
 }
 
+// UserUpdate represents a request to update a user. Only the fields that
+// are set change; the service validates the result as a whole. Status is
+// not among them: it changes through the admin status endpoints, which
+// audit the change and log the user out.
+type UserUpdate struct {
+	Username *string                `json:"username"`
+	Email    *string                `json:"email"`
+	Name     *string                `json:"name"`
+	Age      *int                   `json:"age"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+// AdminUserRequest represents an admin's request to create a user with
+// fields that public signup cannot set
+type AdminUserRequest struct {
+	UserRequest
+	Status        UserStatus `json:"status" binding:"omitempty,oneof=active inactive suspended"`
+	Permissions   []string   `json:"permissions"`
+	EmailVerified bool       `json:"email_verified"`
+}
+
 // UserResponse represents a user response (without sensitive data)
 type UserResponse struct {
-	ID          uuid.UUID              `json:"id"`
-	Username    string                 `json:"username"`
-	Email       string                 `json:"email"`
-	Name        string                 `json:"name"`
-	Age         int                    `json:"age"`
-	Role        UserRole               `json:"role"`
-	Status      UserStatus             `json:"status"`
-	LastLogin   *time.Time             `json:"last_login"`
-	CreatedAt   time.Time              `json:"created_at"`
-	UpdatedAt   time.Time              `json:"updated_at"`
-	Permissions []string               `json:"permissions"`
-	Metadata    map[string]interface{} `json:"metadata"`
+	ID               uuid.UUID              `json:"id"`
+	Username         string                 `json:"username"`
+	Email            string                 `json:"email"`
+	EmailVerified    bool                   `json:"email_verified"`
+	Name             string                 `json:"name"`
+	Age              int                    `json:"age"`
+	Role             UserRole               `json:"role"`
+	Status           UserStatus             `json:"status"`
+	TwoFactorEnabled bool                   `json:"two_factor_enabled"`
+	LastLogin        *time.Time             `json:"last_login"`
+	LastSeenAt       *time.Time             `json:"last_seen_at"`
+	CreatedAt        time.Time              `json:"created_at"`
+	UpdatedAt        time.Time              `json:"updated_at"`
+	Permissions      []string               `json:"permissions"`
+	Metadata         map[string]interface{} `json:"metadata"`
+
+	// Deleted flags users returned by lookups that include deleted rows
+	Deleted        bool       `json:"deleted,omitempty"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+	DeletionReason string     `json:"deletion_reason,omitempty"`
+}
+
+// AdminUserResponse is UserResponse plus the moderation state only admins
+// may see
+type AdminUserResponse struct {
+	*UserResponse
+	Shadowed bool `json:"shadowed"`
+}
+
+// UserSummary is the slim form of UserResponse used by list endpoints; it
+// leaves out permissions and metadata
+type UserSummary struct {
+	ID        uuid.UUID  `json:"id"`
+	Username  string     `json:"username"`
+	Email     string     `json:"email"`
+	Name      string     `json:"name"`
+	Role      UserRole   `json:"role"`
+	Status    UserStatus `json:"status"`
+	LastLogin *time.Time `json:"last_login"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+
+	DeletionReason string `json:"deletion_reason,omitempty"`
+}
+
+// UserDataExport represents everything held about a single user, as returned
+// for a data-subject access request
+type UserDataExport struct {
+	User       *UserResponse    `json:"user"`
+	AuditLogs  []utils.AuditLog `json:"audit_logs"`
+	Sessions   []RefreshToken   `json:"sessions"`
+	ExportedAt time.Time        `json:"exported_at"`
+
+	// Preferences are the user's settings with defaults applied
+	Preferences UserPreferences `json:"preferences"`
+}
+
+// ImportResult is the outcome of one row of a bulk import
+type ImportResult struct {
+	Index    int           `json:"index"`
+	Username string        `json:"username"`
+	User     *UserResponse `json:"user,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// ImportReport summarizes a bulk import. Created counts rows that were (or,
+// for a dry run, would have been) created.
+type ImportReport struct {
+	DryRun  bool           `json:"dry_run"`
+	Total   int            `json:"total"`
+	Created int            `json:"created"`
+	Failed  int            `json:"failed"`
+	Results []ImportResult `json:"results"`
+}
+
+// BulkResult is the outcome of a bulk operation for one user. Error gives
+// the reason the operation failed for that user.
+type BulkResult struct {
+	ID      uuid.UUID `json:"id"`
+	Success bool      `json:"success"`
+	Error   string    `json:"error,omitempty"`
 }
 
 // BeforeCreate is a GORM hook that runs before creating a user
@@ -96,29 +295,135 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// BeforeSave is a GORM hook that runs before creating or updating a user
+func (u *User) BeforeSave(tx *gorm.DB) error {
+	u.Username = NormalizeUsername(u.Username)
+	u.Email = NormalizeEmail(u.Email)
+	return nil
+}
+
+// NormalizeUsername returns the canonical (lowercase) form of a username
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// NormalizeEmail returns the canonical (lowercase) form of an email address
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 // SetPassword hashes and sets the user's password
 func (u *User) SetPassword(password string) error {
-	if len(password) < 8 {
-		return errors.New("password must be at least 8 characters long")
+	if err := utils.DefaultPasswordPolicy.Validate(password); err != nil {
+		return err
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err := u.RehashPassword(password); err != nil {
+		return err
+	}
+
+	u.PasswordChangedAt = time.Now()
+	return nil
+}
+
+// RehashPassword hashes the user's current password again with the current
+// PasswordHasher. Unlike SetPassword it doesn't count as a password change.
+func (u *User) RehashPassword(password string) error {
+	hash, err := PasswordHasher.Hash(password)
 	if err != nil {
 		return err
 	}
 
-	u.PasswordHash = string(hash)
+	u.PasswordHash = hash
 	return nil
 }
 
-// VerifyPassword checks if the provided password matches the user's password
+// PasswordExpired reports whether the password is older than maxAge. A
+// non-positive maxAge means passwords never expire.
+func (u *User) PasswordExpired(maxAge time.Duration) bool {
+	return maxAge > 0 && time.Since(u.PasswordChangedAt) > maxAge
+}
+
+// VerifyPassword checks if the provided password matches the user's
+// password, using whichever algorithm the stored hash was made with
 func (u *User) VerifyPassword(password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password))
-	return err == nil
+	hasher := hasherOf(u.PasswordHash)
+	return hasher != nil && hasher.Verify(u.PasswordHash, password)
+}
+
+var (
+	dummyHashMu sync.Mutex
+	dummyHash   string
+)
+
+// PrepareDummyHash precomputes the hash VerifyDummyPassword compares
+// against using the current PasswordHasher and PasswordCost. Call it after
+// changing either so unknown-user logins keep matching the timing of real
+// ones.
+func PrepareDummyHash() {
+	hash, _ := PasswordHasher.Hash(uuid.NewString())
+
+	dummyHashMu.Lock()
+	dummyHash = hash
+	dummyHashMu.Unlock()
+}
+
+// VerifyDummyPassword runs a password comparison against a throwaway hash.
+// Logins for unknown users call it so they take as long as logins for
+// existing ones, which keeps response times from revealing accounts.
+func VerifyDummyPassword(password string) {
+	dummyHashMu.Lock()
+	if dummyHash == "" {
+		dummyHash, _ = PasswordHasher.Hash(uuid.NewString())
+	}
+	hash := dummyHash
+	dummyHashMu.Unlock()
+
+	_ = PasswordHasher.Verify(hash, password)
+}
+
+// LooksLikeEmail reports whether a login identifier should be treated as an
+// email address rather than a username
+func LooksLikeEmail(identifier string) bool {
+	return strings.Contains(identifier, "@")
+}
+
+// NeedsRehash checks if the password hash was created with an algorithm
+// other than the current PasswordHasher, or with outdated parameters such
+// as a bcrypt cost other than PasswordCost
+func (u *User) NeedsRehash() bool {
+	hasher := hasherOf(u.PasswordHash)
+	if hasher == nil {
+		return false
+	}
+	return hasher.Algorithm() != PasswordHasher.Algorithm() || hasher.Outdated(u.PasswordHash)
+}
+
+// NormalizePermission returns the canonical (trimmed, lowercase) form of a
+// permission
+func NormalizePermission(permission string) string {
+	return strings.ToLower(strings.TrimSpace(permission))
+}
+
+// NormalizePermissions returns the canonical forms of permissions without
+// duplicates. It rejects empty or whitespace-only permissions.
+func NormalizePermissions(permissions []string) ([]string, error) {
+	normalized := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		permission = NormalizePermission(permission)
+		if permission == "" {
+			return nil, errors.New("permission must not be empty")
+		}
+		if !slices.Contains(normalized, permission) {
+			normalized = append(normalized, permission)
+		}
+	}
+	return normalized, nil
 }
 
 // HasPermission checks if the user has a specific permission
 func (u *User) HasPermission(permission string) bool {
+	permission = NormalizePermission(permission)
 	for _, p := range u.Permissions {
 		if p == permission {
 			return true
@@ -127,15 +432,24 @@ func (u *User) HasPermission(permission string) bool {
 	return false
 }
 
-// AddPermission adds a permission to the user
+// Can checks if the user is allowed to perform an action requiring the
+// permission. Admins are implicitly allowed everything.
+func (u *User) Can(permission string) bool {
+	return u.IsAdmin() || u.HasPermission(permission)
+}
+
+// AddPermission adds a permission to the user in its canonical form.
+// Blank permissions are ignored.
 func (u *User) AddPermission(permission string) {
-	if !u.HasPermission(permission) {
+	permission = NormalizePermission(permission)
+	if permission != "" && !u.HasPermission(permission) {
 		u.Permissions = append(u.Permissions, permission)
 	}
 }
 
 // RemovePermission removes a permission from the user
 func (u *User) RemovePermission(permission string) {
+	permission = NormalizePermission(permission)
 	for i, p := range u.Permissions {
 		if p == permission {
 			u.Permissions = append(u.Permissions[:i], u.Permissions[i+1:]...)
@@ -144,6 +458,14 @@ func (u *User) RemovePermission(permission string) {
 	}
 }
 
+// SetPermissions replaces the user's permissions, dropping duplicates
+func (u *User) SetPermissions(permissions []string) {
+	u.Permissions = make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		u.AddPermission(permission)
+	}
+}
+
 // IsActive checks if the user is active
 func (u *User) IsActive() bool {
 	return u.Status == StatusActive
@@ -151,7 +473,18 @@ func (u *User) IsActive() bool {
 
 // IsAdmin checks if the user is an admin
 func (u *User) IsAdmin() bool {
-	return u.Role == RoleAdmin
+	return u.RoleAtLeast(RoleAdmin)
+}
+
+// RoleAtLeast reports whether the user's role meets or exceeds role
+func (u *User) RoleAtLeast(role UserRole) bool {
+	return u.Role.AtLeast(role)
+}
+
+// IsDeleted checks if the user has been deleted, either by status or by a
+// GORM soft delete
+func (u *User) IsDeleted() bool {
+	return u.Status == StatusDeleted || u.DeletedAt.Valid
 }
 
 // IsLocked checks if the user is locked due to too many failed login attempts
@@ -159,8 +492,8 @@ func (u *User) IsLocked() bool {
 	return u.LoginAttempts >= 5 || u.Status == StatusSuspended
 }
 
-// Login records a successful login
-func (u *User) Login() error {
+// Login records a successful login from the given client IP
+func (u *User) Login(ip string) error {
 	if !u.IsActive() {
 		return errors.New("user is not active")
 	}
@@ -171,16 +504,22 @@ func (u *User) Login() error {
 
 	now := time.Now()
 	u.LastLogin = &now
+	u.LastLoginIP = ip
 	u.LoginAttempts = 0
 
 	return nil
 }
 
-// FailedLoginAttempt records a failed login attempt
+// FailedLoginAttempt records a failed login attempt. The fifth one locks
+// the account: it is suspended with the lockout cause and LockedAt records
+// when.
 func (u *User) FailedLoginAttempt() {
 	u.LoginAttempts++
-	if u.LoginAttempts >= 5 {
+	if u.LoginAttempts >= 5 && u.Status != StatusSuspended {
+		now := time.Now()
 		u.Status = StatusSuspended
+		u.SuspensionCause = SuspensionCauseLockout
+		u.LockedAt = &now
 	}
 }
 
@@ -189,52 +528,230 @@ func (u *User) ResetLoginAttempts() {
 	u.LoginAttempts = 0
 }
 
+// IsLockedOut reports whether the user is suspended by the failed login
+// lockout rather than for another cause
+func (u *User) IsLockedOut() bool {
+	return u.Status == StatusSuspended && u.SuspensionCause == SuspensionCauseLockout
+}
+
+// Unlock clears failed login attempts and the lockout. A user suspended by
+// the lockout becomes active again; other statuses, including a suspension
+// by an admin, are left alone.
+func (u *User) Unlock() error {
+	if u.IsDeleted() {
+		return errors.New("cannot unlock a deleted user")
+	}
+	if u.IsLockedOut() {
+		u.Status = StatusActive
+		u.SuspensionCause = ""
+	}
+	u.LoginAttempts = 0
+	u.LockedAt = nil
+	return nil
+}
+
+// statusTransitions is the user status state machine. Users move freely
+// between active, inactive and suspended, and any of them can be deleted.
+// Deleted is terminal: a deleted user cannot be activated, deactivated or
+// suspended, only restored with Restore. Staying in the same status is
+// always allowed.
+//
+//	active    -> inactive, suspended, deleted
+//	inactive  -> active, suspended, deleted
+//	suspended -> active, inactive, deleted
+//	deleted   -> (none)
+var statusTransitions = map[UserStatus][]UserStatus{
+	StatusActive:    {StatusInactive, StatusSuspended, StatusDeleted},
+	StatusInactive:  {StatusActive, StatusSuspended, StatusDeleted},
+	StatusSuspended: {StatusActive, StatusInactive, StatusDeleted},
+	StatusDeleted:   {},
+}
+
+// TransitionTo moves the user to status if the state machine allows it
+func (u *User) TransitionTo(status UserStatus) error {
+	if _, known := statusTransitions[status]; !known {
+		return fmt.Errorf("invalid status: %s", status)
+	}
+	if status == u.Status {
+		return nil
+	}
+
+	for _, allowed := range statusTransitions[u.Status] {
+		if allowed == status {
+			u.Status = status
+			u.SuspensionCause = ""
+			u.LockedAt = nil
+			return nil
+		}
+	}
+
+	return fmt.Errorf("cannot change status from %s to %s", u.Status, status)
+}
+
 // Activate activates the user account
-func (u *User) Activate() {
-	u.Status = StatusActive
+func (u *User) Activate() error {
+	if err := u.TransitionTo(StatusActive); err != nil {
+		return err
+	}
 	u.LoginAttempts = 0
+	return nil
 }
 
 // Deactivate deactivates the user account
-func (u *User) Deactivate() {
-	u.Status = StatusInactive
+func (u *User) Deactivate() error {
+	return u.TransitionTo(StatusInactive)
 }
 
-// Suspend suspends the user account
-func (u *User) Suspend() {
-	u.Status = StatusSuspended
+// Suspend suspends the user account on behalf of an admin. Suspending a
+// locked out user makes the suspension deliberate, so unlocking won't lift
+// it.
+func (u *User) Suspend() error {
+	return u.SuspendFor(SuspensionCauseAdmin)
+}
+
+// SuspendFor suspends the user account for cause. A user who is already
+// suspended takes the new cause.
+func (u *User) SuspendFor(cause SuspensionCause) error {
+	switch cause {
+	case SuspensionCauseLockout, SuspensionCauseAdmin, SuspensionCauseExpiry:
+	default:
+		return fmt.Errorf("invalid suspension cause: %s", cause)
+	}
+	if err := u.TransitionTo(StatusSuspended); err != nil {
+		return err
+	}
+	u.SuspensionCause = cause
+	if cause != SuspensionCauseLockout {
+		u.LockedAt = nil
+	}
+	return nil
 }
 
 // Delete marks the user as deleted
-func (u *User) Delete() {
-	u.Status = StatusDeleted
+func (u *User) Delete() error {
+	return u.TransitionTo(StatusDeleted)
+}
+
+// AnonymizedName replaces the name of anonymized users
+const AnonymizedName = "Anonymized User"
+
+// AnonymizedUsername is the username an anonymized user is given: "anon_"
+// and the first 12 hex digits of the id, which fits the username length
+// limits and is unique in practice
+func AnonymizedUsername(id uuid.UUID) string {
+	hex := strings.ReplaceAll(id.String(), "-", "")
+	return "anon_" + hex[:12]
+}
+
+// IsAnonymized reports whether the user's personal data has been removed
+func (u *User) IsAnonymized() bool {
+	return u.AnonymizedAt != nil
+}
+
+// Anonymize replaces the user's personal data with placeholders. The id,
+// role, status, age and timestamps are kept so the user still counts in
+// statistics. The password and second factor are cleared and the token
+// version bumped, so nobody can sign in as the user afterwards.
+func (u *User) Anonymize() {
+	now := time.Now()
+	u.Username = AnonymizedUsername(u.ID)
+	u.Name = AnonymizedName
+	u.Email = ""
+	u.EmailVerified = false
+	u.Metadata = map[string]interface{}{}
+	u.LastLoginIP = ""
+	u.DeletionReason = ""
+	u.PasswordHash = ""
+	u.DisableTwoFactor()
+	u.Preferences = UserPreferences{}
+	u.TokenVersion++
+	u.AnonymizedAt = &now
+}
+
+// Restore brings a deleted user back as active and clears the deletion
+// details
+func (u *User) Restore() error {
+	if !u.IsDeleted() {
+		return errors.New("user is not deleted")
+	}
+
+	u.Status = StatusActive
+	u.DeletedAt = gorm.DeletedAt{}
+	u.DeletionReason = ""
+	return nil
 }
 
 // ToResponse converts a User to a UserResponse
 func (u *User) ToResponse() *UserResponse {
-	return &UserResponse{
-		ID:          u.ID,
-		Username:    u.Username,
-		Email:       u.Email,
-		Name:        u.Name,
-		Age:         u.Age,
-		Role:        u.Role,
-		Status:      u.Status,
-		LastLogin:   u.LastLogin,
-		CreatedAt:   u.CreatedAt,
-		UpdatedAt:   u.UpdatedAt,
-		Permissions: u.Permissions,
-		Metadata:    u.Metadata,
+	resp := &UserResponse{
+		ID:               u.ID,
+		Username:         u.Username,
+		Email:            u.Email,
+		EmailVerified:    u.EmailVerified,
+		Name:             u.Name,
+		Age:              u.Age,
+		Role:             u.Role,
+		Status:           u.Status,
+		TwoFactorEnabled: u.TwoFactorEnabled,
+		LastLogin:        u.LastLogin,
+		LastSeenAt:       u.LastSeenAt,
+		CreatedAt:        u.CreatedAt,
+		UpdatedAt:        u.UpdatedAt,
+		Permissions:      u.Permissions,
+		Metadata:         u.Metadata,
+	}
+
+	// Keep the JSON shape stable: users without permissions or metadata
+	// get [] and {} rather than null
+	if resp.Permissions == nil {
+		resp.Permissions = []string{}
+	}
+	if resp.Metadata == nil {
+		resp.Metadata = map[string]interface{}{}
+	}
+
+	if u.IsDeleted() {
+		resp.Deleted = true
+		resp.DeletionReason = u.DeletionReason
+		if u.DeletedAt.Valid {
+			resp.DeletedAt = &u.DeletedAt.Time
+		}
+	}
+
+	return resp
+}
+
+// ToAdminResponse converts User to AdminUserResponse
+func (u *User) ToAdminResponse() *AdminUserResponse {
+	return &AdminUserResponse{UserResponse: u.ToResponse(), Shadowed: u.Shadowed}
+}
+
+// ToSummary converts User to UserSummary
+func (u *User) ToSummary() *UserSummary {
+	return &UserSummary{
+		ID:        u.ID,
+		Username:  u.Username,
+		Email:     u.Email,
+		Name:      u.Name,
+		Role:      u.Role,
+		Status:    u.Status,
+		LastLogin: u.LastLogin,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+
+		DeletionReason: u.DeletionReason,
 	}
 }
 
 // FromRequest creates a User from a UserRequest
 func (u *User) FromRequest(req *UserRequest) error {
-	u.Username = req.Username
-	u.Email = req.Email
+	u.Username = NormalizeUsername(req.Username)
+	u.Email = NormalizeEmail(req.Email)
 	u.Name = req.Name
 	u.Age = req.Age
-	u.Role = req.Role
+	if req.Role != "" {
+		u.Role = req.Role
+	}
 	u.Metadata = req.Metadata
 
 	if req.Password != "" {
@@ -251,8 +768,14 @@ func (u *User) MarshalJSON() ([]byte, error) {
 
 // Validate validates the user model
 func (u *User) Validate() error {
-	if len(u.Username) < 3 || len(u.Username) > 20 {
-		return errors.New("username must be between 3 and 20 characters")
+	if err := ValidateUsername(u.Username); err != nil {
+		return err
+	}
+
+	if u.Email != "" {
+		if err := utils.ValidateEmail(u.Email); err != nil {
+			return err
+		}
 	}
 
 	if len(u.Name) == 0 || len(u.Name) > 100 {
@@ -263,12 +786,18 @@ func (u *User) Validate() error {
 		return errors.New("age must be between 0 and 150")
 	}
 
-	if u.Role != RoleAdmin && u.Role != RoleUser && u.Role != RoleGuest {
+	if MinAge > 0 && u.Age < MinAge {
+		errs := utils.NewValidationErrors()
+		errs.Add("age", fmt.Sprintf("age must be at least %d", MinAge))
+		return errs
+	}
+
+	if !u.Role.Valid() {
 		return errors.New("invalid role")
 	}
 
 	if u.Status != StatusActive && u.Status != StatusInactive &&
-	   u.Status != StatusSuspended && u.Status != StatusDeleted {
+		u.Status != StatusSuspended && u.Status != StatusDeleted {
 		return errors.New("invalid status")
 	}
 
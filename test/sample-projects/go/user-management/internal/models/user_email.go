@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserEmail is one of a user's email addresses. Exactly one address per user
+// is primary, and it is mirrored into User.Email; the others are secondary
+// addresses that can also be used to log in once verified.
+type UserEmail struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	Email     string    `json:"email" gorm:"not null;uniqueIndex"`
+	IsPrimary bool      `json:"is_primary"`
+	Verified  bool      `json:"verified"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName returns the table name for GORM
+func (e *UserEmail) TableName() string {
+	return "user_emails"
+}
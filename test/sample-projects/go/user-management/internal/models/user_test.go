@@ -0,0 +1,139 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestAnonymizeUsernameIsValid(t *testing.T) {
+	tests := []struct {
+		name string
+		id   uuid.UUID
+	}{
+		{"zero id", uuid.Nil},
+		{"random id", uuid.New()},
+		{"all bits set", uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &User{ID: tt.id, Username: "someone"}
+			user.Anonymize()
+
+			if err := ValidateUsername(user.Username); err != nil {
+				t.Errorf("anonymized username %q is invalid: %v", user.Username, err)
+			}
+			if user.Username != AnonymizedUsername(tt.id) {
+				t.Errorf("Username = %q, want %q", user.Username, AnonymizedUsername(tt.id))
+			}
+		})
+	}
+}
+
+func TestTransitionTo(t *testing.T) {
+	statuses := []UserStatus{StatusActive, StatusInactive, StatusSuspended, StatusDeleted}
+
+	// allowed lists every permitted move between different statuses;
+	// staying in the same status is always allowed
+	allowed := map[UserStatus]map[UserStatus]bool{
+		StatusActive:    {StatusInactive: true, StatusSuspended: true, StatusDeleted: true},
+		StatusInactive:  {StatusActive: true, StatusSuspended: true, StatusDeleted: true},
+		StatusSuspended: {StatusActive: true, StatusInactive: true, StatusDeleted: true},
+		StatusDeleted:   {},
+	}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := from == to || allowed[from][to]
+			t.Run(string(from)+" to "+string(to), func(t *testing.T) {
+				user := &User{Status: from}
+				err := user.TransitionTo(to)
+
+				if want {
+					if err != nil {
+						t.Fatalf("TransitionTo(%s) = %v, want nil", to, err)
+					}
+					if user.Status != to {
+						t.Errorf("Status = %s, want %s", user.Status, to)
+					}
+					return
+				}
+
+				if err == nil {
+					t.Fatalf("TransitionTo(%s) = nil, want an error", to)
+				}
+				if user.Status != from {
+					t.Errorf("Status = %s after a refused transition, want %s", user.Status, from)
+				}
+			})
+		}
+	}
+}
+
+func TestTransitionToUnknownStatus(t *testing.T) {
+	user := &User{Status: StatusActive}
+	if err := user.TransitionTo("archived"); err == nil {
+		t.Fatal("TransitionTo(archived) = nil, want an error")
+	}
+	if user.Status != StatusActive {
+		t.Errorf("Status = %s, want %s", user.Status, StatusActive)
+	}
+}
+
+func TestStatusMethodsRefuseDeletedUsers(t *testing.T) {
+	tests := []struct {
+		name  string
+		apply func(*User) error
+	}{
+		{"activate", (*User).Activate},
+		{"deactivate", (*User).Deactivate},
+		{"suspend", (*User).Suspend},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &User{Status: StatusDeleted}
+			if err := tt.apply(user); err == nil {
+				t.Fatalf("%s on a deleted user = nil, want an error", tt.name)
+			}
+			if user.Status != StatusDeleted {
+				t.Errorf("Status = %s, want %s", user.Status, StatusDeleted)
+			}
+		})
+	}
+}
+
+func TestToResponseJSONShape(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions []string
+		metadata    map[string]interface{}
+	}{
+		{"nil", nil, nil},
+		{"empty", []string{}, map[string]interface{}{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &User{Permissions: tt.permissions, Metadata: tt.metadata}
+
+			data, err := json.Marshal(user.ToResponse())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(data, &fields); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := string(fields["permissions"]); got != "[]" {
+				t.Errorf("permissions = %s, want []", got)
+			}
+			if got := string(fields["metadata"]); got != "{}" {
+				t.Errorf("metadata = %s, want {}", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/example/user-management/internal/utils"
+)
+
+var (
+	// UsernamePattern, when set, is a regular expression every username
+	// must match in addition to the length limits
+	UsernamePattern *regexp.Regexp
+
+	// reservedUsernames holds lowercased names nobody may take
+	reservedUsernames = map[string]bool{}
+)
+
+// SetUsernamePolicy configures the username format pattern and the
+// reserved names, which are matched case-insensitively. An empty pattern
+// and no reserved names keep only the length check.
+func SetUsernamePolicy(pattern string, reserved []string) error {
+	var re *regexp.Regexp
+	if pattern != "" {
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid username pattern: %w", err)
+		}
+	}
+
+	names := make(map[string]bool, len(reserved))
+	for _, name := range reserved {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			names[name] = true
+		}
+	}
+
+	UsernamePattern = re
+	reservedUsernames = names
+	return nil
+}
+
+// ValidateUsername checks a username against the length limits and the
+// configured format policy. Failures are reported against the username
+// field.
+func ValidateUsername(username string) error {
+	errs := utils.NewValidationErrors()
+
+	switch {
+	case len(username) < 3 || len(username) > 20:
+		errs.Add("username", "username must be between 3 and 20 characters")
+	case UsernamePattern != nil && !UsernamePattern.MatchString(username):
+		errs.Add("username", fmt.Sprintf("username must match %s", UsernamePattern))
+	case reservedUsernames[strings.ToLower(username)]:
+		errs.Add("username", "username is reserved")
+	}
+
+	if errs.HasErrors() {
+		return errs
+	}
+	return nil
+}
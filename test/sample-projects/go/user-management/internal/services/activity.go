@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/google/uuid"
+)
+
+// lastSeenInterval is the minimum time between two last-seen writes for
+// the same user
+const lastSeenInterval = time.Minute
+
+// lastSeenWrites remembers when each user's last-seen time was last written
+var lastSeenWrites = &lastSeenThrottle{writes: make(map[uuid.UUID]time.Time)}
+
+// lastSeenThrottle limits last-seen writes to one per user per
+// lastSeenInterval. Entries older than the interval no longer throttle
+// anything and are swept out now and then, so the map only holds users
+// seen recently.
+type lastSeenThrottle struct {
+	mu        sync.Mutex
+	writes    map[uuid.UUID]time.Time
+	lastSweep time.Time
+}
+
+// allow reports whether a write for id is due at now, and if so records it
+func (t *lastSeenThrottle) allow(id uuid.UUID, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now.Sub(t.lastSweep) > lastSeenInterval {
+		for userID, last := range t.writes {
+			if now.Sub(last) >= lastSeenInterval {
+				delete(t.writes, userID)
+			}
+		}
+		t.lastSweep = now
+	}
+
+	if last, ok := t.writes[id]; ok && now.Sub(last) < lastSeenInterval {
+		return false
+	}
+	t.writes[id] = now
+	return true
+}
+
+// TouchLastSeen records that a user made an authenticated request. Writes
+// are throttled to one per user per minute, so calling it on every request
+// is cheap.
+func (s *UserService) TouchLastSeen(ctx context.Context, id uuid.UUID) error {
+	now := time.Now()
+	if !lastSeenWrites.allow(id, now) {
+		return nil
+	}
+
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	// UpdateColumn leaves updated_at alone; being seen is not a profile change
+	if err := db.Model(&models.User{}).Where("id = ?", id).UpdateColumn("last_seen_at", now).Error; err != nil {
+		return fmt.Errorf("failed to update last seen: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// anonymizeBatchSize is how many users one pass of AnonymizeInactiveBefore
+// looks up at a time
+const anonymizeBatchSize = 100
+
+// AnonymizeUser replaces a user's personal data with placeholders on behalf
+// of actorID, keeping the id and the fields statistics rely on. Deleted
+// users can be anonymized too. Along with the user row it removes the
+// user's extra email addresses and login history, revokes their sessions
+// and redacts their personal data from the audit trail. It is
+// idempotent: anonymizing an anonymized user does nothing and records no
+// second audit entry.
+func (s *UserService) AnonymizeUser(ctx context.Context, id, actorID uuid.UUID) error {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	var anonymized bool
+	err := s.transaction(db, func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+		if user.IsAnonymized() {
+			return nil
+		}
+
+		user.Anonymize()
+		if err := tx.Unscoped().Save(&user).Error; err != nil {
+			return fmt.Errorf("failed to anonymize user: %w", err)
+		}
+
+		if err := tx.Where("user_id = ?", id).Delete(&models.UserEmail{}).Error; err != nil {
+			return fmt.Errorf("failed to delete emails: %w", err)
+		}
+
+		if err := tx.Where("user_id = ?", id).Delete(&models.LoginEvent{}).Error; err != nil {
+			return fmt.Errorf("failed to delete login history: %w", err)
+		}
+
+		if err := revokeUserRefreshTokens(tx, id); err != nil {
+			return err
+		}
+
+		if err := redactAuditLogs(tx, id); err != nil {
+			return err
+		}
+
+		anonymized = true
+		return recordAudit(tx, actorID, AuditActionAnonymize, userResource(id), nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	if anonymized {
+		tokenVersions.invalidate(id)
+	}
+	return nil
+}
+
+// AnonymizeInactiveBefore anonymizes every user, other than admins, whose
+// last activity (last seen, else last login, else creation) is before
+// cutoff. Each user is anonymized in its own transaction and the work is
+// attributed to the system. It returns how many users were anonymized,
+// including those done before an error.
+func (s *UserService) AnonymizeInactiveBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	var anonymized int64
+	for {
+		ids, err := s.inactiveUserIDs(ctx, cutoff, anonymizeBatchSize)
+		if err != nil {
+			return anonymized, err
+		}
+
+		for _, id := range ids {
+			if err := s.AnonymizeUser(ctx, id, uuid.Nil); err != nil {
+				return anonymized, fmt.Errorf("failed to anonymize user %s: %w", id, err)
+			}
+			anonymized++
+		}
+
+		if len(ids) < anonymizeBatchSize {
+			return anonymized, nil
+		}
+	}
+}
+
+// inactiveUserIDs returns up to limit ids of users that AnonymizeInactiveBefore
+// should anonymize
+func (s *UserService) inactiveUserIDs(ctx context.Context, cutoff time.Time, limit int) ([]uuid.UUID, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	var ids []uuid.UUID
+	if err := db.Unscoped().Model(&models.User{}).
+		Where("anonymized_at IS NULL AND role <> ?", models.RoleAdmin).
+		Where("COALESCE(last_seen_at, last_login, created_at) < ?", cutoff).
+		Order("id").Limit(limit).Pluck("id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to find inactive users: %w", err)
+	}
+	return ids, nil
+}
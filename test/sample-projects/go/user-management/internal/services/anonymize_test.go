@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/utils"
+	"github.com/google/uuid"
+)
+
+// seedPersonalAudit gives alice audit entries holding her personal data:
+// a username change and an address she added, a failed login, and an
+// address an admin added for her. Entries alice made come from 10.0.0.1,
+// the admin's from 192.0.2.1.
+func seedPersonalAudit(t *testing.T, s *UserService, alice, admin *models.User) {
+	t.Helper()
+	ctx := context.Background()
+
+	username := "alicia"
+	if _, err := s.UpdateUser(ctx, alice.ID, alice.ID, models.UserUpdate{Username: &username}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddEmail(ctx, alice.ID, alice.ID, "alice@work.example.com", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddEmail(ctx, alice.ID, admin.ID, "alice@home.example.com", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordAudit(s.db, alice.ID, AuditActionLoginFailed, userResource(alice.ID), map[string]interface{}{
+		"ip": "10.0.0.1", "locked": false,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, ip := range map[uuid.UUID]string{alice.ID: "10.0.0.1", admin.ID: "192.0.2.1"} {
+		if err := s.db.Model(&utils.AuditLog{}).Where("user_id = ?", id).
+			Updates(map[string]interface{}{"ip_address": ip, "user_agent": "test-agent"}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkAuditRedacted fails the test if an audit entry about alice still
+// holds her personal data, or if the admin's own IP address was removed
+func checkAuditRedacted(t *testing.T, s *UserService, alice, admin *models.User) {
+	t.Helper()
+
+	var entries []utils.AuditLog
+	if err := s.db.Where("user_id = ? OR resource = ?", alice.ID, userResource(alice.ID)).Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) == 0 {
+		t.Fatal("no audit entries left about alice")
+	}
+
+	for _, entry := range entries {
+		details, err := json.Marshal(entry.Details)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, personal := range []string{"alice", "10.0.0.1"} {
+			if strings.Contains(string(details), personal) {
+				t.Errorf("%s entry details still hold %q: %s", entry.Action, personal, details)
+			}
+		}
+		if entry.UserID == alice.ID && (entry.IPAddress != "" || entry.UserAgent != "") {
+			t.Errorf("%s entry by alice keeps ip %q and user agent %q", entry.Action, entry.IPAddress, entry.UserAgent)
+		}
+		if entry.UserID == admin.ID && entry.Action == AuditActionEmailAdd && entry.IPAddress != "192.0.2.1" {
+			t.Errorf("%s entry by the admin lost its ip: %q", entry.Action, entry.IPAddress)
+		}
+	}
+}
+
+func TestAnonymizeUserRedactsAudit(t *testing.T) {
+	s := newTestService(t, utils.UserServiceConfig{})
+	alice := createTestUser(t, s, "alice")
+	admin := createTestUser(t, s, "admin")
+	seedPersonalAudit(t, s, alice, admin)
+
+	if err := s.AnonymizeUser(context.Background(), alice.ID, admin.ID); err != nil {
+		t.Fatal(err)
+	}
+	checkAuditRedacted(t, s, alice, admin)
+}
+
+func TestHardDeleteAnonymizesAudit(t *testing.T) {
+	s := newTestService(t, utils.UserServiceConfig{AuditOnHardDelete: utils.AuditRetentionAnonymize})
+	alice := createTestUser(t, s, "alice")
+	admin := createTestUser(t, s, "admin")
+	seedPersonalAudit(t, s, alice, admin)
+
+	if err := s.HardDeleteUser(context.Background(), alice.ID, admin.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	checkAuditRedacted(t, s, alice, admin)
+
+	var byAlice int64
+	if err := s.db.Model(&utils.AuditLog{}).Where("user_id = ?", alice.ID).Count(&byAlice).Error; err != nil {
+		t.Fatal(err)
+	}
+	if byAlice != 0 {
+		t.Errorf("%d audit entries still name alice as the actor", byAlice)
+	}
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/example/user-management/internal/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Audit actions
+const (
+	AuditActionCreate         = "user.create"
+	AuditActionUsernameChange = "user.username.change"
+	AuditActionBootstrapAdmin = "user.bootstrap_admin"
+
+	AuditActionActivate   = "user.activate"
+	AuditActionDeactivate = "user.deactivate"
+	AuditActionSuspend    = "user.suspend"
+	AuditActionUnlock     = "user.unlock"
+	AuditActionShadow     = "user.shadow"
+	AuditActionUnshadow   = "user.unshadow"
+	AuditActionRestore    = "user.restore"
+	AuditActionBulkStatus = "user.status.bulk"
+
+	AuditActionSetPermissions       = "user.permissions.set"
+	AuditActionBulkGrantPermissions = "user.permissions.bulk_grant"
+
+	AuditActionPasswordChange       = "user.password.change"
+	AuditActionPasswordChangeFailed = "user.password.change_failed"
+	AuditActionPasswordReset        = "user.password.reset"
+
+	AuditActionLogoutAll = "user.sessions.revoke_all"
+
+	AuditActionImpersonationStart   = "user.impersonation.start"
+	AuditActionImpersonationRequest = "user.impersonation.request"
+
+	AuditActionTwoFactorEnable     = "user.two_factor.enable"
+	AuditActionTwoFactorDisable    = "user.two_factor.disable"
+	AuditActionBackupCodesGenerate = "user.backup_codes.generate"
+	AuditActionBackupCodeUse       = "user.backup_codes.use"
+
+	AuditActionEmailAdd        = "user.email.add"
+	AuditActionEmailRemove     = "user.email.remove"
+	AuditActionEmailSetPrimary = "user.email.set_primary"
+
+	AuditActionDelete      = "user.delete"
+	AuditActionPurge       = "user.purge"
+	AuditActionAnonymize   = "user.anonymize"
+	AuditActionLoginFailed = "user.login_failed"
+
+	AuditActionReadOnlySet = "system.read_only.set"
+)
+
+// SubscribeAuditLog records audit entries for published events that are not
+// already audited in the transaction making the change
+func SubscribeAuditLog(bus *EventBus, db *gorm.DB) {
+	Subscribe(bus, func(ctx context.Context, e LoginFailed) error {
+		if e.UserID == uuid.Nil {
+			return nil
+		}
+		return recordAudit(db.WithContext(ctx), e.UserID, AuditActionLoginFailed, userResource(e.UserID), map[string]interface{}{
+			"ip":     e.ClientIP,
+			"locked": e.Locked,
+		})
+	})
+	Subscribe(bus, func(ctx context.Context, e UserDeleted) error {
+		// Hard deletes purge the audit trail themselves
+		if e.Hard {
+			return nil
+		}
+		var details map[string]interface{}
+		if e.Reason != "" {
+			details = map[string]interface{}{"reason": e.Reason}
+		}
+		return recordAudit(db.WithContext(ctx), uuid.Nil, AuditActionDelete, userResource(e.UserID), details)
+	})
+}
+
+// recordAudit writes an audit log entry using the given database handle,
+// which may be a transaction
+func recordAudit(db *gorm.DB, actorID uuid.UUID, action, resource string, details map[string]interface{}) error {
+	entry := &utils.AuditLog{
+		ID:       uuid.New(),
+		UserID:   actorID,
+		Action:   action,
+		Resource: resource,
+		Details:  details,
+	}
+
+	if err := db.Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+
+	logger.Debug("audit", "action", action, "resource", resource, "actor_id", actorID)
+	return nil
+}
+
+// auditPersonalDetails are the audit detail keys whose values are a user's
+// personal data: addresses, usernames and the IP of a failed login
+var auditPersonalDetails = []string{"email", "old_email", "new_email", "old_username", "new_username", "ip"}
+
+// auditRedacted replaces redacted audit detail values
+const auditRedacted = "[redacted]"
+
+// redactAuditLogs removes a user's personal data from the audit trail
+// while keeping the entries. Entries the user made lose their IP address
+// and user agent, and the personal details of those entries and of the
+// entries about the user are replaced with auditRedacted.
+func redactAuditLogs(tx *gorm.DB, id uuid.UUID) error {
+	var entries []*utils.AuditLog
+	result := tx.Where("user_id = ? OR resource = ?", id, userResource(id)).
+		FindInBatches(&entries, 500, func(batch *gorm.DB, _ int) error {
+			for _, entry := range entries {
+				changed := false
+				if entry.UserID == id && (entry.IPAddress != "" || entry.UserAgent != "") {
+					entry.IPAddress, entry.UserAgent = "", ""
+					changed = true
+				}
+				for _, key := range auditPersonalDetails {
+					if value, ok := entry.Details[key]; ok && value != auditRedacted {
+						entry.Details[key] = auditRedacted
+						changed = true
+					}
+				}
+				if !changed {
+					continue
+				}
+
+				if err := tx.Model(entry).Select("IPAddress", "UserAgent", "Details").Updates(entry).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to redact audit logs: %w", result.Error)
+	}
+	return nil
+}
+
+// userResource returns the audit resource identifier for a user
+func userResource(id uuid.UUID) string {
+	return "users/" + id.String()
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/utils"
+)
+
+// countingHasher counts the password comparisons made through it
+type countingHasher struct {
+	models.Hasher
+	verified int
+}
+
+func (h *countingHasher) Verify(hash, password string) bool {
+	h.verified++
+	return h.Hasher.Verify(hash, password)
+}
+
+func TestAuthenticateComparesOneHashPerAttempt(t *testing.T) {
+	ctx := context.Background()
+
+	hasher := &countingHasher{Hasher: models.BcryptHasher{}}
+	previous := models.PasswordHasher
+	models.PasswordHasher = hasher
+	models.PrepareDummyHash()
+	t.Cleanup(func() {
+		models.PasswordHasher = previous
+		models.PrepareDummyHash()
+	})
+
+	s := newTestService(t, utils.UserServiceConfig{})
+	createTestUser(t, s, "alice")
+	inactive := createTestUser(t, s, "bob")
+	if _, err := s.DeactivateUser(ctx, inactive.ID, inactive.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		ok       bool
+	}{
+		{"unknown user", "nobody", "password123", false},
+		{"wrong password", "alice", "wrong-password", false},
+		{"inactive user", "bob", "password123", false},
+		{"success", "alice", "password123", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasher.verified = 0
+
+			_, err := s.AuthenticateUser(ctx, tt.username, tt.password, SecondFactor{}, "127.0.0.1")
+			if (err == nil) != tt.ok {
+				t.Errorf("AuthenticateUser = %v, want success: %t", err, tt.ok)
+			}
+			if hasher.verified != 1 {
+				t.Errorf("password comparisons = %d, want 1", hasher.verified)
+			}
+		})
+	}
+}
+
+func TestAuthenticateHidesWhichAccountsExist(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, utils.UserServiceConfig{})
+	createTestUser(t, s, "alice")
+
+	_, unknownErr := s.AuthenticateUser(ctx, "nobody", "password123", SecondFactor{}, "127.0.0.1")
+	_, wrongErr := s.AuthenticateUser(ctx, "alice", "wrong-password", SecondFactor{}, "127.0.0.1")
+
+	if !errors.Is(unknownErr, ErrInvalidCredentials) || !errors.Is(wrongErr, ErrInvalidCredentials) {
+		t.Fatalf("AuthenticateUser errors = %v, %v; want %v for both", unknownErr, wrongErr, ErrInvalidCredentials)
+	}
+	if unknownErr.Error() != wrongErr.Error() {
+		t.Errorf("unknown user error %q differs from wrong password error %q", unknownErr, wrongErr)
+	}
+}
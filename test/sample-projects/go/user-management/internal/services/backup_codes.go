@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RegenerateBackupCodes replaces a user's backup codes after confirming
+// their password. The user must have two-factor authentication enabled.
+// The plaintext codes are returned once and cannot be retrieved again; any
+// previously issued codes stop working.
+func (s *UserService) RegenerateBackupCodes(ctx context.Context, id uuid.UUID, password string) ([]string, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	user, err := s.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.VerifyPassword(password) {
+		return nil, validationError(errors.New("password is incorrect"))
+	}
+	if !user.TwoFactorEnabled {
+		return nil, validationError(errors.New("two-factor authentication is not enabled"))
+	}
+
+	codes, err := user.GenerateBackupCodes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate backup codes: %w", err)
+	}
+
+	err = s.transaction(db, func(tx *gorm.DB) error {
+		if err := tx.Model(user).Select("BackupCodes").Updates(user).Error; err != nil {
+			return fmt.Errorf("failed to save backup codes: %w", err)
+		}
+
+		return recordAudit(tx, user.ID, AuditActionBackupCodesGenerate, userResource(user.ID), map[string]interface{}{
+			"count": len(codes),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// consumeBackupCode verifies a backup code for the user and removes it so it
+// cannot be used again, returning the codes left. The user's row is locked
+// so two logins racing with the same code can't both spend it. It returns
+// ErrInvalidBackupCode if the code does not match any remaining code.
+func (s *UserService) consumeBackupCode(db *gorm.DB, id uuid.UUID, code string) ([]string, error) {
+	var remaining []string
+	err := s.transaction(db, func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+
+		if !user.ConsumeBackupCode(code) {
+			return ErrInvalidBackupCode
+		}
+
+		if err := tx.Model(&user).Select("BackupCodes").Updates(&user).Error; err != nil {
+			return fmt.Errorf("failed to save backup codes: %w", err)
+		}
+		remaining = user.BackupCodes
+
+		return recordAudit(tx, user.ID, AuditActionBackupCodeUse, userResource(user.ID), map[string]interface{}{
+			"remaining": len(user.BackupCodes),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return remaining, nil
+}
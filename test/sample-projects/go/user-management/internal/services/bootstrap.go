@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/example/user-management/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// flagAdminBootstrapped is claimed when the first user is made an admin
+const flagAdminBootstrapped = "admin_bootstrapped"
+
+// BootstrapAdmin creates req as the bootstrap admin if the database has no
+// users yet. It returns a nil user when an admin was already bootstrapped.
+func (s *UserService) BootstrapAdmin(ctx context.Context, req *models.UserRequest) (*models.User, error) {
+	return s.createFirstUser(ctx, req, true)
+}
+
+// createFirstUser creates a user like CreateUser, except that the first user
+// on an empty database is made an admin. With adminOnly, nothing is created
+// unless the user becomes admin. The grant happens at most once: it claims a
+// system flag in the same transaction as the insert, so of two concurrent
+// first creates only one becomes admin, and a failed create releases the
+// claim.
+func (s *UserService) createFirstUser(ctx context.Context, req *models.UserRequest, adminOnly bool) (*models.User, error) {
+	db, cancel := s.withContext(ctx)
+	done, err := adminBootstrapped(db)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	if done {
+		if adminOnly {
+			return nil, nil
+		}
+		return s.createUserDefault(ctx, req)
+	}
+
+	var user *models.User
+
+	err = s.WithTx(ctx, func(txService *UserService) error {
+		admin, err := claimBootstrapAdmin(txService.db)
+		if err != nil {
+			return err
+		}
+		if adminOnly && !admin {
+			return nil
+		}
+
+		user, err = txService.createUser(txService.db, req, func(u *models.User) error {
+			if admin {
+				u.Role = models.RoleAdmin
+				for _, permission := range txService.rolePermissions[models.RoleAdmin] {
+					u.AddPermission(permission)
+				}
+			}
+			return nil
+		})
+		if err != nil || !admin {
+			return err
+		}
+
+		return recordAudit(txService.db, user.ID, AuditActionBootstrapAdmin, userResource(user.ID), nil)
+	})
+	if err != nil || user == nil {
+		return nil, err
+	}
+
+	s.publish(ctx, UserCreated{UserID: user.ID})
+	return user, nil
+}
+
+// claimBootstrapAdmin reports whether the user about to be created should
+// become the bootstrap admin: the bootstrap flag has not been claimed yet
+// and the users table, including deleted users, is empty. Once users exist
+// the flag is claimed anyway, so bootstrapping never happens later.
+func claimBootstrapAdmin(db *gorm.DB) (bool, error) {
+	// The flag is written before users are counted so the transaction
+	// takes the write lock up front rather than upgrading a read lock
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.SystemFlag{Key: flagAdminBootstrapped})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to claim admin bootstrap: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	var count int64
+	if err := db.Unscoped().Model(&models.User{}).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to count users: %w", err)
+	}
+	return count == 0, nil
+}
+
+// adminBootstrapped reports whether the bootstrap flag has been claimed
+func adminBootstrapped(db *gorm.DB) (bool, error) {
+	var count int64
+	if err := db.Model(&models.SystemFlag{}).Where("key = ?", flagAdminBootstrapped).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check admin bootstrap: %w", err)
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// defaultQueryTimeout bounds database work when no timeout is configured
+const defaultQueryTimeout = 10 * time.Second
+
+// withTimeout binds db to ctx with a statement timeout. A shorter deadline
+// already carried by ctx, such as a cancelled HTTP request, still applies.
+func withTimeout(ctx context.Context, db *gorm.DB, timeout time.Duration) (*gorm.DB, context.CancelFunc) {
+	if timeout <= 0 {
+		timeout = defaultQueryTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return db.WithContext(ctx), cancel
+}
+
+// withContext returns a database handle bound to ctx and the configured
+// query timeout
+func (s *UserService) withContext(ctx context.Context) (*gorm.DB, context.CancelFunc) {
+	return withTimeout(ctx, s.db, time.Duration(s.config.QueryTimeout)*time.Second)
+}
+
+// withContext returns a database handle bound to ctx and the default
+// query timeout
+func (s *TokenService) withContext(ctx context.Context) (*gorm.DB, context.CancelFunc) {
+	return withTimeout(ctx, s.db, defaultQueryTimeout)
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Domain errors returned by the services. Callers should match them with
+// errors.Is; they are usually wrapped with more context.
+var (
+	ErrUserNotFound      = errors.New("user not found")
+	ErrEmailNotFound     = errors.New("email not found")
+	ErrDuplicateUsername = errors.New("username already exists")
+	ErrDuplicateEmail    = errors.New("email already exists")
+	ErrDuplicateMetadata = errors.New("metadata value already exists")
+	ErrValidation        = errors.New("validation failed")
+
+	ErrPermissionNotAllowed = errors.New("permission not allowed for role")
+	ErrUnknownPermission    = errors.New("permission not in catalog")
+
+	// ErrInvalidCredentials is returned for both unknown users and wrong
+	// passwords so the two cases can't be told apart
+	ErrInvalidCredentials = errors.New("invalid username or password")
+
+	// ErrInvalidBackupCode is returned when a backup code is unknown or has
+	// already been used
+	ErrInvalidBackupCode = errors.New("invalid backup code")
+
+	// ErrInvalidTwoFactorCode is returned when an authenticator code is
+	// wrong, expired or was already used
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+
+	// ErrTwoFactorRequired is returned when the password is right but the
+	// user has two-factor authentication enabled and the login included
+	// neither an authenticator code nor a backup code
+	ErrTwoFactorRequired = errors.New("two-factor code required")
+
+	// Session expiry errors let clients tell an idle timeout from the
+	// maximum session lifetime being reached
+	ErrSessionIdleExpired     = errors.New("session expired due to inactivity")
+	ErrSessionAbsoluteExpired = errors.New("session reached its maximum lifetime")
+
+	// ErrSessionLimitReached is returned when a user already has the
+	// maximum number of active sessions and the limit policy is reject
+	ErrSessionLimitReached = errors.New("maximum number of active sessions reached")
+)
+
+// validationError marks err as a validation failure while keeping its message
+func validationError(err error) error {
+	return fmt.Errorf("%w: %w", ErrValidation, err)
+}
+
+// duplicateError translates a unique constraint violation on an identity
+// column into ErrDuplicateUsername, ErrDuplicateEmail or
+// ErrDuplicateMetadata. It returns nil if err is not such a violation.
+func duplicateError(err error) error {
+	if key := duplicateMetadataKey(err); key != "" {
+		return fmt.Errorf("%w: %s", ErrDuplicateMetadata, key)
+	}
+
+	switch duplicateField(err) {
+	case "email":
+		return ErrDuplicateEmail
+	case "username":
+		return ErrDuplicateUsername
+	}
+	return nil
+}
+
+// duplicateField reports which identity column a unique constraint
+// violation refers to ("username" or "email"), or "" if err is not one
+func duplicateField(err error) string {
+	msg := strings.ToLower(err.Error())
+	if !strings.Contains(msg, "unique") && !strings.Contains(msg, "duplicate") {
+		return ""
+	}
+
+	switch {
+	case strings.Contains(msg, "email"):
+		return "email"
+	case strings.Contains(msg, "username"):
+		return "username"
+	}
+
+	return ""
+}
+
+// duplicateMetadataKey reports which unique metadata index a constraint
+// violation refers to, or "" if err is not one
+func duplicateMetadataKey(err error) string {
+	msg := err.Error()
+	i := strings.Index(msg, metadataIndexPrefix)
+	if i < 0 {
+		return ""
+	}
+
+	name := msg[i+len(metadataIndexPrefix):]
+	if end := strings.IndexAny(name, `"' `); end >= 0 {
+		name = name[:end]
+	}
+	return name
+}
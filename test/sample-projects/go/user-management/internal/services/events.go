@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Event types
+const (
+	EventUserCreated    = "user.created"
+	EventUserDeleted    = "user.deleted"
+	EventUserSuspended  = "user.suspended"
+	EventLoginSucceeded = "user.login"
+	EventLoginFailed    = "user.login_failed"
+)
+
+// Event is something that happened to a user. UserService publishes events
+// after the change is committed; side effects such as webhooks, metrics and
+// extra audit entries subscribe to them.
+type Event interface {
+	EventType() string
+}
+
+// UserCreated is published when a user is created
+type UserCreated struct {
+	UserID uuid.UUID
+}
+
+// UserDeleted is published when a user is deleted. Hard is set for
+// permanent deletions; Reason is the reason given for a soft delete.
+type UserDeleted struct {
+	UserID uuid.UUID
+	Hard   bool
+	Reason string
+}
+
+// UserSuspended is published when an admin suspends a user
+type UserSuspended struct {
+	UserID  uuid.UUID
+	ActorID uuid.UUID
+}
+
+// LoginSucceeded is published after a successful login
+type LoginSucceeded struct {
+	UserID    uuid.UUID
+	ClientIP  string
+	UserAgent string
+}
+
+// LoginFailed is published after a rejected login. UserID is uuid.Nil when
+// the username is unknown; Locked is set if this attempt locked the account.
+type LoginFailed struct {
+	UserID    uuid.UUID
+	Username  string
+	ClientIP  string
+	UserAgent string
+	Locked    bool
+}
+
+func (UserCreated) EventType() string    { return EventUserCreated }
+func (UserDeleted) EventType() string    { return EventUserDeleted }
+func (UserSuspended) EventType() string  { return EventUserSuspended }
+func (LoginSucceeded) EventType() string { return EventLoginSucceeded }
+func (LoginFailed) EventType() string    { return EventLoginFailed }
+
+// EventBus delivers published events to subscribers in subscription order.
+// A synchronous bus runs subscribers before Publish returns and joins their
+// errors; an asynchronous bus runs them in the background, one event at a
+// time in publish order, and logs errors.
+type EventBus struct {
+	mu       sync.RWMutex
+	handlers map[string][]func(context.Context, Event) error
+
+	queue chan publishedEvent
+}
+
+type publishedEvent struct {
+	ctx   context.Context
+	event Event
+}
+
+// asyncQueueSize bounds how many events an asynchronous bus buffers before
+// Publish blocks
+const asyncQueueSize = 1024
+
+// NewEventBus creates an event bus, asynchronous if async is set
+func NewEventBus(async bool) *EventBus {
+	bus := &EventBus{handlers: make(map[string][]func(context.Context, Event) error)}
+	if async {
+		bus.queue = make(chan publishedEvent, asyncQueueSize)
+		go bus.run()
+	}
+	return bus
+}
+
+// Subscribe registers handler for events of type E
+func Subscribe[E Event](bus *EventBus, handler func(ctx context.Context, event E) error) {
+	var zero E
+	eventType := zero.EventType()
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	bus.handlers[eventType] = append(bus.handlers[eventType], func(ctx context.Context, event Event) error {
+		return handler(ctx, event.(E))
+	})
+}
+
+// Publish delivers event to its subscribers. Only a synchronous bus returns
+// subscriber errors.
+func (b *EventBus) Publish(ctx context.Context, event Event) error {
+	if b.queue != nil {
+		// Subscribers outlive the request that triggered the event
+		b.queue <- publishedEvent{ctx: context.WithoutCancel(ctx), event: event}
+		return nil
+	}
+
+	return b.dispatch(ctx, event)
+}
+
+// run delivers queued events of an asynchronous bus
+func (b *EventBus) run() {
+	for published := range b.queue {
+		if err := b.dispatch(published.ctx, published.event); err != nil {
+			logger.Warn("event subscribers failed", "event", published.event.EventType(), "error", err)
+		}
+	}
+}
+
+// dispatch runs every subscriber of event, even after one fails, and joins
+// their errors
+func (b *EventBus) dispatch(ctx context.Context, event Event) error {
+	b.mu.RLock()
+	handlers := b.handlers[event.EventType()]
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
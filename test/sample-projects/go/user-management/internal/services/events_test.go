@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/example/user-management/internal/utils"
+	"github.com/google/uuid"
+)
+
+func TestEventBusSubscriberOrder(t *testing.T) {
+	errFailed := errors.New("subscriber failed")
+
+	tests := []struct {
+		name    string
+		failing int // index of a subscriber that fails, -1 for none
+	}{
+		{"all succeed", -1},
+		{"first fails", 0},
+		{"middle fails", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := NewEventBus(false)
+
+			var calls []int
+			for i := 0; i < 3; i++ {
+				Subscribe(bus, func(_ context.Context, _ UserCreated) error {
+					calls = append(calls, i)
+					if i == tt.failing {
+						return errFailed
+					}
+					return nil
+				})
+			}
+
+			err := bus.Publish(context.Background(), UserCreated{UserID: uuid.New()})
+			if want := tt.failing >= 0; errors.Is(err, errFailed) != want {
+				t.Errorf("Publish = %v, want subscriber error: %t", err, want)
+			}
+			if want := []int{0, 1, 2}; !slices.Equal(calls, want) {
+				t.Errorf("subscribers ran in order %v, want %v", calls, want)
+			}
+		})
+	}
+}
+
+func TestEventBusAsyncPublishOrder(t *testing.T) {
+	bus := NewEventBus(true)
+
+	const events = 100
+	received := make(chan uuid.UUID, events)
+	Subscribe(bus, func(_ context.Context, e UserCreated) error {
+		received <- e.UserID
+		return nil
+	})
+
+	published := make([]uuid.UUID, events)
+	for i := range published {
+		published[i] = uuid.New()
+		if err := bus.Publish(context.Background(), UserCreated{UserID: published[i]}); err != nil {
+			t.Fatalf("Publish = %v, want nil", err)
+		}
+	}
+
+	for i, want := range published {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Fatalf("event %d delivered out of order: got %s, want %s", i, got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for event %d", i)
+		}
+	}
+}
+
+func TestUserServiceEventOrder(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, utils.UserServiceConfig{})
+
+	bus := NewEventBus(false)
+	var types []string
+	record := func(_ context.Context, e Event) error {
+		types = append(types, e.EventType())
+		return nil
+	}
+	Subscribe(bus, func(ctx context.Context, e UserCreated) error { return record(ctx, e) })
+	Subscribe(bus, func(ctx context.Context, e UserSuspended) error { return record(ctx, e) })
+	Subscribe(bus, func(ctx context.Context, e UserDeleted) error { return record(ctx, e) })
+	Subscribe(bus, func(ctx context.Context, e LoginFailed) error { return record(ctx, e) })
+	s.SetEventBus(bus)
+
+	user := createTestUser(t, s, "alice")
+	if _, err := s.AuthenticateUser(ctx, "alice", "wrong-password", SecondFactor{}, "127.0.0.1"); err == nil {
+		t.Fatal("AuthenticateUser with a wrong password succeeded")
+	}
+	if _, err := s.SuspendUser(ctx, user.ID, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteUser(ctx, user.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{EventUserCreated, EventLoginFailed, EventUserSuspended, EventUserDeleted}
+	if !slices.Equal(types, want) {
+		t.Errorf("events = %v, want %v", types, want)
+	}
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/example/user-management/internal/models"
+)
+
+// exportFields are the fields of an exported user, named as in the JSON
+// user response. Any of them may be redacted from an export.
+var exportFields = []string{
+	"id", "username", "email", "email_verified", "name", "age", "role", "status",
+	"last_login", "last_seen_at", "created_at", "updated_at", "permissions", "metadata",
+	"deleted", "deleted_at", "deletion_reason",
+}
+
+// exportRedaction checks the fields an export was asked to redact and
+// returns them as a set. Unknown fields are a validation error, so a typo
+// can't silently leak the field it meant to strip.
+func exportRedaction(redact []string) (map[string]bool, error) {
+	redacted := make(map[string]bool, len(redact))
+	for _, field := range redact {
+		if !isExportField(field) {
+			return nil, validationError(fmt.Errorf("invalid redact field: %s", field))
+		}
+		redacted[field] = true
+	}
+	return redacted, nil
+}
+
+// isExportField checks if a field may be redacted from an export
+func isExportField(field string) bool {
+	for _, f := range exportFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// redactResponse drops the redacted fields from a user response. Without
+// redactions the response is returned as is.
+func redactResponse(response *models.UserResponse, redacted map[string]bool) (interface{}, error) {
+	if len(redacted) == 0 {
+		return response, nil
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for field := range redacted {
+		delete(fields, field)
+	}
+	return fields, nil
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/example/user-management/internal/utils"
+	"github.com/google/uuid"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestExportUsersXLSXBatches(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, utils.UserServiceConfig{})
+
+	// More than two batches, with every other user sharing one creation
+	// time so batches have to break ties on the random ids
+	users := seedUsers(t, s, 2*exportXLSXBatchSize+7)
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < len(users); i += 2 {
+		if err := s.db.Model(users[i]).Update("created_at", createdAt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := s.ExportUsersXLSX(ctx, &buf, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := file.GetRows(exportXLSXSheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []uuid.UUID
+	for _, row := range rows[1:] {
+		id, err := uuid.Parse(row[0])
+		if err != nil {
+			t.Fatalf("row %v: %v", row, err)
+		}
+		ids = append(ids, id)
+	}
+	checkPagedOnce(t, ids, users)
+}
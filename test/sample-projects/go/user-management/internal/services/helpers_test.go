@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/utils"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	// Hashing at the default cost would dominate every test that creates
+	// a user
+	models.PasswordCost = bcrypt.MinCost
+	os.Exit(m.Run())
+}
+
+// newTestDB opens a migrated SQLite database in the test's temporary
+// directory
+func newTestDB(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "users.db")
+	db, err := gorm.Open(sqlite.Open(path+"?_busy_timeout=5000"), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := RunMigrations(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	return db
+}
+
+// newTestService returns a UserService over a fresh database
+func newTestService(t testing.TB, config utils.UserServiceConfig) *UserService {
+	t.Helper()
+	return NewUserService(newTestDB(t), config)
+}
+
+// createTestUser creates an active user with the password "password123"
+func createTestUser(t testing.TB, s *UserService, username string) *models.User {
+	t.Helper()
+
+	user, err := s.CreateUser(context.Background(), &models.UserRequest{
+		Username: username,
+		Email:    username + "@example.com",
+		Name:     "Test User",
+		Age:      30,
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+	return user
+}
+
+// seedUsers inserts n active users named user00000 upwards, in batches and
+// sharing one password hash, for tests that need more users than
+// createTestUser makes quickly
+func seedUsers(t testing.TB, s *UserService, n int) []*models.User {
+	t.Helper()
+
+	hash, err := models.PasswordHasher.Hash("password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := make([]*models.User, n)
+	for i := range users {
+		username := fmt.Sprintf("user%05d", i)
+		users[i] = &models.User{
+			Username:     username,
+			Email:        username + "@example.com",
+			Name:         fmt.Sprintf("Test User %d", i),
+			Age:          18 + i%60,
+			PasswordHash: hash,
+			Role:         models.RoleUser,
+			Status:       models.StatusActive,
+		}
+	}
+	if err := s.db.CreateInBatches(users, 500).Error; err != nil {
+		t.Fatalf("seed users: %v", err)
+	}
+	return users
+}
+
+// pageThrough fetches pages of pageSize users until total users have been
+// seen or a page comes back short, and returns their ids in order
+func pageThrough(t testing.TB, pageSize int, fetch func(page, pageSize int) ([]*models.User, int64, error)) []uuid.UUID {
+	t.Helper()
+
+	var ids []uuid.UUID
+	for page := 1; ; page++ {
+		users, total, err := fetch(page, pageSize)
+		if err != nil {
+			t.Fatalf("page %d: %v", page, err)
+		}
+		for _, user := range users {
+			ids = append(ids, user.ID)
+		}
+		if len(users) < pageSize || int64(len(ids)) >= total {
+			return ids
+		}
+	}
+}
+
+// checkPagedOnce fails the test unless ids holds every id of want exactly
+// once
+func checkPagedOnce(t testing.TB, ids []uuid.UUID, want []*models.User) {
+	t.Helper()
+
+	seen := make(map[uuid.UUID]int, len(ids))
+	for _, id := range ids {
+		seen[id]++
+	}
+	for _, user := range want {
+		if n := seen[user.ID]; n != 1 {
+			t.Errorf("user %s (%s) appeared %d times across pages, want 1", user.Username, user.ID, n)
+		}
+	}
+	if len(ids) != len(want) {
+		t.Errorf("paged through %d users, want %d", len(ids), len(want))
+	}
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/example/user-management/internal/models"
+	"gorm.io/gorm"
+)
+
+// defaultIdempotencyWindow is how long idempotency keys are kept when no
+// window is configured
+const defaultIdempotencyWindow = 24 * time.Hour
+
+// CreateUserIdempotent creates a user once per idempotency key. Replaying a
+// key within the configured window returns the originally created user and
+// reports replayed as true instead of creating another one. The user and
+// the key are stored in one transaction, so a failure leaves neither
+// behind and a retry with the same key creates the user afresh.
+func (s *UserService) CreateUserIdempotent(ctx context.Context, key string, req *models.UserRequest) (user *models.User, replayed bool, err error) {
+	hash := idempotencyRequestHash(req)
+
+	if user, err := s.replayIdempotencyKey(ctx, key, hash); err != nil || user != nil {
+		return user, user != nil, err
+	}
+
+	err = s.WithTx(ctx, func(txService *UserService) error {
+		// UserCreated is published once the transaction has committed
+		quiet := *txService
+		quiet.events = nil
+
+		var err error
+		user, err = quiet.CreateUser(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		record := &models.IdempotencyKey{
+			Key:         key,
+			RequestHash: hash,
+			UserID:      user.ID,
+			ExpiresAt:   time.Now().Add(s.idempotencyWindow()),
+		}
+		if err := txService.db.Create(record).Error; err != nil {
+			return fmt.Errorf("failed to store idempotency key: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		// A concurrent request with the same key may have won the race
+		if replay, replayErr := s.replayIdempotencyKey(ctx, key, hash); replayErr == nil && replay != nil {
+			return replay, true, nil
+		}
+		return nil, false, err
+	}
+
+	s.publish(ctx, UserCreated{UserID: user.ID})
+	return user, false, nil
+}
+
+// replayIdempotencyKey returns the user previously created for key, or nil
+// if the key is unknown or expired
+func (s *UserService) replayIdempotencyKey(ctx context.Context, key, hash string) (*models.User, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	if err := db.Where("expires_at <= ?", time.Now()).Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return nil, fmt.Errorf("failed to purge expired idempotency keys: %w", err)
+	}
+
+	var record models.IdempotencyKey
+	if err := db.Where(&models.IdempotencyKey{Key: key}).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	if record.RequestHash != hash {
+		return nil, validationError(errors.New("idempotency key was already used for a different request"))
+	}
+
+	return s.GetUserByID(ctx, record.UserID)
+}
+
+// idempotencyWindow returns how long idempotency keys are honoured
+func (s *UserService) idempotencyWindow() time.Duration {
+	if s.config.IdempotencyWindow <= 0 {
+		return defaultIdempotencyWindow
+	}
+	return time.Duration(s.config.IdempotencyWindow) * time.Hour
+}
+
+// idempotencyRequestHash fingerprints the identifying fields of a create
+// request so a key cannot be replayed for a different user
+func idempotencyRequestHash(req *models.UserRequest) string {
+	sum := sha256.Sum256([]byte(models.NormalizeUsername(req.Username) + "\x00" + models.NormalizeEmail(req.Email)))
+	return hex.EncodeToString(sum[:])
+}
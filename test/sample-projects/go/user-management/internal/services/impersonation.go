@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/google/uuid"
+)
+
+// ImpersonationTarget returns the user adminID wants to act as after
+// checking that impersonation is allowed: the user must be active and
+// must not be the admin themselves or another admin.
+func (s *UserService) ImpersonationTarget(ctx context.Context, id, adminID uuid.UUID) (*models.User, error) {
+	if id == adminID {
+		return nil, validationError(errors.New("cannot impersonate yourself"))
+	}
+
+	user, err := s.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.IsAdmin() {
+		return nil, validationError(errors.New("admins cannot be impersonated"))
+	}
+	if !user.IsActive() {
+		return nil, validationError(errors.New("only active users can be impersonated"))
+	}
+	return user, nil
+}
+
+// RecordImpersonationStart audits that adminID was issued the token tokenID
+// to act as the user id until expiresAt
+func (s *UserService) RecordImpersonationStart(ctx context.Context, id, adminID uuid.UUID, tokenID string, expiresAt time.Time) error {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	return recordAudit(db, adminID, AuditActionImpersonationStart, userResource(id), map[string]interface{}{
+		"token_id":   tokenID,
+		"expires_at": expiresAt,
+	})
+}
+
+// RecordImpersonatedRequest audits a request adminID made while acting as
+// the user id
+func (s *UserService) RecordImpersonatedRequest(ctx context.Context, id, adminID uuid.UUID, details map[string]interface{}) error {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	return recordAudit(db, adminID, AuditActionImpersonationRequest, userResource(id), details)
+}
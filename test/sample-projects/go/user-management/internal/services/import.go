@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/example/user-management/internal/models"
+)
+
+// errImportRollback aborts the import transaction once results are recorded
+var errImportRollback = errors.New("import rolled back")
+
+// ImportUsers creates users in a single transaction. Each row goes through
+// the same validation and duplicate checks as CreateUser, including against
+// earlier rows of the batch. If any row fails nothing is persisted. With
+// dryRun the transaction is always rolled back, so the report shows what a
+// real import would do without writing anything.
+func (s *UserService) ImportUsers(ctx context.Context, reqs []*models.UserRequest, dryRun bool) (*models.ImportReport, error) {
+	var report *models.ImportReport
+
+	err := s.WithTx(ctx, func(txService *UserService) error {
+		tx := txService.db
+
+		report = &models.ImportReport{
+			DryRun:  dryRun,
+			Total:   len(reqs),
+			Results: make([]models.ImportResult, 0, len(reqs)),
+		}
+
+		for i, req := range reqs {
+			result := models.ImportResult{Index: i, Username: models.NormalizeUsername(req.Username)}
+
+			// A savepoint keeps the transaction usable after a failed insert
+			savepoint := fmt.Sprintf("import_row_%d", i)
+			if err := tx.SavePoint(savepoint).Error; err != nil {
+				return fmt.Errorf("failed to create savepoint: %w", err)
+			}
+
+			user, err := txService.createUser(tx, req, nil)
+			if err != nil {
+				if err := tx.RollbackTo(savepoint).Error; err != nil {
+					return fmt.Errorf("failed to roll back row: %w", err)
+				}
+				result.Error = err.Error()
+				report.Failed++
+			} else {
+				result.User = user.ToResponse()
+				report.Created++
+			}
+
+			report.Results = append(report.Results, result)
+		}
+
+		if dryRun || report.Failed > 0 {
+			return errImportRollback
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errImportRollback) {
+		return nil, fmt.Errorf("failed to import users: %w", err)
+	}
+
+	if report.Failed > 0 && !dryRun {
+		// Rows that succeeded were rolled back with the rest
+		report.Created = 0
+		for i := range report.Results {
+			report.Results[i].User = nil
+		}
+		return report, validationError(fmt.Errorf("%d of %d rows failed, nothing was imported", report.Failed, report.Total))
+	}
+
+	if !dryRun {
+		for _, result := range report.Results {
+			s.publish(ctx, UserCreated{UserID: result.User.ID})
+		}
+	}
+
+	return report, nil
+}
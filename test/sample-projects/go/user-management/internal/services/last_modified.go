@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/utils"
+	"gorm.io/gorm"
+)
+
+// UsersLastModified returns when any user last changed. It is not scoped to
+// a filter: it bounds when any list FilterUsers returns last changed. It
+// is zero if there have never been users.
+func (s *UserService) UsersLastModified(ctx context.Context) (time.Time, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	return usersLastModified(db)
+}
+
+// SearchUsersLastModified checks query and fields as SearchUsers does and
+// returns UsersLastModified, which is not scoped to the matches either. It
+// is zero for a query too short to search, whose result is always empty.
+func (s *UserService) SearchUsersLastModified(ctx context.Context, query string, fields []string) (time.Time, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	if _, ok, err := s.checkSearchQuery(query); !ok {
+		return time.Time{}, err
+	}
+	if err := validateSearchFields(fields); err != nil {
+		return time.Time{}, err
+	}
+	return usersLastModified(db)
+}
+
+// usersLastModified returns the latest update, soft deletion or permanent
+// removal of any user. It looks at the whole table rather than the rows a
+// list matches: a user who stops matching, by changing status or being
+// deleted, changes the list as much as one in it changing, but a MAX over
+// the current matches can't see them. Removed users
+// leave no row behind, so removals are found through their audit entries.
+func usersLastModified(db *gorm.DB) (time.Time, error) {
+	var updated, deleted, removed interface{}
+	if err := db.Unscoped().Model(&models.User{}).
+		Select("MAX(updated_at), MAX(deleted_at)").
+		Row().Scan(&updated, &deleted); err != nil {
+		return time.Time{}, fmt.Errorf("failed to get last modification time: %w", err)
+	}
+	if err := db.Model(&utils.AuditLog{}).
+		Where("action IN ?", []string{AuditActionDelete, AuditActionPurge}).
+		Select("MAX(created_at)").
+		Row().Scan(&removed); err != nil {
+		return time.Time{}, fmt.Errorf("failed to get last modification time: %w", err)
+	}
+
+	var latest time.Time
+	for _, value := range []interface{}{updated, deleted, removed} {
+		if value == nil {
+			continue
+		}
+		at, err := parseDBTime(value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to get last modification time: %w", err)
+		}
+		if at.After(latest) {
+			latest = at
+		}
+	}
+	return latest, nil
+}
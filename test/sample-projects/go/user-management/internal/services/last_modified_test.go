@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/utils"
+)
+
+func TestUsersLastModified(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		change func(t *testing.T, s *UserService, user *models.User)
+	}{
+		{
+			name: "update",
+			change: func(t *testing.T, s *UserService, user *models.User) {
+				name := "Renamed"
+				if _, err := s.UpdateUser(ctx, user.ID, user.ID, models.UserUpdate{Name: &name}); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "deactivation",
+			change: func(t *testing.T, s *UserService, user *models.User) {
+				if _, err := s.DeactivateUser(ctx, user.ID, user.ID); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "soft delete",
+			change: func(t *testing.T, s *UserService, user *models.User) {
+				if err := s.DeleteUser(ctx, user.ID, ""); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "hard delete",
+			change: func(t *testing.T, s *UserService, user *models.User) {
+				if err := s.HardDeleteUser(ctx, user.ID, user.ID, ""); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "purge",
+			change: func(t *testing.T, s *UserService, user *models.User) {
+				if err := s.DeleteUser(ctx, user.ID, ""); err != nil {
+					t.Fatal(err)
+				}
+				if n, err := s.PurgeDeletedBefore(ctx, time.Now().Add(time.Minute)); err != nil || n != 1 {
+					t.Fatalf("PurgeDeletedBefore = %d, %v; want 1, nil", n, err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, utils.UserServiceConfig{})
+			createTestUser(t, s, "alice")
+			// bob is the most recently changed user, so removing him must
+			// not move the time backwards
+			bob := createTestUser(t, s, "bob")
+
+			before, err := s.UsersLastModified(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if before.IsZero() {
+				t.Fatal("UsersLastModified is zero with users present")
+			}
+
+			tt.change(t, s, bob)
+
+			after, err := s.UsersLastModified(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !after.After(before) {
+				t.Errorf("UsersLastModified after %s = %v, want after %v", tt.name, after, before)
+			}
+		})
+	}
+}
+
+func TestUsersLastModifiedEmpty(t *testing.T) {
+	s := newTestService(t, utils.UserServiceConfig{})
+
+	at, err := s.UsersLastModified(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !at.IsZero() {
+		t.Errorf("UsersLastModified = %v, want zero", at)
+	}
+}
+
+func TestSearchUsersLastModified(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, utils.UserServiceConfig{MinSearchLength: 2, ShortSearchPolicy: utils.ShortSearchEmpty})
+	createTestUser(t, s, "alice")
+
+	all, err := s.UsersLastModified(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		fields  []string
+		want    time.Time
+		wantErr error
+	}{
+		{"matches", "alice", nil, all, nil},
+		{"matches nothing", "nobody", nil, all, nil},
+		{"too short", "a", nil, time.Time{}, nil},
+		{"invalid field", "alice", []string{"password_hash"}, time.Time{}, ErrValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.SearchUsersLastModified(ctx, tt.query, tt.fields)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("SearchUsersLastModified = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
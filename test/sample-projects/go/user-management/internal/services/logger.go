@@ -0,0 +1,18 @@
+package services
+
+import (
+	"log/slog"
+
+	"github.com/example/user-management/internal/utils"
+)
+
+// logger receives warnings and audit entries from the services
+var logger utils.Logger = slog.Default()
+
+// SetLogger sets the logger the services write to, typically the one the
+// access log uses so all output shares a format
+func SetLogger(l utils.Logger) {
+	if l != nil {
+		logger = l
+	}
+}
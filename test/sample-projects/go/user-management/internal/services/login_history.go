@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type userAgentKey struct{}
+
+// WithUserAgent attaches the client's user agent to ctx so login events
+// published during authentication can record it
+func WithUserAgent(ctx context.Context, userAgent string) context.Context {
+	return context.WithValue(ctx, userAgentKey{}, userAgent)
+}
+
+func userAgentFrom(ctx context.Context) string {
+	userAgent, _ := ctx.Value(userAgentKey{}).(string)
+	return userAgent
+}
+
+// SubscribeLoginHistory records a login event for every authentication
+// attempt against a known user
+func SubscribeLoginHistory(bus *EventBus, db *gorm.DB) {
+	Subscribe(bus, func(ctx context.Context, e LoginSucceeded) error {
+		return recordLoginEvent(db.WithContext(ctx), e.UserID, true, e.ClientIP, e.UserAgent)
+	})
+	Subscribe(bus, func(ctx context.Context, e LoginFailed) error {
+		if e.UserID == uuid.Nil {
+			return nil
+		}
+		return recordLoginEvent(db.WithContext(ctx), e.UserID, false, e.ClientIP, e.UserAgent)
+	})
+}
+
+func recordLoginEvent(db *gorm.DB, userID uuid.UUID, success bool, ip, userAgent string) error {
+	event := &models.LoginEvent{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Success:   success,
+		IPAddress: ip,
+		UserAgent: userAgent,
+	}
+
+	if err := db.Create(event).Error; err != nil {
+		return fmt.Errorf("failed to record login event: %w", err)
+	}
+	return nil
+}
+
+// LoginHistory returns a user's login events, most recent first
+func (s *UserService) LoginHistory(ctx context.Context, userID uuid.UUID, page, pageSize int) ([]models.LoginEvent, int64, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	query := db.Model(&models.LoginEvent{}).Where("user_id = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count login events: %w", err)
+	}
+
+	var events []models.LoginEvent
+	offset := (page - 1) * pageSize
+	if err := query.Order("created_at DESC, id DESC").Limit(pageSize).Offset(offset).Find(&events).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get login history: %w", err)
+	}
+
+	return events, total, nil
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/utils"
+	"gorm.io/gorm"
+)
+
+// Metadata limits used when none are configured
+const (
+	defaultMetadataMaxBytes = 16 * 1024
+	defaultMetadataMaxDepth = 5
+)
+
+// validateMetadata rejects metadata that is too large, too deeply nested or
+// does not conform to the configured schema
+func (s *UserService) validateMetadata(metadata map[string]interface{}) error {
+	maxBytes := s.config.MetadataMaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMetadataMaxBytes
+	}
+
+	maxDepth := s.config.MetadataMaxDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultMetadataMaxDepth
+	}
+
+	if err := utils.ValidateMetadata(metadata, maxBytes, maxDepth); err != nil {
+		return validationError(err)
+	}
+
+	if s.metadataSchema != nil {
+		if metadata == nil {
+			metadata = map[string]interface{}{}
+		}
+		if errs := s.metadataSchema.Validate("metadata", metadata); errs != nil {
+			return validationError(errs)
+		}
+	}
+	return nil
+}
+
+// metadataIndexPrefix names the unique indexes backing UniqueMetadataKeys
+const metadataIndexPrefix = "idx_users_metadata_"
+
+// checkUniqueMetadata returns ErrDuplicateMetadata if another user already
+// has the value user holds for one of the unique metadata keys. The unique
+// indexes from EnsureMetadataIndexes catch writes racing past this check.
+func (s *UserService) checkUniqueMetadata(db *gorm.DB, user *models.User) error {
+	for _, key := range s.config.UniqueMetadataKeys {
+		value, ok := metadataText(user.Metadata[key])
+		if !ok {
+			continue
+		}
+
+		condition, args, err := metadataCondition(db, key, value)
+		if err != nil {
+			return err
+		}
+
+		var count int64
+		if err := db.Model(&models.User{}).Where(condition, args...).
+			Where("id <> ?", user.ID).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check metadata uniqueness: %w", err)
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: %s", ErrDuplicateMetadata, key)
+		}
+	}
+	return nil
+}
+
+// metadataText renders a scalar metadata value the way the database renders
+// it as text. Missing, null and structured values report false.
+func metadataText(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case int:
+		return strconv.Itoa(v), true
+	}
+	return "", false
+}
+
+// EnsureMetadataIndexes creates a unique expression index for each unique
+// metadata key so concurrent writes cannot both store the same value.
+func EnsureMetadataIndexes(db *gorm.DB, keys []string) error {
+	for _, key := range keys {
+		if !metadataKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid unique metadata key %q", key)
+		}
+
+		expr := fmt.Sprintf("json_extract(metadata, '$.%s')", key)
+		if db.Dialector.Name() == "postgres" {
+			expr = fmt.Sprintf("(metadata::jsonb ->> '%s')", key)
+		}
+
+		stmt := fmt.Sprintf(`CREATE UNIQUE INDEX IF NOT EXISTS "%s%s" ON users (%s)`, metadataIndexPrefix, key, expr)
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("failed to create unique index for metadata key %s: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/utils"
+)
+
+func TestCreateUserUniqueMetadataRace(t *testing.T) {
+	keys := []string{"external_id"}
+	db := newTestDB(t)
+	if err := EnsureMetadataIndexes(db, keys); err != nil {
+		t.Fatal(err)
+	}
+	s := NewUserService(db, utils.UserServiceConfig{UniqueMetadataKeys: keys})
+
+	const creates = 8
+	errs := make([]error, creates)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < creates; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, errs[i] = s.CreateUser(context.Background(), &models.UserRequest{
+				Username: fmt.Sprintf("user%d", i),
+				Email:    fmt.Sprintf("user%d@example.com", i),
+				Name:     "Racing User",
+				Password: "password123",
+				Metadata: map[string]interface{}{"external_id": "ext-42"},
+			})
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	var created int
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, ErrDuplicateMetadata):
+			t.Errorf("create %d = %v, want nil or %v", i, err, ErrDuplicateMetadata)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d creates succeeded, want exactly 1", created)
+	}
+}
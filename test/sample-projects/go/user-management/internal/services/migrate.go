@@ -0,0 +1,120 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/utils"
+	"gorm.io/gorm"
+)
+
+// schemaModels are the models whose tables RunMigrations creates
+var schemaModels = []interface{}{
+	&models.User{}, &models.RefreshToken{}, &models.IdempotencyKey{}, &models.SystemFlag{},
+	&models.LoginEvent{}, &models.UserEmail{}, &utils.AuditLog{},
+}
+
+// RunMigrations brings the database schema up to date: it creates and
+// alters tables, replaces legacy indexes, backfills columns added since
+// rows were written and creates the search indexes. Every step is
+// idempotent, so it can run on every deploy.
+func RunMigrations(db *gorm.DB) error {
+	if err := db.AutoMigrate(schemaModels...); err != nil {
+		return fmt.Errorf("failed to migrate tables: %w", err)
+	}
+
+	if err := normalizeUserIdentities(db); err != nil {
+		return err
+	}
+
+	if err := normalizePermissions(db); err != nil {
+		return err
+	}
+
+	// Users created before password ages were tracked count from creation
+	if err := db.Exec("UPDATE users SET password_changed_at = created_at WHERE password_changed_at IS NULL").Error; err != nil {
+		return fmt.Errorf("failed to backfill password change times: %w", err)
+	}
+
+	// Suspensions made before causes were recorded: a lockout time or a
+	// full set of failed logins means the lockout, anything else an admin
+	if err := db.Exec(`UPDATE users SET suspension_cause = CASE
+		WHEN locked_at IS NOT NULL OR login_attempts >= 5 THEN 'lockout' ELSE 'admin' END
+		WHERE status = 'suspended' AND (suspension_cause IS NULL OR suspension_cause = '')`).Error; err != nil {
+		return fmt.Errorf("failed to backfill suspension causes: %w", err)
+	}
+
+	return EnsureSearchIndexes(db)
+}
+
+// CheckSchema reports an error if a table RunMigrations creates is
+// missing, so a server started without migrations fails at startup rather
+// than on its first query
+func CheckSchema(db *gorm.DB) error {
+	for _, model := range schemaModels {
+		if !db.Migrator().HasTable(model) {
+			stmt := &gorm.Statement{DB: db}
+			if err := stmt.Parse(model); err != nil {
+				return fmt.Errorf("failed to check schema: %w", err)
+			}
+			return fmt.Errorf("table %s is missing; run migrations first", stmt.Schema.Table)
+		}
+	}
+	return nil
+}
+
+// normalizeUserIdentities backfills lowercase usernames and emails for rows
+// created before normalization and adds case-insensitive unique indexes.
+// The indexes skip deleted users so their usernames and emails can be
+// reused, and email uniqueness ignores empty strings so users without an
+// email don't collide. Legacy indexes covering every row are dropped.
+func normalizeUserIdentities(db *gorm.DB) error {
+	for _, index := range []string{"idx_users_email", "idx_users_username", "idx_users_username_lower", "idx_users_email_lower"} {
+		if db.Migrator().HasIndex(&models.User{}, index) {
+			if err := db.Migrator().DropIndex(&models.User{}, index); err != nil {
+				return fmt.Errorf("failed to drop legacy index %s: %w", index, err)
+			}
+		}
+	}
+
+	// Only rows that still need it, so later runs write nothing
+	if err := db.Exec(`UPDATE users SET username = LOWER(TRIM(username)), email = LOWER(TRIM(email))
+		WHERE username <> LOWER(TRIM(username)) OR email <> LOWER(TRIM(email))`).Error; err != nil {
+		return fmt.Errorf("failed to normalize user identities: %w", err)
+	}
+
+	// Live users differing only in case fail here, not in the update
+	if err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_live ON users (LOWER(username)) WHERE deleted_at IS NULL AND status <> 'deleted'").Error; err != nil {
+		return fmt.Errorf("failed to create username index (resolve case-insensitive duplicate usernames first): %w", err)
+	}
+
+	if err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_live ON users (LOWER(email)) WHERE email != '' AND deleted_at IS NULL AND status <> 'deleted'").Error; err != nil {
+		return fmt.Errorf("failed to create email index (resolve case-insensitive duplicate emails first): %w", err)
+	}
+
+	return nil
+}
+
+// normalizePermissions rewrites permissions stored before they were
+// normalized into their trimmed, lowercase form, merging duplicates and
+// dropping blanks. Rows already in canonical form are left untouched.
+func normalizePermissions(db *gorm.DB) error {
+	var users []*models.User
+	return db.Unscoped().Select("id", "permissions").
+		Where("permissions IS NOT NULL").
+		FindInBatches(&users, 500, func(tx *gorm.DB, batch int) error {
+			for _, user := range users {
+				before := user.Permissions
+				user.SetPermissions(before)
+				if slices.Equal(before, user.Permissions) {
+					continue
+				}
+
+				if err := db.Unscoped().Model(user).Select("Permissions").UpdateColumns(user).Error; err != nil {
+					return fmt.Errorf("failed to normalize permissions: %w", err)
+				}
+			}
+			return nil
+		}).Error
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/utils"
+)
+
+func TestNormalizeUserIdentities(t *testing.T) {
+	tests := []struct {
+		name         string
+		bobUsername  string
+		wantErr      string
+		wantUsername string
+	}{
+		{"legacy mixed case", "  Bob ", "", "bob"},
+		{"case-insensitive duplicate", "ALICE", "duplicate usernames", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, utils.UserServiceConfig{})
+			createTestUser(t, s, "alice")
+			bob := createTestUser(t, s, "bob")
+
+			// Rows written before normalization, without the index that
+			// would have stopped them
+			if err := s.db.Exec("DROP INDEX idx_users_username_live").Error; err != nil {
+				t.Fatalf("drop index: %v", err)
+			}
+			if err := s.db.Exec("UPDATE users SET username = ?, email = ? WHERE id = ?", tt.bobUsername, "Bob@Example.COM", bob.ID).Error; err != nil {
+				t.Fatalf("denormalize bob: %v", err)
+			}
+
+			err := RunMigrations(s.db)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("RunMigrations error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RunMigrations: %v", err)
+			}
+
+			var got models.User
+			if err := s.db.First(&got, "id = ?", bob.ID).Error; err != nil {
+				t.Fatalf("load bob: %v", err)
+			}
+			if got.Username != tt.wantUsername || got.Email != "bob@example.com" {
+				t.Errorf("bob = %q <%s>, want %q <bob@example.com>", got.Username, got.Email, tt.wantUsername)
+			}
+		})
+	}
+}
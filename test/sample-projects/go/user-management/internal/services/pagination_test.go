@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/utils"
+	"github.com/google/uuid"
+)
+
+func TestPaginationTieBreaksOnID(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, utils.UserServiceConfig{})
+
+	var users []*models.User
+	for i := 0; i < 12; i++ {
+		user := createTestUser(t, s, fmt.Sprintf("user%02d", i))
+		if _, err := s.UpdateMetadata(ctx, user.ID, map[string]interface{}{"team": "core"}, nil); err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, user)
+	}
+
+	// Give every user the same creation time so only the tie-breaker
+	// orders them
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := s.db.Model(&models.User{}).Where("1 = 1").Update("created_at", createdAt).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	byID := make([]uuid.UUID, len(users))
+	for i, user := range users {
+		byID[i] = user.ID
+	}
+	slices.SortFunc(byID, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+
+	tests := []struct {
+		name  string
+		fetch func(page, pageSize int) ([]*models.User, int64, error)
+	}{
+		{"GetAllUsers", func(page, pageSize int) ([]*models.User, int64, error) {
+			return s.GetAllUsers(ctx, page, pageSize)
+		}},
+		{"FilterUsers", func(page, pageSize int) ([]*models.User, int64, error) {
+			return s.FilterUsers(ctx, &utils.FilterParams{Role: string(models.RoleUser)}, page, pageSize)
+		}},
+		{"SearchByMetadata", func(page, pageSize int) ([]*models.User, int64, error) {
+			return s.SearchByMetadata(ctx, "team", "core", page, pageSize)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, pageSize := range []int{1, 5, 7, 12} {
+				ids := pageThrough(t, pageSize, tt.fetch)
+				checkPagedOnce(t, ids, users)
+				if !slices.Equal(ids, byID) {
+					t.Errorf("page size %d: users not in id order", pageSize)
+				}
+			}
+		})
+	}
+}
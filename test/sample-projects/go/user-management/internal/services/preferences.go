@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetPreferences returns a user's preferences with defaults applied to the
+// ones they haven't set
+func (s *UserService) GetPreferences(ctx context.Context, id uuid.UUID) (*models.UserPreferences, error) {
+	user, err := s.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	preferences := user.Preferences.WithDefaults()
+	return &preferences, nil
+}
+
+// UpdatePreferences merges the fields set in update into a user's
+// preferences and returns the result with defaults applied. Fields update
+// leaves unset keep their current value.
+func (s *UserService) UpdatePreferences(ctx context.Context, id uuid.UUID, update models.UserPreferences) (*models.UserPreferences, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	if err := update.Validate(); err != nil {
+		return nil, validationError(err)
+	}
+
+	var user models.User
+	err := s.transaction(db, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+
+		user.Preferences.Merge(update)
+		if err := tx.Save(&user).Error; err != nil {
+			return fmt.Errorf("failed to update preferences: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	preferences := user.Preferences.WithDefaults()
+	return &preferences, nil
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// defaultPurgeBatchSize is how many users one purge transaction removes when
+// no batch size is configured
+const defaultPurgeBatchSize = 100
+
+// PurgeDeletedBefore permanently deletes users that were soft-deleted before
+// cutoff, cascading to sessions and audit logs like HardDeleteUser. Users
+// are removed in batches, each in its own transaction, so a large purge
+// doesn't hold locks on the table for long; each batch records one audit
+// entry listing the removed ids. It returns how many users were
+// removed, including those from batches committed before an error.
+func (s *UserService) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	batchSize := s.config.PurgeBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultPurgeBatchSize
+	}
+
+	var purged int64
+	for {
+		n, err := s.purgeDeletedBatch(ctx, cutoff, batchSize)
+		purged += n
+		if err != nil {
+			return purged, err
+		}
+		if n < int64(batchSize) {
+			return purged, nil
+		}
+	}
+}
+
+// DeletedSummary reports how many soft-deleted users are waiting to be
+// purged and when the longest-waiting one was deleted, nil if there are
+// none. It uses the same notion of soft-deleted as the purge job and runs
+// as a single aggregate query.
+func (s *UserService) DeletedSummary(ctx context.Context) (int64, *time.Time, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	var count int64
+	var oldest interface{}
+	row := db.Unscoped().Model(&models.User{}).
+		Select("COUNT(*), MIN(COALESCE(deleted_at, updated_at))").
+		Where("deleted_at IS NOT NULL OR status = ?", models.StatusDeleted).
+		Row()
+	if err := row.Scan(&count, &oldest); err != nil {
+		return 0, nil, fmt.Errorf("failed to summarize deleted users: %w", err)
+	}
+
+	if oldest == nil {
+		return count, nil, nil
+	}
+
+	at, err := parseDBTime(oldest)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to summarize deleted users: %w", err)
+	}
+	return count, &at, nil
+}
+
+// dbTimeLayouts are the text forms drivers return for timestamps computed
+// by an aggregate, where the column type that would drive conversion to
+// time.Time is lost
+var dbTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	time.RFC3339Nano,
+}
+
+// parseDBTime converts a scanned timestamp to time.Time
+func parseDBTime(value interface{}) (time.Time, error) {
+	var text string
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return time.Time{}, fmt.Errorf("unexpected timestamp type %T", value)
+	}
+
+	for _, layout := range dbTimeLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", text)
+}
+
+// purgeDeletedBatch hard-deletes up to limit users soft-deleted before
+// cutoff. A user counts as soft-deleted if its deleted_at is set or its
+// status is deleted, in which case updated_at is the time of deletion.
+func (s *UserService) purgeDeletedBatch(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	var purged int64
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var ids []uuid.UUID
+		if err := tx.Unscoped().Model(&models.User{}).
+			Where("deleted_at < ? OR (status = ? AND updated_at < ?)", cutoff, models.StatusDeleted, cutoff).
+			Limit(limit).Pluck("id", &ids).Error; err != nil {
+			return fmt.Errorf("failed to find deleted users: %w", err)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if err := tx.Where("user_id IN ?", ids).Delete(&models.RefreshToken{}).Error; err != nil {
+			return fmt.Errorf("failed to delete sessions: %w", err)
+		}
+
+		if err := tx.Where("user_id IN ?", ids).Delete(&models.LoginEvent{}).Error; err != nil {
+			return fmt.Errorf("failed to delete login history: %w", err)
+		}
+
+		if err := tx.Where("user_id IN ?", ids).Delete(&models.UserEmail{}).Error; err != nil {
+			return fmt.Errorf("failed to delete emails: %w", err)
+		}
+
+		for _, id := range ids {
+			if err := s.purgeAuditLogs(tx, id); err != nil {
+				return err
+			}
+		}
+
+		result := tx.Unscoped().Where("id IN ?", ids).Delete(&models.User{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete users: %w", result.Error)
+		}
+		purged = result.RowsAffected
+
+		return recordAudit(tx, uuid.Nil, AuditActionPurge, "users", map[string]interface{}{
+			"ids":   ids,
+			"count": len(ids),
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted users: %w", err)
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/example/user-management/internal/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrPasswordRateLimited is returned when a user has exceeded the number of
+// password operations allowed within the configured window
+var ErrPasswordRateLimited = errors.New("too many password operations, try again later")
+
+// Default password operation limits, used when not configured
+const (
+	defaultPasswordChangeLimit  = 5
+	defaultPasswordChangeWindow = 60 // minutes
+)
+
+// passwordChangeActions are the audit actions counted against the
+// self-service password change limit. Failed attempts count too, so the
+// endpoint cannot be used to guess the current password.
+var passwordChangeActions = []string{AuditActionPasswordChange, AuditActionPasswordChangeFailed}
+
+// checkPasswordRateLimit counts recent audited password operations on a
+// user and returns ErrPasswordRateLimited once limit is reached. A limit
+// of zero or less disables the check.
+func (s *UserService) checkPasswordRateLimit(db *gorm.DB, id uuid.UUID, actions []string, limit int) error {
+	if limit <= 0 {
+		return nil
+	}
+
+	window := s.config.PasswordChangeWindow
+	if window <= 0 {
+		window = defaultPasswordChangeWindow
+	}
+	since := time.Now().Add(-time.Duration(window) * time.Minute)
+
+	var count int64
+	if err := db.Model(&utils.AuditLog{}).
+		Where("resource = ? AND action IN ? AND created_at > ?", userResource(id), actions, since).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check password rate limit: %w", err)
+	}
+
+	if count >= int64(limit) {
+		return ErrPasswordRateLimited
+	}
+
+	return nil
+}
@@ -0,0 +1,21 @@
+package services
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// readOnlyResource is the audit resource of the read-only maintenance switch
+const readOnlyResource = "system/read-only"
+
+// RecordReadOnlyChange audits actorID turning read-only mode on or off
+func (s *UserService) RecordReadOnlyChange(ctx context.Context, actorID uuid.UUID, enabled, blockLogin bool) error {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	return recordAudit(db, actorID, AuditActionReadOnlySet, readOnlyResource, map[string]interface{}{
+		"enabled":     enabled,
+		"block_login": blockLogin,
+	})
+}
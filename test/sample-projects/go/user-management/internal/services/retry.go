@@ -0,0 +1,114 @@
+package services
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Retry settings used when none are configured
+const (
+	defaultWriteRetries      = 2
+	defaultWriteRetryBackoff = 50 * time.Millisecond
+)
+
+// TransientErrorClassifier reports whether a database error is worth
+// retrying, such as a serialization failure or a dropped connection.
+// Deterministic errors like constraint violations must return false.
+type TransientErrorClassifier func(err error) bool
+
+var (
+	classifiersMu        sync.RWMutex
+	transientClassifiers = map[string]TransientErrorClassifier{
+		"postgres": isTransientPostgresError,
+		"sqlite":   isTransientSQLiteError,
+	}
+)
+
+// RegisterTransientClassifier sets the classifier used for the GORM dialect
+// with the given name, replacing any built-in one
+func RegisterTransientClassifier(dialect string, classify TransientErrorClassifier) {
+	classifiersMu.Lock()
+	defer classifiersMu.Unlock()
+	transientClassifiers[dialect] = classify
+}
+
+// isTransient classifies err with the classifier for db's dialect. Errors
+// from dialects without a classifier are never retried.
+func isTransient(db *gorm.DB, err error) bool {
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+
+	classifiersMu.RLock()
+	classify := transientClassifiers[db.Dialector.Name()]
+	classifiersMu.RUnlock()
+
+	return classify != nil && classify(err)
+}
+
+// isTransientPostgresError matches the SQLSTATE classes Postgres uses for
+// serialization failures, deadlocks and lost connections
+func isTransientPostgresError(err error) bool {
+	msg := err.Error()
+	for _, code := range []string{"40001", "40P01", "08000", "08003", "08006", "57P01"} {
+		if strings.Contains(msg, "SQLSTATE "+code) || strings.Contains(msg, "("+code+")") {
+			return true
+		}
+	}
+	return strings.Contains(msg, "connection reset by peer") || strings.Contains(msg, "broken pipe")
+}
+
+// isTransientSQLiteError matches SQLite's busy and locked errors
+func isTransientSQLiteError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "database is locked") || strings.Contains(msg, "database table is locked")
+}
+
+// retryWrite runs write, retrying it with exponential backoff while it
+// fails with a transient error, up to the configured number of retries.
+// Writes on a transaction handle are not retried: the transaction is
+// already aborted, so the caller has to retry it as a whole.
+func (s *UserService) retryWrite(db *gorm.DB, write func() error) error {
+	retries := s.config.WriteRetries
+	if retries <= 0 {
+		retries = defaultWriteRetries
+	}
+	backoff := time.Duration(s.config.WriteRetryBackoff) * time.Millisecond
+	if backoff <= 0 {
+		backoff = defaultWriteRetryBackoff
+	}
+
+	if _, inTx := db.Statement.ConnPool.(gorm.TxCommitter); inTx {
+		retries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := write()
+		if err == nil || attempt >= retries || !isTransient(db, err) {
+			return err
+		}
+
+		logger.Warn("retrying transient database error", "attempt", attempt+1, "error", err)
+
+		timer := time.NewTimer(backoff << attempt)
+		select {
+		case <-timer.C:
+		case <-db.Statement.Context.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// transaction runs fn in a transaction on db, retrying the whole
+// transaction on transient errors
+func (s *UserService) transaction(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	return s.retryWrite(db, func() error {
+		return db.Transaction(fn)
+	})
+}
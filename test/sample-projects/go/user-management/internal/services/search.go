@@ -0,0 +1,100 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// searchStrategy builds the filter used by SearchUsers for a SQL dialect
+type searchStrategy interface {
+	// condition returns the WHERE clause and its arguments matching query
+	// against the given columns
+	condition(query string, fields []string) (string, []interface{})
+}
+
+// searchStrategyFor picks the search strategy for the database dialect
+func searchStrategyFor(db *gorm.DB) searchStrategy {
+	if db.Dialector.Name() == "postgres" {
+		return trigramSearch{}
+	}
+	return likeSearch{}
+}
+
+// likeSearch matches substrings with LIKE. It works everywhere but cannot
+// use an index, so it scans the whole table.
+type likeSearch struct{}
+
+func (likeSearch) condition(query string, fields []string) (string, []interface{}) {
+	pattern := "%" + strings.ToLower(query) + "%"
+
+	conditions := make([]string, 0, len(fields))
+	args := make([]interface{}, 0, len(fields))
+	for _, field := range fields {
+		conditions = append(conditions, "LOWER("+field+") LIKE ?")
+		args = append(args, pattern)
+	}
+
+	return strings.Join(conditions, " OR "), args
+}
+
+// trigramSearch uses pg_trgm. Both the LIKE substring match and the %
+// similarity operator are served by the GIN indexes created in
+// EnsureSearchIndexes.
+type trigramSearch struct{}
+
+func (trigramSearch) condition(query string, fields []string) (string, []interface{}) {
+	lowered := strings.ToLower(query)
+	pattern := "%" + lowered + "%"
+
+	conditions := make([]string, 0, len(fields))
+	args := make([]interface{}, 0, len(fields)*2)
+	for _, field := range fields {
+		conditions = append(conditions, "LOWER("+field+") LIKE ? OR LOWER("+field+") % ?")
+		args = append(args, pattern, lowered)
+	}
+
+	return strings.Join(conditions, " OR "), args
+}
+
+// metadataKeyPattern restricts metadata keys to characters that are safe in
+// a JSON path
+var metadataKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// metadataCondition returns a WHERE clause matching users whose metadata
+// has key set to value, compared as text. Users without the key don't
+// match.
+func metadataCondition(db *gorm.DB, key, value string) (string, []interface{}, error) {
+	if !metadataKeyPattern.MatchString(key) {
+		return "", nil, validationError(errors.New("metadata key may only contain letters, digits, '_' and '-'"))
+	}
+
+	if db.Dialector.Name() == "postgres" {
+		return "metadata::jsonb ->> ? = ?", []interface{}{key, value}, nil
+	}
+	return "CAST(json_extract(metadata, ?) AS TEXT) = ?", []interface{}{"$." + key, value}, nil
+}
+
+// EnsureSearchIndexes creates the trigram indexes used by SearchUsers on
+// postgres. It is a no-op on other dialects.
+func EnsureSearchIndexes(db *gorm.DB) error {
+	if db.Dialector.Name() != "postgres" {
+		return nil
+	}
+
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		return fmt.Errorf("failed to enable pg_trgm: %w", err)
+	}
+
+	for _, field := range searchableFields {
+		stmt := fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_users_%s_trgm ON users USING gin (LOWER(%s) gin_trgm_ops)", field, field)
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("failed to create %s trigram index: %w", field, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/utils"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestSearchUsersPagination(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, utils.UserServiceConfig{})
+
+	// Every user matches the query and shares name and age, so sorting by
+	// either leaves only the tie-breaker to order them
+	var users []*models.User
+	for i := 0; i < 23; i++ {
+		users = append(users, createTestUser(t, s, fmt.Sprintf("member%02d", (i*7)%23)))
+	}
+
+	tests := []struct {
+		sortBy  string
+		sortDir string
+	}{
+		{"", ""},
+		{"username", "desc"},
+		{"name", "asc"},
+		{"age", "desc"},
+		{"created_at", "asc"},
+		{utils.SortRelevance, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("sort %q %q", tt.sortBy, tt.sortDir), func(t *testing.T) {
+			for _, pageSize := range []int{1, 5, 10, 23, 50} {
+				ids := pageThrough(t, pageSize, func(page, pageSize int) ([]*models.User, int64, error) {
+					return s.SearchUsers(ctx, &utils.SearchParams{
+						Query:    "member",
+						Page:     page,
+						PageSize: pageSize,
+						SortBy:   tt.sortBy,
+						SortDir:  tt.sortDir,
+					}, nil)
+				})
+				checkPagedOnce(t, ids, users)
+			}
+		})
+	}
+}
+
+func TestSearchUsersDefaultSortIsUsername(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, utils.UserServiceConfig{})
+	for _, username := range []string{"member_c", "member_a", "member_b"} {
+		createTestUser(t, s, username)
+	}
+
+	found, _, err := s.SearchUsers(ctx, &utils.SearchParams{Query: "member", Page: 1, PageSize: 10}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var usernames []string
+	for _, user := range found {
+		usernames = append(usernames, user.Username)
+	}
+	if !slices.IsSortedFunc(usernames, strings.Compare) || len(usernames) != 3 {
+		t.Errorf("default search order = %v, want the three usernames ascending", usernames)
+	}
+}
+
+// BenchmarkSearchUsers searches a seeded table of users. SQLite takes the
+// LIKE strategy and scans the table; BenchmarkSearchStrategiesPostgres
+// shows the trigram indexes from EnsureSearchIndexes at work.
+func BenchmarkSearchUsers(b *testing.B) {
+	ctx := context.Background()
+	s := newTestService(b, utils.UserServiceConfig{})
+	seedUsers(b, s, 10000)
+
+	tests := []struct {
+		name   string
+		params utils.SearchParams
+		fields []string
+	}{
+		{"one match", utils.SearchParams{Query: "user04321"}, nil},
+		{"many matches", utils.SearchParams{Query: "user04"}, nil},
+		{"no match", utils.SearchParams{Query: "nobody"}, nil},
+		{"username only", utils.SearchParams{Query: "user04"}, []string{"username"}},
+		{"by relevance", utils.SearchParams{Query: "user04", SortBy: utils.SortRelevance}, nil},
+	}
+
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			params := tt.params
+			params.Page, params.PageSize = 1, utils.DefaultPageSize
+			for i := 0; i < b.N; i++ {
+				if _, _, err := s.SearchUsers(ctx, &params, tt.fields); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// postgresDSNEnv names the variable holding the DSN of a scratch postgres
+// database for BenchmarkSearchStrategiesPostgres
+const postgresDSNEnv = "TEST_POSTGRES_DSN"
+
+// BenchmarkSearchStrategiesPostgres runs the LIKE and trigram filters on
+// the same seeded postgres table, with the indexes EnsureSearchIndexes
+// creates, so the two can be compared directly. It is skipped unless
+// TEST_POSTGRES_DSN is set; the database's tables are dropped and
+// recreated, so point it at one used for nothing else.
+func BenchmarkSearchStrategiesPostgres(b *testing.B) {
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		b.Skipf("%s not set", postgresDSNEnv)
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		b.Fatalf("open database: %v", err)
+	}
+	b.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := db.Migrator().DropTable(schemaModels...); err != nil {
+		b.Fatalf("drop tables: %v", err)
+	}
+	if err := RunMigrations(db); err != nil {
+		b.Fatalf("migrate database: %v", err)
+	}
+	s := NewUserService(db, utils.UserServiceConfig{})
+	seedUsers(b, s, 10000)
+	if err := db.Exec("ANALYZE users").Error; err != nil {
+		b.Fatalf("analyze users: %v", err)
+	}
+
+	strategies := []struct {
+		name     string
+		strategy searchStrategy
+	}{
+		{"like", likeSearch{}},
+		{"trigram", trigramSearch{}},
+	}
+	queries := []struct {
+		name  string
+		query string
+	}{
+		{"one match", "user04321"},
+		{"many matches", "user04"},
+		{"no match", "nobody"},
+	}
+
+	for _, st := range strategies {
+		for _, q := range queries {
+			b.Run(st.name+"/"+q.name, func(b *testing.B) {
+				where, args := st.strategy.condition(q.query, searchableFields)
+				for i := 0; i < b.N; i++ {
+					var users []*models.User
+					if err := db.Scopes(notDeleted).Where(where, args...).
+						Order("username").Limit(utils.DefaultPageSize).Find(&users).Error; err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/utils"
+	"gorm.io/gorm"
+)
+
+// maxAgeBuckets caps how many boundaries an age distribution may use, which
+// bounds the size of its CASE expression
+const maxAgeBuckets = 20
+
+// maxSignupBuckets caps how many periods a signups series may cover
+const maxSignupBuckets = 1000
+
+// AgeDistribution counts live users per age range. The ascending boundaries
+// split ages into ranges that each start at a boundary and end before the
+// next one, labelled "18-24"; ages at or above the last boundary fall into
+// an open-ended "50+" range and ages below the first into "<18". Every
+// range appears in the result, with zero if no user falls into it. The
+// counts come from a single grouped query.
+func (s *UserService) AgeDistribution(ctx context.Context, buckets []int) (map[string]int64, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	if err := validateAgeBuckets(buckets); err != nil {
+		return nil, validationError(err)
+	}
+
+	distribution := make(map[string]int64, len(buckets)+1)
+	var when []string
+	var args []interface{}
+	if buckets[0] > 0 {
+		label := "<" + strconv.Itoa(buckets[0])
+		distribution[label] = 0
+		when = append(when, "WHEN age < ? THEN ?")
+		args = append(args, buckets[0], label)
+	}
+	for i := 0; i < len(buckets)-1; i++ {
+		label := fmt.Sprintf("%d-%d", buckets[i], buckets[i+1]-1)
+		distribution[label] = 0
+		when = append(when, "WHEN age < ? THEN ?")
+		args = append(args, buckets[i+1], label)
+	}
+	top := strconv.Itoa(buckets[len(buckets)-1]) + "+"
+	distribution[top] = 0
+	args = append(args, top)
+	bucket := "CASE " + strings.Join(when, " ") + " ELSE ? END"
+
+	var rows []struct {
+		Bucket string
+		Count  int64
+	}
+	if err := db.Model(&models.User{}).Scopes(notDeleted).
+		Select(bucket+" AS bucket, COUNT(*) AS count", args...).
+		Group("bucket").Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to count users by age: %w", err)
+	}
+
+	for _, row := range rows {
+		distribution[row.Bucket] = row.Count
+	}
+	return distribution, nil
+}
+
+// validateAgeBuckets checks that age bucket boundaries are given, valid ages
+// and strictly ascending
+func validateAgeBuckets(buckets []int) error {
+	if len(buckets) == 0 {
+		return errors.New("at least one age bucket boundary is required")
+	}
+	if len(buckets) > maxAgeBuckets {
+		return fmt.Errorf("at most %d age bucket boundaries are allowed", maxAgeBuckets)
+	}
+	for i, boundary := range buckets {
+		if boundary < 0 || boundary > 150 {
+			return fmt.Errorf("age bucket boundary %d must be between 0 and 150", boundary)
+		}
+		if i > 0 && boundary <= buckets[i-1] {
+			return fmt.Errorf("age bucket boundaries must be ascending: %d follows %d", boundary, buckets[i-1])
+		}
+	}
+	return nil
+}
+
+// SignupsOverTime counts the users created in [from, to) per day, week
+// (starting Monday) or month, in UTC. Deleted users count too, since they
+// did sign up. The series runs from the period containing from to the one
+// containing the end of the range, with zero counts for periods without
+// signups, so it can be charted as is.
+func (s *UserService) SignupsOverTime(ctx context.Context, from, to time.Time, granularity string) ([]utils.TimeBucket, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	if !to.After(from) {
+		return nil, validationError(errors.New("from must be before to"))
+	}
+	truncate, next, err := signupPeriod(granularity)
+	if err != nil {
+		return nil, validationError(err)
+	}
+
+	var series []utils.TimeBucket
+	for start := truncate(from.UTC()); start.Before(to); start = next(start) {
+		if len(series) == maxSignupBuckets {
+			return nil, validationError(fmt.Errorf("range covers more than %d %ss", maxSignupBuckets, granularity))
+		}
+		series = append(series, utils.TimeBucket{Start: start})
+	}
+
+	period := truncateCreatedAt(db, granularity)
+	var rows []struct {
+		Period string
+		Count  int64
+	}
+	if err := db.Unscoped().Model(&models.User{}).
+		Select(period+" AS period, COUNT(*) AS count").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("period").Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to count signups: %w", err)
+	}
+
+	counts := make(map[time.Time]int64, len(rows))
+	for _, row := range rows {
+		start, err := time.Parse(time.DateOnly, row.Period)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count signups: unexpected period %q", row.Period)
+		}
+		counts[start] = row.Count
+	}
+	for i := range series {
+		series[i].Count = counts[series[i].Start]
+	}
+	return series, nil
+}
+
+// signupPeriod returns how to truncate a UTC time to the start of its
+// period and how to step to the start of the following period
+func signupPeriod(granularity string) (truncate, next func(time.Time) time.Time, err error) {
+	day := func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+
+	switch granularity {
+	case utils.GranularityDay:
+		return day, func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }, nil
+	case utils.GranularityWeek:
+		week := func(t time.Time) time.Time {
+			sinceMonday := (int(t.Weekday()) + 6) % 7
+			return day(t).AddDate(0, 0, -sinceMonday)
+		}
+		return week, func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }, nil
+	case utils.GranularityMonth:
+		month := func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		}
+		return month, func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }, nil
+	default:
+		return nil, nil, fmt.Errorf("invalid granularity: %s (must be day, week or month)", granularity)
+	}
+}
+
+// truncateCreatedAt returns an expression giving the UTC start of the
+// period containing created_at as YYYY-MM-DD text
+func truncateCreatedAt(db *gorm.DB, granularity string) string {
+	if db.Dialector.Name() == "postgres" {
+		return fmt.Sprintf("to_char(date_trunc('%s', created_at AT TIME ZONE 'UTC'), 'YYYY-MM-DD')", granularity)
+	}
+
+	// SQLite's date functions convert to UTC. For weeks, 'weekday 0' moves
+	// forward to Sunday, unless it already is one, and six days back from
+	// there is the Monday starting the week.
+	switch granularity {
+	case utils.GranularityWeek:
+		return "date(created_at, 'weekday 0', '-6 days')"
+	case utils.GranularityMonth:
+		return "date(created_at, 'start of month')"
+	default:
+		return "date(created_at)"
+	}
+}
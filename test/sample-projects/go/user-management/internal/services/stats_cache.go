@@ -0,0 +1,50 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/example/user-management/internal/utils"
+)
+
+// statsCache holds the last user statistics computed by GetUserStats so
+// dashboards polling it don't run every count each time
+type statsCache struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	stats     utils.UserStats
+	expiresAt time.Time
+}
+
+func newStatsCache(ttl time.Duration) *statsCache {
+	return &statsCache{ttl: ttl}
+}
+
+// get returns a copy of the cached statistics if they haven't expired
+func (c *statsCache) get() (*utils.UserStats, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !time.Now().Before(c.expiresAt) {
+		return nil, false
+	}
+	stats := c.stats
+	return &stats, true
+}
+
+func (c *statsCache) set(stats *utils.UserStats) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats = *stats
+	c.expiresAt = time.Now().Add(c.ttl)
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/example/user-management/internal/utils"
+)
+
+func TestStatsCacheTTL(t *testing.T) {
+	const ttl = 50 * time.Millisecond
+	cache := newStatsCache(ttl)
+
+	if _, ok := cache.get(); ok {
+		t.Fatal("empty cache reported a hit")
+	}
+
+	cache.set(&utils.UserStats{Total: 3})
+	stats, ok := cache.get()
+	if !ok || stats.Total != 3 {
+		t.Fatalf("get = %+v, %t; want Total 3, true", stats, ok)
+	}
+
+	// The caller's copy must not alias the cached value
+	stats.Total = 99
+	if again, _ := cache.get(); again.Total != 3 {
+		t.Errorf("cached Total = %d after modifying a copy, want 3", again.Total)
+	}
+
+	time.Sleep(2 * ttl)
+	if _, ok := cache.get(); ok {
+		t.Error("cache hit after the TTL elapsed")
+	}
+}
+
+func TestGetUserStatsCaching(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		ttl  int // seconds; 0 disables the cache
+
+		// wantCached is the Total GetUserStats reports after a second user
+		// is created
+		wantCached int64
+	}{
+		{"cache disabled", 0, 2},
+		{"cache enabled", 60, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, utils.UserServiceConfig{StatsCacheTTL: tt.ttl})
+			createTestUser(t, s, "alice")
+			if _, err := s.GetUserStats(ctx); err != nil {
+				t.Fatal(err)
+			}
+			createTestUser(t, s, "bob")
+
+			stats, err := s.GetUserStats(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Total != tt.wantCached {
+				t.Errorf("GetUserStats Total = %d, want %d", stats.Total, tt.wantCached)
+			}
+
+			fresh, err := s.GetUserStatsFresh(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fresh.Total != 2 {
+				t.Errorf("GetUserStatsFresh Total = %d, want 2", fresh.Total)
+			}
+
+			// A fresh read refreshes the cache
+			if stats, _ := s.GetUserStats(ctx); stats.Total != 2 {
+				t.Errorf("GetUserStats Total after a fresh read = %d, want 2", stats.Total)
+			}
+		})
+	}
+}
+
+func TestStatsCacheConcurrentAccess(t *testing.T) {
+	cache := newStatsCache(time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				cache.set(&utils.UserStats{Total: int64(i)})
+				if stats, ok := cache.get(); !ok || stats.Total < 0 || stats.Total >= 8 {
+					t.Errorf("get = %+v, %t", stats, ok)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
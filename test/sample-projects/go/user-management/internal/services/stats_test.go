@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/utils"
+)
+
+func TestUserStatsDeletedUsers(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		delete func(t *testing.T, s *UserService, user *models.User)
+	}{
+		{
+			name: "deleted status",
+			delete: func(t *testing.T, s *UserService, user *models.User) {
+				if err := s.DeleteUser(ctx, user.ID, ""); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "gorm soft delete",
+			delete: func(t *testing.T, s *UserService, user *models.User) {
+				if err := s.db.Delete(user).Error; err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, utils.UserServiceConfig{})
+			createTestUser(t, s, "alice")
+			createTestUser(t, s, "bob")
+			tt.delete(t, s, createTestUser(t, s, "carol"))
+
+			stats, err := s.GetUserStats(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := utils.UserStats{
+				Total:                 2,
+				Active:                2,
+				User:                  2,
+				WithEmail:             2,
+				Deleted:               1,
+				TotalIncludingDeleted: 3,
+			}
+			if *stats != want {
+				t.Errorf("GetUserStats = %+v, want %+v", *stats, want)
+			}
+		})
+	}
+}
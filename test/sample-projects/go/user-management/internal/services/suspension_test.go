@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/utils"
+)
+
+func TestSuspensionCause(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		suspend func(t *testing.T, s *UserService, user *models.User)
+		cause   models.SuspensionCause
+
+		// unlocked is whether UnlockUser lifts the suspension
+		unlocked bool
+	}{
+		{
+			name: "lockout",
+			suspend: func(t *testing.T, s *UserService, user *models.User) {
+				for i := 0; i < 5; i++ {
+					if _, err := s.AuthenticateUser(ctx, user.Username, "wrong-password", SecondFactor{}, "127.0.0.1"); err == nil {
+						t.Fatal("AuthenticateUser with a wrong password succeeded")
+					}
+				}
+			},
+			cause:    models.SuspensionCauseLockout,
+			unlocked: true,
+		},
+		{
+			name: "admin",
+			suspend: func(t *testing.T, s *UserService, user *models.User) {
+				if _, err := s.SuspendUser(ctx, user.ID, user.ID); err != nil {
+					t.Fatal(err)
+				}
+			},
+			cause: models.SuspensionCauseAdmin,
+		},
+		{
+			name: "expiry",
+			suspend: func(t *testing.T, s *UserService, user *models.User) {
+				if err := user.SuspendFor(models.SuspensionCauseExpiry); err != nil {
+					t.Fatal(err)
+				}
+				if err := s.db.Save(user).Error; err != nil {
+					t.Fatal(err)
+				}
+			},
+			cause: models.SuspensionCauseExpiry,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, utils.UserServiceConfig{})
+			user := createTestUser(t, s, "alice")
+
+			tt.suspend(t, s, user)
+
+			activity, err := s.GetUserActivity(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if activity.SuspensionCause != string(tt.cause) {
+				t.Errorf("SuspensionCause = %q, want %q", activity.SuspensionCause, tt.cause)
+			}
+
+			unlocked, err := s.UnlockUser(ctx, user.ID, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := unlocked.Status == models.StatusActive; got != tt.unlocked {
+				t.Errorf("after UnlockUser status = %s, want active: %t", unlocked.Status, tt.unlocked)
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TokenService handles persistence and revocation of refresh tokens
+type TokenService struct {
+	db     *gorm.DB
+	config utils.SessionConfig
+}
+
+// NewTokenService creates a new token service
+func NewTokenService(db *gorm.DB, config utils.SessionConfig) *TokenService {
+	return &TokenService{db: db, config: config}
+}
+
+// StoreRefreshToken records an issued refresh token so it can be revoked
+// later. Storing a token starts a session, so it enforces
+// MaxSessionsPerUser: past the limit it either returns
+// ErrSessionLimitReached or revokes the user's oldest sessions, depending on
+// the limit policy.
+func (s *TokenService) StoreRefreshToken(ctx context.Context, id string, userID uuid.UUID, expiresAt time.Time) error {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	token := &models.RefreshToken{
+		ID:        id,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := s.enforceSessionLimit(tx, userID); err != nil {
+			return err
+		}
+
+		if err := tx.Create(token).Error; err != nil {
+			return fmt.Errorf("failed to store refresh token: %w", err)
+		}
+		return nil
+	})
+}
+
+// CountActiveSessions returns how many sessions of a user are still usable
+func (s *TokenService) CountActiveSessions(ctx context.Context, userID uuid.UUID) (int64, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	var count int64
+	if err := s.activeSessions(db, userID).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count sessions: %w", err)
+	}
+	return count, nil
+}
+
+// enforceSessionLimit makes room for one more session of a user
+func (s *TokenService) enforceSessionLimit(tx *gorm.DB, userID uuid.UUID) error {
+	limit := s.config.MaxSessionsPerUser
+	if limit <= 0 {
+		return nil
+	}
+
+	var count int64
+	if err := s.activeSessions(tx, userID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count sessions: %w", err)
+	}
+	if count < int64(limit) {
+		return nil
+	}
+
+	if s.config.LimitPolicy == utils.SessionLimitReject {
+		return ErrSessionLimitReached
+	}
+
+	// Access tokens already issued for evicted sessions stay valid until
+	// they expire; only the refresh tokens are revoked
+	var oldest []string
+	if err := s.activeSessions(tx, userID).Order("created_at").
+		Limit(int(count)-limit+1).Pluck("id", &oldest).Error; err != nil {
+		return fmt.Errorf("failed to find oldest sessions: %w", err)
+	}
+	if err := tx.Model(&models.RefreshToken{}).Where("id IN ?", oldest).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to evict sessions: %w", err)
+	}
+	return nil
+}
+
+// activeSessions selects the refresh tokens of a user that are neither
+// revoked, expired nor past the session timeouts
+func (s *TokenService) activeSessions(db *gorm.DB, userID uuid.UUID) *gorm.DB {
+	now := time.Now()
+	query := db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now)
+
+	if s.config.AbsoluteTimeout > 0 {
+		query = query.Where("created_at > ?", now.Add(-time.Duration(s.config.AbsoluteTimeout)*time.Hour))
+	}
+	if s.config.IdleTimeout > 0 {
+		query = query.Where("COALESCE(last_used_at, created_at) > ?", now.Add(-time.Duration(s.config.IdleTimeout)*time.Hour))
+	}
+	return query
+}
+
+// GetActiveRefreshToken retrieves a refresh token that is neither revoked nor expired
+func (s *TokenService) GetActiveRefreshToken(ctx context.Context, id string) (*models.RefreshToken, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	var token models.RefreshToken
+	if err := db.First(&token, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("refresh token not found")
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	if token.IsRevoked() {
+		return nil, errors.New("refresh token has been revoked")
+	}
+
+	if token.IsExpired() {
+		return nil, errors.New("refresh token has expired")
+	}
+
+	return &token, nil
+}
+
+// GetSession retrieves an active refresh token and enforces the session
+// timeouts. It returns ErrSessionIdleExpired if the session has not been
+// used within the idle timeout and ErrSessionAbsoluteExpired once it is
+// older than the absolute timeout; neither can be refreshed.
+func (s *TokenService) GetSession(ctx context.Context, id string) (*models.RefreshToken, error) {
+	token, err := s.GetActiveRefreshToken(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if s.config.AbsoluteTimeout > 0 && now.Sub(token.CreatedAt) > time.Duration(s.config.AbsoluteTimeout)*time.Hour {
+		return nil, ErrSessionAbsoluteExpired
+	}
+	if s.config.IdleTimeout > 0 && now.Sub(token.LastActivity()) > time.Duration(s.config.IdleTimeout)*time.Hour {
+		return nil, ErrSessionIdleExpired
+	}
+
+	return token, nil
+}
+
+// ExtendSession records use of a refresh token, restarting its idle timeout
+func (s *TokenService) ExtendSession(ctx context.Context, id string) error {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	if err := db.Model(&models.RefreshToken{}).
+		Where("id = ?", id).
+		Update("last_used_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to extend session: %w", err)
+	}
+	return nil
+}
+
+// RevokeRefreshToken revokes a single refresh token
+func (s *TokenService) RevokeRefreshToken(ctx context.Context, id string) error {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	if err := db.Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+	return nil
+}
+
+// RevokeUserRefreshTokens revokes every outstanding refresh token of a user
+func (s *TokenService) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	return revokeUserRefreshTokens(db, userID)
+}
+
+// RevokeAllForUser ends every session of a user: outstanding refresh tokens
+// are revoked and the token version is bumped so access tokens already
+// issued fail validation. The action is recorded in the audit log.
+func (s *TokenService) RevokeAllForUser(ctx context.Context, userID, actorID uuid.UUID) error {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	defer tokenVersions.invalidate(userID)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).Where("id = ?", userID).
+			Update("token_version", gorm.Expr("token_version + 1"))
+		if result.Error != nil {
+			return fmt.Errorf("failed to bump token version: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrUserNotFound
+		}
+
+		if err := revokeUserRefreshTokens(tx, userID); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, actorID, AuditActionLogoutAll, userResource(userID), nil)
+	})
+}
+
+// TokenVersion returns the current token version of a user. Versions are
+// cached briefly so checking every request doesn't hit the database.
+func (s *TokenService) TokenVersion(ctx context.Context, userID uuid.UUID) (int, error) {
+	state, err := s.tokenState(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	return state.version, nil
+}
+
+// IsShadowed reports whether a user is shadow banned. It is cached along
+// with the token version, so checking it on every request is cheap.
+func (s *TokenService) IsShadowed(ctx context.Context, userID uuid.UUID) (bool, error) {
+	state, err := s.tokenState(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return state.shadowed, nil
+}
+
+func (s *TokenService) tokenState(ctx context.Context, userID uuid.UUID) (tokenState, error) {
+	if state, ok := tokenVersions.get(userID); ok {
+		return state, nil
+	}
+
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	var user models.User
+	if err := db.Select("token_version", "shadowed").First(&user, "id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tokenState{}, ErrUserNotFound
+		}
+		return tokenState{}, fmt.Errorf("failed to get token version: %w", err)
+	}
+
+	state := tokenState{version: user.TokenVersion, shadowed: user.Shadowed}
+	tokenVersions.set(userID, state)
+	return state, nil
+}
+
+// revokeUserRefreshTokens is shared with UserService so that password
+// changes revoke outstanding refresh tokens
+func revokeUserRefreshTokens(db *gorm.DB, userID uuid.UUID) error {
+	if err := db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/example/user-management/internal/utils"
+)
+
+func TestSessionLimit(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		policy  string
+		wantErr error
+
+		// active lists which of the sessions s1, s2 and s3 are usable
+		// after the third login
+		active map[string]bool
+	}{
+		{
+			policy:  utils.SessionLimitReject,
+			wantErr: ErrSessionLimitReached,
+			active:  map[string]bool{"s1": true, "s2": true, "s3": false},
+		},
+		{
+			policy: utils.SessionLimitEvict,
+			active: map[string]bool{"s1": false, "s2": true, "s3": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			db := newTestDB(t)
+			user := createTestUser(t, NewUserService(db, utils.UserServiceConfig{}), "alice")
+			tokens := NewTokenService(db, utils.SessionConfig{MaxSessionsPerUser: 2, LimitPolicy: tt.policy})
+
+			expires := time.Now().Add(time.Hour)
+			for _, id := range []string{"s1", "s2"} {
+				if err := tokens.StoreRefreshToken(ctx, id, user.ID, expires); err != nil {
+					t.Fatalf("StoreRefreshToken(%s) = %v, want nil", id, err)
+				}
+			}
+
+			err := tokens.StoreRefreshToken(ctx, "s3", user.ID, expires)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("StoreRefreshToken(s3) = %v, want %v", err, tt.wantErr)
+			}
+
+			for id, want := range tt.active {
+				_, err := tokens.GetActiveRefreshToken(ctx, id)
+				if got := err == nil; got != want {
+					t.Errorf("session %s active = %t, want %t (%v)", id, got, want, err)
+				}
+			}
+
+			count, err := tokens.CountActiveSessions(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 2 {
+				t.Errorf("CountActiveSessions = %d, want 2", count)
+			}
+		})
+	}
+}
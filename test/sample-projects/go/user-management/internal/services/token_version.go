@@ -0,0 +1,75 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// tokenVersionTTL bounds how long a cached token version is trusted. Bumps
+// made by this process invalidate the entry immediately; bumps made by
+// other instances are picked up once it expires.
+const tokenVersionTTL = 30 * time.Second
+
+// tokenVersions is shared by every service in the process so that version
+// bumps from UserService are seen by TokenService's checks
+var tokenVersions = &tokenVersionCache{entries: make(map[uuid.UUID]tokenVersionEntry)}
+
+// tokenState is what validating an access token needs to know about its
+// user
+type tokenState struct {
+	version  int
+	shadowed bool
+}
+
+type tokenVersionEntry struct {
+	state     tokenState
+	expiresAt time.Time
+}
+
+// tokenVersionCache keeps recently checked token versions, and the shadow
+// ban flag that rides along with them, so validating an access token
+// doesn't cost a database query per request
+type tokenVersionCache struct {
+	mu        sync.RWMutex
+	entries   map[uuid.UUID]tokenVersionEntry
+	lastSweep time.Time
+}
+
+func (c *tokenVersionCache) get(userID uuid.UUID) (tokenState, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[userID]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return tokenState{}, false
+	}
+	return entry.state, true
+}
+
+func (c *tokenVersionCache) set(userID uuid.UUID, state tokenState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Drop expired entries now and then so users who stop making requests
+	// don't pin memory
+	now := time.Now()
+	if now.Sub(c.lastSweep) > tokenVersionTTL {
+		for id, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, id)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	c.entries[userID] = tokenVersionEntry{state: state, expiresAt: now.Add(tokenVersionTTL)}
+}
+
+func (c *tokenVersionCache) invalidate(userID uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, userID)
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SecondFactor is what a login offers besides the password when the user
+// has two-factor authentication enabled: a code from the authenticator app
+// or, when the app is unavailable, one of the user's backup codes
+type SecondFactor struct {
+	Code       string
+	BackupCode string
+}
+
+// SetupTwoFactor starts two-factor setup after confirming the user's
+// password. It returns a new authenticator secret and its otpauth URI;
+// two-factor authentication is only required once EnableTwoFactor confirms
+// a code made from it. Starting again replaces an unconfirmed secret.
+func (s *UserService) SetupTwoFactor(ctx context.Context, id uuid.UUID, password string) (secret, uri string, err error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	user, err := s.GetUserByID(ctx, id)
+	if err != nil {
+		return "", "", err
+	}
+
+	if !user.VerifyPassword(password) {
+		return "", "", validationError(errors.New("password is incorrect"))
+	}
+	if user.TwoFactorEnabled {
+		return "", "", validationError(errors.New("two-factor authentication is already enabled"))
+	}
+
+	secret, err = user.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate two-factor secret: %w", err)
+	}
+	if err := db.Model(user).Select("TOTPSecret", "TOTPLastStep").Updates(user).Error; err != nil {
+		return "", "", fmt.Errorf("failed to save two-factor secret: %w", err)
+	}
+	return secret, user.TOTPURI(), nil
+}
+
+// EnableTwoFactor finishes setup once the user shows a code from their
+// authenticator app. From then on every login needs a second factor. It
+// returns the user's first backup codes, which are shown only this once.
+func (s *UserService) EnableTwoFactor(ctx context.Context, id uuid.UUID, code string) ([]string, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	var codes []string
+	err := s.transaction(db, func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+
+		if user.TwoFactorEnabled {
+			return validationError(errors.New("two-factor authentication is already enabled"))
+		}
+		if user.TOTPSecret == "" {
+			return validationError(errors.New("two-factor setup has not been started"))
+		}
+		if !user.ConsumeTOTPCode(code, time.Now()) {
+			return ErrInvalidTwoFactorCode
+		}
+
+		var err error
+		if codes, err = user.GenerateBackupCodes(); err != nil {
+			return fmt.Errorf("failed to generate backup codes: %w", err)
+		}
+		user.TwoFactorEnabled = true
+
+		if err := tx.Model(&user).Select("TwoFactorEnabled", "TOTPLastStep", "BackupCodes").Updates(&user).Error; err != nil {
+			return fmt.Errorf("failed to enable two-factor authentication: %w", err)
+		}
+
+		return recordAudit(tx, user.ID, AuditActionTwoFactorEnable, userResource(user.ID), map[string]interface{}{
+			"backup_codes": len(codes),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off after confirming the
+// user's password, discarding the authenticator secret and backup codes.
+// Disabling it when it is off does nothing.
+func (s *UserService) DisableTwoFactor(ctx context.Context, id uuid.UUID, password string) error {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	user, err := s.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !user.VerifyPassword(password) {
+		return validationError(errors.New("password is incorrect"))
+	}
+	if !user.TwoFactorEnabled && user.TOTPSecret == "" {
+		return nil
+	}
+	wasEnabled := user.TwoFactorEnabled
+
+	user.DisableTwoFactor()
+	return s.transaction(db, func(tx *gorm.DB) error {
+		if err := tx.Model(user).Select("TwoFactorEnabled", "TOTPSecret", "TOTPLastStep", "BackupCodes").Updates(user).Error; err != nil {
+			return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+		}
+		if !wasEnabled {
+			return nil
+		}
+		return recordAudit(tx, user.ID, AuditActionTwoFactorDisable, userResource(user.ID), nil)
+	})
+}
+
+// verifySecondFactor checks the second factor of a login whose password was
+// right. An authenticator code is preferred; a backup code is spent only
+// when no authenticator code is given.
+func (s *UserService) verifySecondFactor(db *gorm.DB, user *models.User, factor SecondFactor) error {
+	switch {
+	case factor.Code != "":
+		// The accepted step is saved with the rest of the login
+		if !user.ConsumeTOTPCode(factor.Code, time.Now()) {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	case factor.BackupCode != "":
+		remaining, err := s.consumeBackupCode(db, user.ID, factor.BackupCode)
+		if err != nil {
+			return err
+		}
+		user.BackupCodes = remaining
+		return nil
+	default:
+		return ErrTwoFactorRequired
+	}
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/utils"
+)
+
+// enableTwoFactor sets up and enables two-factor authentication for user
+// and returns the authenticator secret and the backup codes
+func enableTwoFactor(t *testing.T, s *UserService, user *models.User) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+
+	secret, _, err := s.SetupTwoFactor(ctx, user.ID, "password123")
+	if err != nil {
+		t.Fatalf("setup two-factor: %v", err)
+	}
+	codes, err := s.EnableTwoFactor(ctx, user.ID, totpCode(t, secret, time.Now()))
+	if err != nil {
+		t.Fatalf("enable two-factor: %v", err)
+	}
+	return secret, codes
+}
+
+// nextTOTPCode returns a code from the period after now, which is still
+// accepted but not yet used by enabling
+func nextTOTPCode(t *testing.T, secret string) string {
+	t.Helper()
+	return totpCode(t, secret, time.Now().Add(30*time.Second))
+}
+
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	code, err := models.TOTPCode(secret, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func loginAttempts(t *testing.T, s *UserService, user *models.User) int {
+	t.Helper()
+	current, err := s.GetUserByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return current.LoginAttempts
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		factor   func(t *testing.T, secret string, codes []string) SecondFactor
+		wantErr  error
+
+		// failed tells whether the attempt counts as a failed login
+		failed bool
+	}{
+		{
+			name:     "authenticator code",
+			password: "password123",
+			factor: func(t *testing.T, secret string, codes []string) SecondFactor {
+				return SecondFactor{Code: nextTOTPCode(t, secret)}
+			},
+		},
+		{
+			name:     "backup code",
+			password: "password123",
+			factor: func(t *testing.T, secret string, codes []string) SecondFactor {
+				return SecondFactor{BackupCode: codes[0]}
+			},
+		},
+		{
+			name:     "no second factor",
+			password: "password123",
+			factor:   func(t *testing.T, secret string, codes []string) SecondFactor { return SecondFactor{} },
+			wantErr:  ErrTwoFactorRequired,
+		},
+		{
+			name:     "wrong authenticator code",
+			password: "password123",
+			factor:   func(t *testing.T, secret string, codes []string) SecondFactor { return SecondFactor{Code: "000000"} },
+			wantErr:  ErrInvalidTwoFactorCode,
+			failed:   true,
+		},
+		{
+			name:     "wrong backup code",
+			password: "password123",
+			factor: func(t *testing.T, secret string, codes []string) SecondFactor {
+				return SecondFactor{BackupCode: "aaaa-aaaa"}
+			},
+			wantErr: ErrInvalidBackupCode,
+			failed:  true,
+		},
+		{
+			name:     "wrong password",
+			password: "wrong-password",
+			factor: func(t *testing.T, secret string, codes []string) SecondFactor {
+				return SecondFactor{BackupCode: codes[0]}
+			},
+			wantErr: ErrInvalidCredentials,
+			failed:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, utils.UserServiceConfig{})
+			user := createTestUser(t, s, "alice")
+			secret, codes := enableTwoFactor(t, s, user)
+
+			_, err := s.AuthenticateUser(ctx, "alice", tt.password, tt.factor(t, secret, codes), "127.0.0.1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			wantAttempts := 0
+			if tt.failed {
+				wantAttempts = 1
+			}
+			if got := loginAttempts(t, s, user); got != wantAttempts {
+				t.Errorf("login attempts = %d, want %d", got, wantAttempts)
+			}
+		})
+	}
+}
+
+func TestWrongPasswordKeepsBackupCode(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, utils.UserServiceConfig{})
+	user := createTestUser(t, s, "alice")
+	_, codes := enableTwoFactor(t, s, user)
+
+	if _, err := s.AuthenticateUser(ctx, "alice", "wrong-password", SecondFactor{BackupCode: codes[0]}, "127.0.0.1"); err == nil {
+		t.Fatal("login with a wrong password succeeded")
+	}
+	if _, err := s.AuthenticateUser(ctx, "alice", "password123", SecondFactor{BackupCode: codes[0]}, "127.0.0.1"); err != nil {
+		t.Errorf("backup code was spent by a failed login: %v", err)
+	}
+}
+
+func TestBackupCodesExhausted(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, utils.UserServiceConfig{})
+	user := createTestUser(t, s, "alice")
+	secret, codes := enableTwoFactor(t, s, user)
+	if len(codes) != models.BackupCodeCount {
+		t.Fatalf("got %d backup codes, want %d", len(codes), models.BackupCodeCount)
+	}
+
+	for i, code := range codes {
+		if _, err := s.AuthenticateUser(ctx, "alice", "password123", SecondFactor{BackupCode: code}, "127.0.0.1"); err != nil {
+			t.Fatalf("backup code %d: %v", i, err)
+		}
+	}
+
+	// Every code is spent, by now and by a second try
+	if _, err := s.AuthenticateUser(ctx, "alice", "password123", SecondFactor{BackupCode: codes[0]}, "127.0.0.1"); !errors.Is(err, ErrInvalidBackupCode) {
+		t.Errorf("reused backup code: err = %v, want %v", err, ErrInvalidBackupCode)
+	}
+
+	// Running out of codes leaves the second factor required
+	if _, err := s.AuthenticateUser(ctx, "alice", "password123", SecondFactor{}, "127.0.0.1"); !errors.Is(err, ErrTwoFactorRequired) {
+		t.Errorf("login without a second factor: err = %v, want %v", err, ErrTwoFactorRequired)
+	}
+	if _, err := s.AuthenticateUser(ctx, "alice", "password123", SecondFactor{Code: nextTOTPCode(t, secret)}, "127.0.0.1"); err != nil {
+		t.Errorf("login with an authenticator code: %v", err)
+	}
+}
+
+func TestAuthenticatorCodeSingleUse(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, utils.UserServiceConfig{})
+	user := createTestUser(t, s, "alice")
+	secret, _ := enableTwoFactor(t, s, user)
+
+	code := nextTOTPCode(t, secret)
+	if _, err := s.AuthenticateUser(ctx, "alice", "password123", SecondFactor{Code: code}, "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AuthenticateUser(ctx, "alice", "password123", SecondFactor{Code: code}, "127.0.0.1"); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("reused code: err = %v, want %v", err, ErrInvalidTwoFactorCode)
+	}
+}
+
+func TestRegenerateBackupCodes(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, utils.UserServiceConfig{})
+	user := createTestUser(t, s, "alice")
+
+	if _, err := s.RegenerateBackupCodes(ctx, user.ID, "password123"); !errors.Is(err, ErrValidation) {
+		t.Fatalf("without two-factor: err = %v, want %v", err, ErrValidation)
+	}
+
+	_, old := enableTwoFactor(t, s, user)
+	if _, err := s.RegenerateBackupCodes(ctx, user.ID, "wrong-password"); !errors.Is(err, ErrValidation) {
+		t.Fatalf("wrong password: err = %v, want %v", err, ErrValidation)
+	}
+	codes, err := s.RegenerateBackupCodes(ctx, user.ID, "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != models.BackupCodeCount {
+		t.Fatalf("got %d backup codes, want %d", len(codes), models.BackupCodeCount)
+	}
+
+	if _, err := s.AuthenticateUser(ctx, "alice", "password123", SecondFactor{BackupCode: old[0]}, "127.0.0.1"); !errors.Is(err, ErrInvalidBackupCode) {
+		t.Errorf("replaced backup code: err = %v, want %v", err, ErrInvalidBackupCode)
+	}
+	if _, err := s.AuthenticateUser(ctx, "alice", "password123", SecondFactor{BackupCode: codes[0]}, "127.0.0.1"); err != nil {
+		t.Errorf("new backup code: %v", err)
+	}
+}
+
+func TestTwoFactorSetup(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, utils.UserServiceConfig{})
+	user := createTestUser(t, s, "alice")
+
+	if _, err := s.EnableTwoFactor(ctx, user.ID, "123456"); !errors.Is(err, ErrValidation) {
+		t.Errorf("enable before setup: err = %v, want %v", err, ErrValidation)
+	}
+	if _, _, err := s.SetupTwoFactor(ctx, user.ID, "wrong-password"); !errors.Is(err, ErrValidation) {
+		t.Errorf("setup with a wrong password: err = %v, want %v", err, ErrValidation)
+	}
+
+	secret, _, err := s.SetupTwoFactor(ctx, user.ID, "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EnableTwoFactor(ctx, user.ID, "000000"); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("enable with a wrong code: err = %v, want %v", err, ErrInvalidTwoFactorCode)
+	}
+
+	// Until a code is confirmed the password alone still logs in
+	if _, err := s.AuthenticateUser(ctx, "alice", "password123", SecondFactor{}, "127.0.0.1"); err != nil {
+		t.Fatalf("login before enabling: %v", err)
+	}
+
+	if _, err := s.EnableTwoFactor(ctx, user.ID, totpCode(t, secret, time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AuthenticateUser(ctx, "alice", "password123", SecondFactor{}, "127.0.0.1"); !errors.Is(err, ErrTwoFactorRequired) {
+		t.Fatalf("login after enabling: err = %v, want %v", err, ErrTwoFactorRequired)
+	}
+
+	if err := s.DisableTwoFactor(ctx, user.ID, "password123"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AuthenticateUser(ctx, "alice", "password123", SecondFactor{}, "127.0.0.1"); err != nil {
+		t.Errorf("login after disabling: %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// WithTx runs fn inside a database transaction, passing a UserService whose
+// queries all run in that transaction. The transaction commits if fn returns
+// nil and rolls back otherwise. Calling WithTx on a transaction-scoped
+// service reuses the existing transaction instead of starting a new one.
+func (s *UserService) WithTx(ctx context.Context, fn func(txService *UserService) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		txService := *s
+		txService.db = tx
+		txService.inTx = true
+		return fn(&txService)
+	})
+}
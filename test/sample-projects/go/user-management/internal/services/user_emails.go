@@ -0,0 +1,327 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ListEmails returns a user's email addresses, primary first. Users who have
+// never added a second address have no rows yet; their primary address is
+// reported from User.Email.
+func (s *UserService) ListEmails(ctx context.Context, id uuid.UUID) ([]models.UserEmail, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	user, err := s.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var emails []models.UserEmail
+	if err := db.Where("user_id = ?", id).Order("is_primary DESC, created_at").Find(&emails).Error; err != nil {
+		return nil, fmt.Errorf("failed to get emails: %w", err)
+	}
+
+	if len(emails) == 0 && user.Email != "" {
+		emails = append(emails, models.UserEmail{
+			UserID:    user.ID,
+			Email:     user.Email,
+			IsPrimary: true,
+			Verified:  user.EmailVerified,
+			CreatedAt: user.CreatedAt,
+		})
+	}
+	return emails, nil
+}
+
+// AddEmail adds an address to a user. The first address of a user without
+// an email becomes primary; later ones are secondary. It returns
+// ErrDuplicateEmail if the address belongs to any live user.
+func (s *UserService) AddEmail(ctx context.Context, id, actorID uuid.UUID, email string, verified bool) (*models.UserEmail, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	email = models.NormalizeEmail(email)
+	if err := utils.ValidateEmail(email); err != nil {
+		return nil, validationError(err)
+	}
+
+	var added *models.UserEmail
+	err := s.transaction(db, func(tx *gorm.DB) error {
+		user, emails, err := lockUserEmails(tx, id)
+		if err != nil {
+			return err
+		}
+
+		for _, existing := range emails {
+			if existing.Email == email {
+				return ErrDuplicateEmail
+			}
+		}
+
+		if err := s.checkEmailAvailable(tx, user.ID, email); err != nil {
+			return err
+		}
+
+		added = &models.UserEmail{
+			ID:        uuid.New(),
+			UserID:    user.ID,
+			Email:     email,
+			IsPrimary: len(emails) == 0,
+			Verified:  verified,
+		}
+		if err := createUserEmail(tx, added); err != nil {
+			return err
+		}
+
+		if added.IsPrimary {
+			if err := mirrorPrimaryEmail(tx, user.ID, added); err != nil {
+				return err
+			}
+		}
+
+		return recordAudit(tx, actorID, AuditActionEmailAdd, userResource(user.ID), map[string]interface{}{
+			"email":    email,
+			"primary":  added.IsPrimary,
+			"verified": verified,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return added, nil
+}
+
+// SetPrimaryEmail makes one of a user's verified addresses primary and
+// mirrors it into User.Email
+func (s *UserService) SetPrimaryEmail(ctx context.Context, id, actorID uuid.UUID, email string) (*models.User, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	email = models.NormalizeEmail(email)
+
+	err := s.transaction(db, func(tx *gorm.DB) error {
+		user, emails, err := lockUserEmails(tx, id)
+		if err != nil {
+			return err
+		}
+
+		target := findEmail(emails, email)
+		if target == nil {
+			return ErrEmailNotFound
+		}
+		if target.IsPrimary {
+			return nil
+		}
+		if !target.Verified {
+			return validationError(errors.New("email must be verified before it can be made primary"))
+		}
+
+		if err := tx.Model(&models.UserEmail{}).Where("user_id = ?", user.ID).
+			Update("is_primary", gorm.Expr("id = ?", target.ID)).Error; err != nil {
+			return fmt.Errorf("failed to set primary email: %w", err)
+		}
+
+		if err := mirrorPrimaryEmail(tx, user.ID, target); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, actorID, AuditActionEmailSetPrimary, userResource(user.ID), map[string]interface{}{
+			"old_email": user.Email,
+			"new_email": email,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetUserByID(ctx, id)
+}
+
+// RemoveEmail removes a secondary address from a user. The primary address
+// can't be removed; make another address primary first.
+func (s *UserService) RemoveEmail(ctx context.Context, id, actorID uuid.UUID, email string) error {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	email = models.NormalizeEmail(email)
+
+	return s.transaction(db, func(tx *gorm.DB) error {
+		_, emails, err := lockUserEmails(tx, id)
+		if err != nil {
+			return err
+		}
+
+		target := findEmail(emails, email)
+		if target == nil {
+			return ErrEmailNotFound
+		}
+		if target.IsPrimary {
+			return validationError(errors.New("the primary email cannot be removed"))
+		}
+
+		if err := tx.Delete(target).Error; err != nil {
+			return fmt.Errorf("failed to remove email: %w", err)
+		}
+
+		return recordAudit(tx, actorID, AuditActionEmailRemove, userResource(id), map[string]interface{}{
+			"email": email,
+		})
+	})
+}
+
+// lockUserEmails locks the user row and returns it with its addresses. A
+// user with an email but no rows yet gets its primary row created from
+// User.Email, so every caller sees the full set.
+func lockUserEmails(tx *gorm.DB, id uuid.UUID) (*models.User, []models.UserEmail, error) {
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(notDeleted).First(&user, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrUserNotFound
+		}
+		return nil, nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	var emails []models.UserEmail
+	if err := tx.Where("user_id = ?", id).Find(&emails).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to get emails: %w", err)
+	}
+
+	if len(emails) == 0 && user.Email != "" {
+		primary := models.UserEmail{
+			ID:        uuid.New(),
+			UserID:    user.ID,
+			Email:     user.Email,
+			IsPrimary: true,
+			Verified:  user.EmailVerified,
+		}
+		if err := createUserEmail(tx, &primary); err != nil {
+			return nil, nil, err
+		}
+		emails = append(emails, primary)
+	}
+
+	return &user, emails, nil
+}
+
+// createUserEmail inserts an address, first dropping any row for the same
+// address left behind by a deleted user so the unique index doesn't block it
+func createUserEmail(tx *gorm.DB, email *models.UserEmail) error {
+	if err := tx.Where("email = ? AND user_id NOT IN (?)", email.Email, liveUserIDs(tx)).
+		Delete(&models.UserEmail{}).Error; err != nil {
+		return fmt.Errorf("failed to release email: %w", err)
+	}
+
+	if err := tx.Create(email).Error; err != nil {
+		if dupErr := duplicateError(err); dupErr != nil {
+			return dupErr
+		}
+		return fmt.Errorf("failed to add email: %w", err)
+	}
+	return nil
+}
+
+// mirrorPrimaryEmail copies the primary address into the user's email
+// columns, which older clients and lookups still read
+func mirrorPrimaryEmail(tx *gorm.DB, userID uuid.UUID, primary *models.UserEmail) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"email":          primary.Email,
+		"email_verified": primary.Verified,
+	}).Error; err != nil {
+		if dupErr := duplicateError(err); dupErr != nil {
+			return dupErr
+		}
+		return fmt.Errorf("failed to update email: %w", err)
+	}
+	return nil
+}
+
+// syncPrimaryEmail follows a change of User.Email made through UpdateUser.
+// Users without address rows are left alone; otherwise the primary row is
+// replaced by the new address, absorbing a matching secondary row. The new
+// row is unverified whatever the rows it replaces were, since UpdateUser
+// doesn't verify the address.
+func syncPrimaryEmail(tx *gorm.DB, user *models.User) error {
+	var count int64
+	if err := tx.Model(&models.UserEmail{}).Where("user_id = ?", user.ID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to get emails: %w", err)
+	}
+	if count == 0 {
+		return nil
+	}
+
+	if err := tx.Where("user_id = ? AND (is_primary = ? OR email = ?)", user.ID, true, user.Email).
+		Delete(&models.UserEmail{}).Error; err != nil {
+		return fmt.Errorf("failed to update primary email: %w", err)
+	}
+	if user.Email == "" {
+		return nil
+	}
+
+	return createUserEmail(tx, &models.UserEmail{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Email:     user.Email,
+		IsPrimary: true,
+		Verified:  false,
+	})
+}
+
+// checkEmailAvailable returns ErrDuplicateEmail if email is the primary or
+// a secondary address of a live user other than userID. With
+// UnifiedLoginIdentifiers it also must not be another user's username.
+func (s *UserService) checkEmailAvailable(db *gorm.DB, userID uuid.UUID, email string) error {
+	identities := db.Where("email = ?", email)
+	if s.config.UnifiedLoginIdentifiers {
+		identities = identities.Or("username = ?", email)
+	}
+
+	var count int64
+	if err := db.Model(&models.User{}).Scopes(notDeleted).
+		Where("id <> ?", userID).Where(identities).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check email availability: %w", err)
+	}
+	if count > 0 {
+		return ErrDuplicateEmail
+	}
+
+	return checkSecondaryEmails(db, userID, email)
+}
+
+// checkSecondaryEmails returns ErrDuplicateEmail if email is one of the
+// addresses of a live user other than userID in the emails table
+func checkSecondaryEmails(db *gorm.DB, userID uuid.UUID, email string) error {
+	var count int64
+	if err := db.Model(&models.UserEmail{}).
+		Where("email = ? AND user_id <> ? AND user_id IN (?)", email, userID, liveUserIDs(db)).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check email availability: %w", err)
+	}
+	if count > 0 {
+		return ErrDuplicateEmail
+	}
+	return nil
+}
+
+// liveUserIDs is a subquery selecting the ids of users that aren't deleted
+func liveUserIDs(db *gorm.DB) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).Model(&models.User{}).Scopes(notDeleted).Select("id")
+}
+
+func findEmail(emails []models.UserEmail, email string) *models.UserEmail {
+	for i := range emails {
+		if emails[i].Email == email {
+			return &emails[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/utils"
+)
+
+func TestUpdateEmailResetsVerification(t *testing.T) {
+	tests := []struct {
+		name string
+
+		// rows gives the user address rows: a verified primary and a
+		// verified secondary at alice@second.example.com
+		rows     bool
+		newEmail string
+		wantOK   bool
+	}{
+		{"no address rows", false, "alice@new.example.com", false},
+		{"address rows", true, "alice@new.example.com", false},
+		{"verified secondary made primary", true, "alice@second.example.com", false},
+		{"same address in other case", false, "Alice@Example.com", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, utils.UserServiceConfig{})
+			user := createTestUser(t, s, "alice")
+			if tt.rows {
+				if err := s.db.Model(user).Update("email", "").Error; err != nil {
+					t.Fatal(err)
+				}
+				for _, email := range []string{"alice@example.com", "alice@second.example.com"} {
+					if _, err := s.AddEmail(ctx, user.ID, user.ID, email, true); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if err := s.db.Model(user).Update("email_verified", true).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			updated, err := s.UpdateUser(ctx, user.ID, user.ID, models.UserUpdate{Email: &tt.newEmail})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if updated.EmailVerified != tt.wantOK {
+				t.Errorf("EmailVerified = %v, want %v", updated.EmailVerified, tt.wantOK)
+			}
+
+			if !tt.rows {
+				return
+			}
+			emails, err := s.ListEmails(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, email := range emails {
+				if email.IsPrimary && (email.Email != updated.Email || email.Verified) {
+					t.Errorf("primary row = %s verified %v, want %s unverified", email.Email, email.Verified, updated.Email)
+				}
+			}
+		})
+	}
+}
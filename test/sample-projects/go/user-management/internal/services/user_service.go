@@ -1,40 +1,206 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/example/user-management/internal/models"
 	"github.com/example/user-management/internal/utils"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // UserService handles user-related business logic
 type UserService struct {
-	db *gorm.DB
+	db                 *gorm.DB
+	config             utils.UserServiceConfig
+	rolePermissions    map[models.UserRole][]string
+	allowedPermissions map[models.UserRole][]string
+	permissionCatalog  []string
+
+	// events receives lifecycle events after they are committed
+	events *EventBus
+
+	// metadataSchema, when set, constrains the shape of user metadata
+	metadataSchema *utils.JSONSchema
+
+	// stats caches GetUserStats; nil when caching is disabled
+	stats *statsCache
+
+	// inTx is set on services created by WithTx
+	inTx bool
 }
 
 // NewUserService creates a new user service
-func NewUserService(db *gorm.DB) *UserService {
-	return &UserService{db: db}
+func NewUserService(db *gorm.DB, config utils.UserServiceConfig) *UserService {
+	if config.AuditOnHardDelete == "" {
+		config.AuditOnHardDelete = utils.AuditRetentionAnonymize
+	}
+
+	rolePermissions := make(map[models.UserRole][]string, len(models.RolePermissions))
+	for role, permissions := range models.RolePermissions {
+		rolePermissions[role] = configPermissions(permissions)
+	}
+	for role, permissions := range config.RolePermissions {
+		rolePermissions[models.UserRole(role)] = configPermissions(permissions)
+	}
+
+	allowedPermissions := make(map[models.UserRole][]string, len(models.RoleAllowedPermissions))
+	for role, permissions := range models.RoleAllowedPermissions {
+		allowedPermissions[role] = configPermissions(permissions)
+	}
+	for role, permissions := range config.RoleAllowedPermissions {
+		allowedPermissions[models.UserRole(role)] = configPermissions(permissions)
+	}
+
+	var stats *statsCache
+	if config.StatsCacheTTL > 0 {
+		stats = newStatsCache(time.Duration(config.StatsCacheTTL) * time.Second)
+	}
+
+	return &UserService{
+		db:                 db,
+		config:             config,
+		rolePermissions:    rolePermissions,
+		allowedPermissions: allowedPermissions,
+		permissionCatalog:  configPermissions(config.PermissionCatalog),
+		stats:              stats,
+	}
+}
+
+// configPermissions normalizes permissions from configuration so they
+// compare equal to stored ones, skipping blanks
+func configPermissions(permissions []string) []string {
+	normalized := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		if permission = models.NormalizePermission(permission); permission != "" && !slices.Contains(normalized, permission) {
+			normalized = append(normalized, permission)
+		}
+	}
+	return normalized
+}
+
+// SetEventBus sets the bus user events are published on. Events are
+// discarded until a bus is set.
+func (s *UserService) SetEventBus(bus *EventBus) {
+	s.events = bus
+}
+
+// SetMetadataSchema sets the JSON Schema user metadata must conform to.
+// A nil schema leaves metadata free-form.
+func (s *UserService) SetMetadataSchema(schema *utils.JSONSchema) {
+	s.metadataSchema = schema
+}
+
+// publish sends event to the event bus. The change it describes is already
+// committed, so subscriber failures are logged rather than returned.
+func (s *UserService) publish(ctx context.Context, event Event) {
+	if s.events == nil {
+		return
+	}
+	if err := s.events.Publish(ctx, event); err != nil {
+		logger.Warn("event subscribers failed", "event", event.EventType(), "error", err)
+	}
 }
 
 // CreateUser creates a new user
-func (s *UserService) CreateUser(req *models.UserRequest) (*models.User, error) {
+func (s *UserService) CreateUser(ctx context.Context, req *models.UserRequest) (*models.User, error) {
+	if s.config.BootstrapFirstAdmin {
+		return s.createFirstUser(ctx, req, false)
+	}
+	return s.createUserDefault(ctx, req)
+}
+
+// createUserDefault creates a user with the default role handling
+func (s *UserService) createUserDefault(ctx context.Context, req *models.UserRequest) (*models.User, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	user, err := s.createUser(db, req, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publish(ctx, UserCreated{UserID: user.ID})
+	return user, nil
+}
+
+// CreateUserAsAdmin creates a user with privileged fields (status, explicit
+// permissions, verified email) set by an admin. Input is validated like a
+// public signup, permissions must be allowed for the role, and the creation
+// is audited against actorID.
+func (s *UserService) CreateUserAsAdmin(ctx context.Context, actorID uuid.UUID, req *models.AdminUserRequest) (*models.User, error) {
+	var user *models.User
+
+	err := s.WithTx(ctx, func(txService *UserService) error {
+		var err error
+		user, err = txService.createUser(txService.db, &req.UserRequest, func(u *models.User) error {
+			if req.Status != "" {
+				u.Status = req.Status
+			}
+			if req.Permissions != nil {
+				permissions, err := models.NormalizePermissions(req.Permissions)
+				if err != nil {
+					return validationError(err)
+				}
+				if err := txService.checkAllowedPermissions(u.Role, permissions); err != nil {
+					return err
+				}
+				u.SetPermissions(permissions)
+			}
+			u.EmailVerified = req.EmailVerified
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(txService.db, actorID, AuditActionCreate, userResource(user.ID), map[string]interface{}{
+			"role":           user.Role,
+			"status":         user.Status,
+			"permissions":    user.Permissions,
+			"email_verified": user.EmailVerified,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.publish(ctx, UserCreated{UserID: user.ID})
+	return user, nil
+}
+
+// createUser validates req, checks for duplicates and inserts the user
+// using db, which may be a transaction. customize, if set, may adjust the
+// user before it is validated.
+func (s *UserService) createUser(db *gorm.DB, req *models.UserRequest, customize func(*models.User) error) (*models.User, error) {
+	// Validate email format (if provided)
+	if req.Email != "" {
+		if err := utils.ValidateEmail(models.NormalizeEmail(req.Email)); err != nil {
+			return nil, validationError(err)
+		}
+	}
+
 	// Check if username already exists
 	var existingUser models.User
-	if err := s.db.Where("username = ?", req.Username).First(&existingUser).Error; err == nil {
-		return nil, errors.New("username already exists")
+	if err := db.Scopes(notDeleted).Where("username = ?", models.NormalizeUsername(req.Username)).First(&existingUser).Error; err == nil {
+		return nil, ErrDuplicateUsername
 	}
 
 	// Check if email already exists (if provided)
 	if req.Email != "" {
-		if err := s.db.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
-			return nil, errors.New("email already exists")
+		if err := db.Scopes(notDeleted).Where("email = ?", models.NormalizeEmail(req.Email)).First(&existingUser).Error; err == nil {
+			return nil, ErrDuplicateEmail
+		}
+		if err := checkSecondaryEmails(db, uuid.Nil, models.NormalizeEmail(req.Email)); err != nil {
+			return nil, err
 		}
 	}
 
@@ -45,14 +211,41 @@ func (s *UserService) CreateUser(req *models.UserRequest) (*models.User, error)
 	}
 
 	if err := user.FromRequest(req); err != nil {
-		return nil, fmt.Errorf("failed to create user from request: %w", err)
+		return nil, validationError(err)
+	}
+
+	// Grant the role's default permissions
+	for _, permission := range s.rolePermissions[user.Role] {
+		user.AddPermission(permission)
+	}
+
+	if customize != nil {
+		if err := customize(user); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := user.Validate(); err != nil {
-		return nil, fmt.Errorf("user validation failed: %w", err)
+		return nil, validationError(err)
 	}
 
-	if err := s.db.Create(user).Error; err != nil {
+	if err := s.validateMetadata(user.Metadata); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkUniqueMetadata(db, user); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkCrossIdentifiers(db, user); err != nil {
+		return nil, err
+	}
+
+	if err := s.retryWrite(db, func() error { return db.Create(user).Error }); err != nil {
+		// A concurrent insert can still win the race past the checks above
+		if dupErr := duplicateError(err); dupErr != nil {
+			return nil, dupErr
+		}
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
@@ -60,333 +253,1542 @@ func (s *UserService) CreateUser(req *models.UserRequest) (*models.User, error)
 }
 
 // GetUserByID retrieves a user by ID
-func (s *UserService) GetUserByID(id uuid.UUID) (*models.User, error) {
+func (s *UserService) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	var user models.User
+	if err := db.First(&user, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return &user, nil
+}
+
+// GetUserByIDUnscoped retrieves a user by ID including soft-deleted users.
+// It is meant for admin inspection; regular lookups use GetUserByID.
+func (s *UserService) GetUserByIDUnscoped(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
 	var user models.User
-	if err := s.db.First(&user, "id = ?", id).Error; err != nil {
+	if err := db.Unscoped().First(&user, "id = ?", id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 	return &user, nil
 }
 
+// MaxBatchGetIDs caps the number of ids GetUsersByIDs accepts
+const MaxBatchGetIDs = 100
+
+// GetUsersByIDs retrieves the users with the given ids in a single query.
+// Ids that don't match a user are absent from the result.
+func (s *UserService) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*models.User, error) {
+	if len(ids) > MaxBatchGetIDs {
+		return nil, validationError(fmt.Errorf("at most %d ids can be fetched at once", MaxBatchGetIDs))
+	}
+
+	result := make(map[uuid.UUID]*models.User, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	var users []*models.User
+	if err := db.Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+
+	for _, user := range users {
+		result[user.ID] = user
+	}
+	return result, nil
+}
+
 // GetUserByUsername retrieves a user by username
-func (s *UserService) GetUserByUsername(username string) (*models.User, error) {
+func (s *UserService) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
 	var user models.User
-	if err := s.db.Where("username = ?", username).First(&user).Error; err != nil {
+	if err := db.Scopes(notDeleted).Where("username = ?", models.NormalizeUsername(username)).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 	return &user, nil
 }
 
-// GetUserByEmail retrieves a user by email
-func (s *UserService) GetUserByEmail(email string) (*models.User, error) {
+// GetUserByEmail retrieves a user by their primary email, falling back to
+// their verified secondary addresses
+func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	email = models.NormalizeEmail(email)
+
 	var user models.User
-	if err := s.db.Where("email = ?", email).First(&user).Error; err != nil {
+	err := db.Scopes(notDeleted).Where("email = ?", email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		verified := db.Model(&models.UserEmail{}).Select("user_id").Where("email = ? AND verified = ?", email, true)
+		err = db.Scopes(notDeleted).Where("id IN (?)", verified).First(&user).Error
+	}
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 	return &user, nil
 }
 
-// UpdateUser updates an existing user
-func (s *UserService) UpdateUser(id uuid.UUID, updates map[string]interface{}) (*models.User, error) {
-	user, err := s.GetUserByID(id)
+// IsUsernameAvailable reports whether no live user holds the username;
+// deleted users release theirs. Usernames are stored normalized, so the
+// check is case-insensitive.
+func (s *UserService) IsUsernameAvailable(ctx context.Context, username string) (bool, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	var count int64
+	if err := db.Model(&models.User{}).Scopes(notDeleted).
+		Where("username = ?", models.NormalizeUsername(username)).
+		Limit(1).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check username availability: %w", err)
+	}
+	return count == 0, nil
+}
+
+// IsEmailAvailable reports whether no live user holds the email; deleted
+// users release theirs. Emails are stored normalized, so the check is
+// case-insensitive.
+func (s *UserService) IsEmailAvailable(ctx context.Context, email string) (bool, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	var count int64
+	if err := db.Model(&models.User{}).Scopes(notDeleted).
+		Where("email = ?", models.NormalizeEmail(email)).
+		Limit(1).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check email availability: %w", err)
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	if err := checkSecondaryEmails(db, uuid.Nil, models.NormalizeEmail(email)); err != nil {
+		if errors.Is(err, ErrDuplicateEmail) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// UpdateUser applies an update to an existing user on behalf of actorID
+func (s *UserService) UpdateUser(ctx context.Context, id, actorID uuid.UUID, update models.UserUpdate) (*models.User, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	user, err := s.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	oldUsername, oldEmail := user.Username, user.Email
+
+	if update.Username != nil {
+		user.Username = models.NormalizeUsername(*update.Username)
+	}
+	if update.Name != nil {
+		user.Name = *update.Name
+	}
+	if update.Age != nil {
+		user.Age = *update.Age
+	}
+	if update.Email != nil {
+		email := models.NormalizeEmail(*update.Email)
+		if email != "" {
+			if err := utils.ValidateEmail(email); err != nil {
+				return nil, validationError(err)
+			}
+		}
+		// Verification belonged to the old address
+		if email != user.Email {
+			user.EmailVerified = false
+		}
+		user.Email = email
+	}
+	if update.Metadata != nil {
+		user.Metadata = update.Metadata
+	}
+
+	if err := user.Validate(); err != nil {
+		return nil, validationError(err)
+	}
+
+	if err := s.validateMetadata(user.Metadata); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkUniqueMetadata(db, user); err != nil {
+		return nil, err
+	}
+
+	err = s.transaction(db, func(tx *gorm.DB) error {
+		if user.Username != oldUsername {
+			// Sessions and login state are keyed by id, so only the
+			// username itself has to be free
+			var count int64
+			if err := tx.Model(&models.User{}).Scopes(notDeleted).
+				Where("username = ? AND id <> ?", user.Username, user.ID).
+				Count(&count).Error; err != nil {
+				return fmt.Errorf("failed to check username availability: %w", err)
+			}
+			if count > 0 {
+				return ErrDuplicateUsername
+			}
+
+			if err := recordAudit(tx, actorID, AuditActionUsernameChange, userResource(user.ID), map[string]interface{}{
+				"old_username": oldUsername,
+				"new_username": user.Username,
+			}); err != nil {
+				return err
+			}
+		}
+
+		if user.Username != oldUsername || user.Email != oldEmail {
+			if err := s.checkCrossIdentifiers(tx, user); err != nil {
+				return err
+			}
+		}
+
+		if user.Email != oldEmail && user.Email != "" {
+			if err := checkSecondaryEmails(tx, user.ID, user.Email); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Save(user).Error; err != nil {
+			if dupErr := duplicateError(err); dupErr != nil {
+				return dupErr
+			}
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+
+		if user.Email != oldEmail {
+			return syncPrimaryEmail(tx, user)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// UpdateMetadata merges metadata keys into a user's metadata and removes the
+// given keys. The row is re-read inside a transaction so concurrent merges
+// don't overwrite each other's keys.
+func (s *UserService) UpdateMetadata(ctx context.Context, id uuid.UUID, set map[string]interface{}, remove []string) (*models.User, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	var user models.User
+
+	err := s.transaction(db, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+
+		for key, value := range set {
+			user.SetMetadata(key, value)
+		}
+
+		for _, key := range remove {
+			user.RemoveMetadata(key)
+		}
+
+		if err := s.validateMetadata(user.Metadata); err != nil {
+			return err
+		}
+
+		if err := s.checkUniqueMetadata(tx, &user); err != nil {
+			return err
+		}
+
+		if err := tx.Save(&user).Error; err != nil {
+			if dupErr := duplicateError(err); dupErr != nil {
+				return dupErr
+			}
+			return fmt.Errorf("failed to update metadata: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// DeleteUser soft deletes a user. The optional reason is stored on the user
+// and recorded in the audit log.
+func (s *UserService) DeleteUser(ctx context.Context, id uuid.UUID, reason string) error {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	user, err := s.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := user.Delete(); err != nil {
+		return validationError(err)
+	}
+	user.DeletionReason = strings.TrimSpace(reason)
+
+	if err := s.retryWrite(db, func() error { return db.Save(user).Error }); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	s.publish(ctx, UserDeleted{UserID: user.ID, Reason: user.DeletionReason})
+	return nil
+}
+
+// RestoreUser brings a deleted user back as active. Deleted users release
+// their username and email, so restoring fails with ErrDuplicateUsername or
+// ErrDuplicateEmail if another user has taken either since.
+func (s *UserService) RestoreUser(ctx context.Context, id, actorID uuid.UUID) (*models.User, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	user, err := s.GetUserByIDUnscoped(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := user.Restore(); err != nil {
+		return nil, validationError(err)
+	}
+
+	err = s.transaction(db, func(tx *gorm.DB) error {
+		if err := checkIdentityAvailable(tx, user); err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Save(user).Error; err != nil {
+			if dupErr := duplicateError(err); dupErr != nil {
+				return dupErr
+			}
+			return fmt.Errorf("failed to restore user: %w", err)
+		}
+
+		// Secondary addresses claimed by someone else in the meantime stay
+		// with their new owner
+		taken := tx.Session(&gorm.Session{NewDB: true}).Model(&models.User{}).Scopes(notDeleted).
+			Where("id <> ?", user.ID).Select("email")
+		if err := tx.Where("user_id = ? AND is_primary = ? AND email IN (?)", user.ID, false, taken).
+			Delete(&models.UserEmail{}).Error; err != nil {
+			return fmt.Errorf("failed to restore emails: %w", err)
+		}
+
+		return recordAudit(tx, actorID, AuditActionRestore, userResource(user.ID), nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// checkIdentityAvailable returns ErrDuplicateUsername or ErrDuplicateEmail
+// if a live user other than user holds its username or email
+func checkIdentityAvailable(db *gorm.DB, user *models.User) error {
+	var count int64
+	if err := db.Model(&models.User{}).Scopes(notDeleted).
+		Where("username = ? AND id <> ?", user.Username, user.ID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check username availability: %w", err)
+	}
+	if count > 0 {
+		return ErrDuplicateUsername
+	}
+
+	if user.Email == "" {
+		return nil
+	}
+
+	if err := db.Model(&models.User{}).Scopes(notDeleted).
+		Where("email = ? AND id <> ?", user.Email, user.ID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check email availability: %w", err)
+	}
+	if count > 0 {
+		return ErrDuplicateEmail
+	}
+	return checkSecondaryEmails(db, user.ID, user.Email)
+}
+
+// checkCrossIdentifiers keeps login identifiers unambiguous when
+// UnifiedLoginIdentifiers is enabled: it returns ErrDuplicateUsername if
+// user's username is another live user's email, and ErrDuplicateEmail if
+// user's email is another live user's username. Both directions are
+// checked with one query; identifiers are stored lowercase, so the check
+// is case-insensitive.
+func (s *UserService) checkCrossIdentifiers(db *gorm.DB, user *models.User) error {
+	if !s.config.UnifiedLoginIdentifiers {
+		return nil
+	}
+
+	collides := db.Where("email = ?", user.Username)
+	if user.Email != "" {
+		collides = collides.Or("username = ?", user.Email)
+	}
+
+	var match models.User
+	err := db.Model(&models.User{}).Scopes(notDeleted).
+		Select("username", "email").
+		Where("id <> ?", user.ID).
+		Where(collides).
+		Take(&match).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check identifier availability: %w", err)
+	}
+
+	if match.Email == user.Username {
+		return ErrDuplicateUsername
+	}
+	return ErrDuplicateEmail
+}
+
+// notDeleted excludes users marked deleted by status. Rows soft-deleted
+// through DeletedAt are already excluded by GORM's default scope.
+func notDeleted(db *gorm.DB) *gorm.DB {
+	return db.Where("status <> ?", models.StatusDeleted)
+}
+
+// HardDeleteByDefault reports whether deletions requested without an
+// explicit mode should be permanent
+func (s *UserService) HardDeleteByDefault() bool {
+	return s.config.HardDeleteByDefault
+}
+
+// HardDeleteUser permanently deletes a user together with their sessions.
+// Audit log entries about the user are anonymized or deleted depending on
+// the AuditOnHardDelete setting, and an entry recording the permanent
+// deletion is written. Either everything is removed or nothing is.
+func (s *UserService) HardDeleteUser(ctx context.Context, id, actorID uuid.UUID, reason string) error {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	err := s.transaction(db, func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", id).Delete(&models.RefreshToken{}).Error; err != nil {
+			return fmt.Errorf("failed to delete sessions: %w", err)
+		}
+
+		if err := tx.Where("user_id = ?", id).Delete(&models.LoginEvent{}).Error; err != nil {
+			return fmt.Errorf("failed to delete login history: %w", err)
+		}
+
+		if err := tx.Where("user_id = ?", id).Delete(&models.UserEmail{}).Error; err != nil {
+			return fmt.Errorf("failed to delete emails: %w", err)
+		}
+
+		if err := s.purgeAuditLogs(tx, id); err != nil {
+			return err
+		}
+
+		result := tx.Unscoped().Delete(&models.User{}, "id = ?", id)
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete user: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrUserNotFound
+		}
+
+		details := map[string]interface{}{"permanent": true}
+		if reason = strings.TrimSpace(reason); reason != "" {
+			details["reason"] = reason
+		}
+		return recordAudit(tx, actorID, AuditActionDelete, userResource(id), details)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to hard delete user: %w", err)
+	}
+
+	s.publish(ctx, UserDeleted{UserID: id, Hard: true})
+	return nil
+}
+
+// purgeAuditLogs removes or anonymizes the audit trail of a user
+func (s *UserService) purgeAuditLogs(tx *gorm.DB, id uuid.UUID) error {
+	switch s.config.AuditOnHardDelete {
+	case utils.AuditRetentionDelete:
+		if err := tx.Where("user_id = ? OR resource = ?", id, userResource(id)).
+			Delete(&utils.AuditLog{}).Error; err != nil {
+			return fmt.Errorf("failed to delete audit logs: %w", err)
+		}
+	case utils.AuditRetentionAnonymize:
+		if err := redactAuditLogs(tx, id); err != nil {
+			return err
+		}
+		if err := tx.Model(&utils.AuditLog{}).Where("user_id = ?", id).Update("user_id", uuid.Nil).Error; err != nil {
+			return fmt.Errorf("failed to anonymize audit logs: %w", err)
+		}
+	default:
+		return fmt.Errorf("invalid audit retention mode: %s", s.config.AuditOnHardDelete)
+	}
+
+	return nil
+}
+
+// ActivateUser activates a user account on behalf of an admin
+func (s *UserService) ActivateUser(ctx context.Context, id, actorID uuid.UUID) (*models.User, error) {
+	return s.changeStatus(ctx, id, actorID, AuditActionActivate, (*models.User).Activate)
+}
+
+// DeactivateUser deactivates a user account on behalf of an admin
+func (s *UserService) DeactivateUser(ctx context.Context, id, actorID uuid.UUID) (*models.User, error) {
+	return s.changeStatus(ctx, id, actorID, AuditActionDeactivate, (*models.User).Deactivate)
+}
+
+// SuspendUser suspends a user account on behalf of an admin
+func (s *UserService) SuspendUser(ctx context.Context, id, actorID uuid.UUID) (*models.User, error) {
+	user, err := s.changeStatus(ctx, id, actorID, AuditActionSuspend, (*models.User).Suspend)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publish(ctx, UserSuspended{UserID: user.ID, ActorID: actorID})
+	return user, nil
+}
+
+// UnlockUser clears a user's failed login attempts and lockout on behalf
+// of an admin without touching the password. A user suspended by the
+// lockout is reactivated; a user an admin suspended stays suspended.
+func (s *UserService) UnlockUser(ctx context.Context, id, actorID uuid.UUID) (*models.User, error) {
+	return s.changeStatus(ctx, id, actorID, AuditActionUnlock, (*models.User).Unlock)
+}
+
+// SetShadowed shadow bans a user, or lifts the ban, on behalf of an admin.
+// It doesn't change the user's status or sessions; requests authenticated
+// as the user see the new flag once the cached token state is refreshed.
+func (s *UserService) SetShadowed(ctx context.Context, id, actorID uuid.UUID, shadowed bool) (*models.User, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	user, err := s.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.Shadowed == shadowed {
+		return user, nil
+	}
+
+	action := AuditActionShadow
+	if !shadowed {
+		action = AuditActionUnshadow
+	}
+
+	user.Shadowed = shadowed
+	defer tokenVersions.invalidate(user.ID)
+
+	err = s.transaction(db, func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("shadowed", shadowed).Error; err != nil {
+			return fmt.Errorf("failed to update shadow ban: %w", err)
+		}
+
+		return recordAudit(tx, actorID, action, userResource(user.ID), nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// UpdateStatus moves a user to status on behalf of an admin. Deletion is
+// not a status update; use DeleteUser.
+func (s *UserService) UpdateStatus(ctx context.Context, id, actorID uuid.UUID, status models.UserStatus) (*models.User, error) {
+	switch status {
+	case models.StatusActive:
+		return s.ActivateUser(ctx, id, actorID)
+	case models.StatusInactive:
+		return s.DeactivateUser(ctx, id, actorID)
+	case models.StatusSuspended:
+		return s.SuspendUser(ctx, id, actorID)
+	default:
+		return nil, validationError(fmt.Errorf("cannot set status to %s", status))
+	}
+}
+
+// MaxBulkStatusIDs caps the number of ids BulkUpdateStatus accepts
+const MaxBulkStatusIDs = 500
+
+// BulkUpdateStatus moves many users to status with a single UPDATE and
+// records one audit entry listing the affected ids. Each user goes through
+// the same transition as UpdateStatus would apply to it alone; deleted
+// users, unknown ids and users already in status are skipped, so updated
+// counts only actual changes. As for a single user, users moved to a
+// status other than active lose their access tokens, and each suspended
+// user is published as UserSuspended.
+func (s *UserService) BulkUpdateStatus(ctx context.Context, ids []uuid.UUID, actorID uuid.UUID, status models.UserStatus) (int64, error) {
+	var apply func(*models.User) error
+	switch status {
+	case models.StatusActive:
+		apply = (*models.User).Activate
+	case models.StatusInactive:
+		apply = (*models.User).Deactivate
+	case models.StatusSuspended:
+		apply = (*models.User).Suspend
+	default:
+		return 0, validationError(fmt.Errorf("cannot set status to %s", status))
+	}
+	if len(ids) > MaxBulkStatusIDs {
+		return 0, validationError(fmt.Errorf("at most %d ids can be updated at once", MaxBulkStatusIDs))
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	var affected []uuid.UUID
+	var updated int64
+	err := s.transaction(db, func(tx *gorm.DB) error {
+		var users []*models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(notDeleted).
+			Where("id IN ? AND status <> ?", ids, status).
+			Order("id").Find(&users).Error; err != nil {
+			return fmt.Errorf("failed to find users: %w", err)
+		}
+
+		// Every user leaving another status for the same one ends up with
+		// the same status fields, so one UPDATE can write them all
+		var changed *models.User
+		for _, user := range users {
+			if err := apply(user); err != nil {
+				continue
+			}
+			affected = append(affected, user.ID)
+			changed = user
+		}
+		if changed == nil {
+			return nil
+		}
+
+		updates := map[string]interface{}{
+			"status":           changed.Status,
+			"suspension_cause": changed.SuspensionCause,
+			"locked_at":        changed.LockedAt,
+		}
+		if status == models.StatusActive {
+			updates["login_attempts"] = changed.LoginAttempts
+		} else {
+			updates["token_version"] = gorm.Expr("token_version + 1")
+		}
+
+		result := tx.Model(&models.User{}).Where("id IN ?", affected).Updates(updates)
+		if result.Error != nil {
+			return fmt.Errorf("failed to update user status: %w", result.Error)
+		}
+		updated = result.RowsAffected
+
+		return recordAudit(tx, actorID, AuditActionBulkStatus, "users", map[string]interface{}{
+			"to":    status,
+			"ids":   affected,
+			"count": len(affected),
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range affected {
+		if status != models.StatusActive {
+			tokenVersions.invalidate(id)
+		}
+		if status == models.StatusSuspended {
+			s.publish(ctx, UserSuspended{UserID: id, ActorID: actorID})
+		}
+	}
+	return updated, nil
+}
+
+// changeStatus applies a status transition and records it in the audit log.
+// A user moved to a status other than active loses their access tokens.
+func (s *UserService) changeStatus(ctx context.Context, id, actorID uuid.UUID, action string, apply func(*models.User) error) (*models.User, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	user, err := s.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := user.Status
+	if err := apply(user); err != nil {
+		return nil, validationError(err)
+	}
+
+	if user.Status != previous && user.Status != models.StatusActive {
+		user.TokenVersion++
+		defer tokenVersions.invalidate(user.ID)
+	}
+
+	err = s.transaction(db, func(tx *gorm.DB) error {
+		if err := tx.Save(user).Error; err != nil {
+			return fmt.Errorf("failed to update user status: %w", err)
+		}
+
+		return recordAudit(tx, actorID, action, userResource(user.ID), map[string]interface{}{
+			"from": previous,
+			"to":   user.Status,
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Apply updates
-	for key, value := range updates {
-		switch key {
-		case "name":
-			if name, ok := value.(string); ok {
-				user.Name = name
-			}
-		case "age":
-			if age, ok := value.(int); ok {
-				user.Age = age
-			}
-		case "email":
-			if email, ok := value.(string); ok {
-				user.Email = email
-			}
-		case "role":
-			if role, ok := value.(models.UserRole); ok {
-				user.Role = role
-			}
-		case "status":
-			if status, ok := value.(models.UserStatus); ok {
-				user.Status = status
-			}
-		case "metadata":
-			if metadata, ok := value.(map[string]interface{}); ok {
-				user.Metadata = metadata
-			}
-		}
-	}
+	return user, nil
+}
 
-	if err := user.Validate(); err != nil {
-		return nil, fmt.Errorf("user validation failed: %w", err)
+// GetAllUsers retrieves all users with pagination
+func (s *UserService) GetAllUsers(ctx context.Context, page, pageSize int) ([]*models.User, int64, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	var users []*models.User
+	var total int64
+
+	// Count total users
+	if err := db.Model(&models.User{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
 	}
 
-	if err := s.db.Save(user).Error; err != nil {
-		return nil, fmt.Errorf("failed to update user: %w", err)
+	// Get users with pagination, oldest first; id breaks ties between users
+	// created in the same instant so pages don't overlap
+	offset := (page - 1) * pageSize
+	if err := db.Order("created_at, id").Limit(pageSize).Offset(offset).Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get users: %w", err)
 	}
 
-	return user, nil
+	return users, total, nil
 }
 
-// DeleteUser soft deletes a user
-func (s *UserService) DeleteUser(id uuid.UUID) error {
-	user, err := s.GetUserByID(id)
-	if err != nil {
-		return err
-	}
+// FilterUsers retrieves a page of users matching every set field of filter
+func (s *UserService) FilterUsers(ctx context.Context, filter *utils.FilterParams, page, pageSize int) ([]*models.User, int64, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
 
-	user.Delete()
+	query := applyUserFilter(db.Model(&models.User{}), filter)
 
-	if err := s.db.Save(user).Error; err != nil {
-		return fmt.Errorf("failed to delete user: %w", err)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
 	}
 
-	return nil
+	var users []*models.User
+	offset := (page - 1) * pageSize
+	if err := query.Order("created_at, id").Limit(pageSize).Offset(offset).Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to filter users: %w", err)
+	}
+
+	return users, total, nil
 }
 
-// HardDeleteUser permanently deletes a user
-func (s *UserService) HardDeleteUser(id uuid.UUID) error {
-	if err := s.db.Unscoped().Delete(&models.User{}, id).Error; err != nil {
-		return fmt.Errorf("failed to hard delete user: %w", err)
+// SearchByMetadata lists users whose metadata has key set to value
+func (s *UserService) SearchByMetadata(ctx context.Context, key, value string, page, pageSize int) ([]*models.User, int64, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	condition, args, err := metadataCondition(db, key, value)
+	if err != nil {
+		return nil, 0, err
 	}
-	return nil
-}
 
-// GetAllUsers retrieves all users with pagination
-func (s *UserService) GetAllUsers(page, pageSize int) ([]*models.User, int64, error) {
-	var users []*models.User
-	var total int64
+	query := db.Model(&models.User{}).Where(condition, args...)
 
-	// Count total users
-	if err := s.db.Model(&models.User{}).Count(&total).Error; err != nil {
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to count users: %w", err)
 	}
 
-	// Get users with pagination
+	var users []*models.User
 	offset := (page - 1) * pageSize
-	if err := s.db.Limit(pageSize).Offset(offset).Find(&users).Error; err != nil {
-		return nil, 0, fmt.Errorf("failed to get users: %w", err)
+	if err := query.Order("created_at, id").Limit(pageSize).Offset(offset).Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to search users by metadata: %w", err)
 	}
 
 	return users, total, nil
 }
 
+// applyUserFilter adds a WHERE condition for each set field of filter
+func applyUserFilter(query *gorm.DB, filter *utils.FilterParams) *gorm.DB {
+	if filter == nil {
+		return query
+	}
+
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.AgeMin > 0 {
+		query = query.Where("age >= ?", filter.AgeMin)
+	}
+	if filter.AgeMax > 0 {
+		query = query.Where("age <= ?", filter.AgeMax)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		query = query.Where("created_at < ?", filter.CreatedBefore)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		query = query.Where("created_at > ?", filter.CreatedAfter)
+	}
+	if !filter.LastLoginBefore.IsZero() {
+		query = query.Where("last_login < ?", filter.LastLoginBefore)
+	}
+	if filter.NeverLoggedIn {
+		query = query.Where("last_login IS NULL")
+	}
+
+	return query
+}
+
 // GetActiveUsers retrieves all active users
-func (s *UserService) GetActiveUsers() ([]*models.User, error) {
+func (s *UserService) GetActiveUsers(ctx context.Context) ([]*models.User, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
 	var users []*models.User
-	if err := s.db.Where("status = ?", models.StatusActive).Find(&users).Error; err != nil {
+	if err := db.Where("status = ?", models.StatusActive).Find(&users).Error; err != nil {
 		return nil, fmt.Errorf("failed to get active users: %w", err)
 	}
 	return users, nil
 }
 
-// GetUsersByRole retrieves users by role
-func (s *UserService) GetUsersByRole(role models.UserRole) ([]*models.User, error) {
-	var users []*models.User
-	if err := s.db.Where("role = ?", role).Find(&users).Error; err != nil {
-		return nil, fmt.Errorf("failed to get users by role: %w", err)
+// GetUsersByRole retrieves a page of the users with role, in the order
+// GetUsers lists them
+func (s *UserService) GetUsersByRole(ctx context.Context, role models.UserRole, page, pageSize int) ([]*models.User, int64, error) {
+	if !role.Valid() {
+		return nil, 0, validationError(fmt.Errorf("invalid role: %s", role))
 	}
-	return users, nil
+	return s.FilterUsers(ctx, &utils.FilterParams{Role: string(role)}, page, pageSize)
 }
 
-// SearchUsers searches for users by name or username
-func (s *UserService) SearchUsers(query string, page, pageSize int) ([]*models.User, int64, error) {
+// searchableFields are the columns SearchUsers may match against
+var searchableFields = []string{"name", "username", "email"}
+
+// SearchUsers searches for users by name, username or email. Fields restricts
+// which columns are searched; when empty all searchable fields are used.
+// Results are ordered by params.SortBy and SortDir, defaulting to the
+// configured search sort, so paging through them is stable.
+func (s *UserService) SearchUsers(ctx context.Context, params *utils.SearchParams, fields []string) ([]*models.User, int64, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
 	var users []*models.User
 	var total int64
 
-	searchQuery := "%" + strings.ToLower(query) + "%"
+	query, ok, err := s.checkSearchQuery(params.Query)
+	if !ok {
+		return []*models.User{}, 0, err
+	}
+
+	order, err := s.searchOrder(query, params)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	where, args, err := searchCondition(db, query, fields)
+	if err != nil {
+		return nil, 0, err
+	}
 
 	// Count total matching users
-	if err := s.db.Model(&models.User{}).Where(
-		"LOWER(name) LIKE ? OR LOWER(username) LIKE ? OR LOWER(email) LIKE ?",
-		searchQuery, searchQuery, searchQuery,
-	).Count(&total).Error; err != nil {
+	if err := db.Model(&models.User{}).Where(where, args...).Count(&total).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to count search results: %w", err)
 	}
 
 	// Get matching users with pagination
-	offset := (page - 1) * pageSize
-	if err := s.db.Where(
-		"LOWER(name) LIKE ? OR LOWER(username) LIKE ? OR LOWER(email) LIKE ?",
-		searchQuery, searchQuery, searchQuery,
-	).Limit(pageSize).Offset(offset).Find(&users).Error; err != nil {
+	offset := (params.Page - 1) * params.PageSize
+	if err := db.Where(where, args...).Clauses(order).
+		Limit(params.PageSize).Offset(offset).Find(&users).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to search users: %w", err)
 	}
 
 	return users, total, nil
 }
 
-// GetUserStats returns user statistics
-func (s *UserService) GetUserStats() (*utils.UserStats, error) {
+// searchOrder returns the ORDER BY for a search. An unset sort takes the
+// configured default; relevance ranks exact and prefix username matches
+// above other matches. Every order ends with id so ties keep their place
+// from page to page.
+func (s *UserService) searchOrder(query string, params *utils.SearchParams) (clause.OrderBy, error) {
+	sort := *params
+	if sort.SortBy == "" {
+		sort.SortBy = s.config.SearchSortBy
+		if sort.SortDir == "" {
+			sort.SortDir = s.config.SearchSortDir
+		}
+	}
+	if sort.SortBy == "" {
+		sort.SortBy = "username"
+	}
+	if sort.SortDir == "" {
+		sort.SortDir = "asc"
+	}
+
+	if sort.SortBy == utils.SortRelevance {
+		lowered := strings.ToLower(query)
+		return clause.OrderBy{Expression: clause.Expr{
+			SQL:                "CASE WHEN LOWER(username) = ? THEN 0 WHEN LOWER(username) LIKE ? THEN 1 ELSE 2 END, username, id",
+			Vars:               []interface{}{lowered, lowered + "%"},
+			WithoutParentheses: true,
+		}}, nil
+	}
+
+	if err := sort.Validate(); err != nil {
+		return clause.OrderBy{}, validationError(err)
+	}
+	return clause.OrderBy{Columns: []clause.OrderByColumn{
+		{Column: clause.Column{Name: sort.SortBy}, Desc: sort.SortDir == "desc"},
+		{Column: clause.Column{Name: "id"}},
+	}}, nil
+}
+
+// checkSearchQuery trims query and checks it against MinSearchLength. It
+// reports false if the search shouldn't run, with a validation error under
+// the reject policy and none under the empty policy.
+func (s *UserService) checkSearchQuery(query string) (string, bool, error) {
+	query = strings.TrimSpace(query)
+	if utf8.RuneCountInString(query) >= s.config.MinSearchLength {
+		return query, true, nil
+	}
+
+	if s.config.ShortSearchPolicy == utils.ShortSearchEmpty {
+		return query, false, nil
+	}
+	return query, false, validationError(fmt.Errorf("search query must be at least %d characters", s.config.MinSearchLength))
+}
+
+// searchCondition builds the WHERE condition matching query against fields,
+// which default to every searchable field
+func searchCondition(db *gorm.DB, query string, fields []string) (string, []interface{}, error) {
+	if len(fields) == 0 {
+		fields = searchableFields
+	}
+	if err := validateSearchFields(fields); err != nil {
+		return "", nil, err
+	}
+
+	// The filter depends on the dialect: trigram indexes on postgres, LIKE elsewhere
+	where, args := searchStrategyFor(db).condition(query, fields)
+	return where, args, nil
+}
+
+// validateSearchFields checks that every field may be searched
+func validateSearchFields(fields []string) error {
+	for _, field := range fields {
+		if !isSearchableField(field) {
+			return validationError(fmt.Errorf("invalid search field: %s", field))
+		}
+	}
+	return nil
+}
+
+// isSearchableField checks if a column may be used in SearchUsers
+func isSearchableField(field string) bool {
+	for _, f := range searchableFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// GetUserStats returns user statistics. When a stats cache TTL is
+// configured the statistics may be that old; use GetUserStatsFresh for
+// current numbers. Inside WithTx the cache is bypassed.
+func (s *UserService) GetUserStats(ctx context.Context) (*utils.UserStats, error) {
+	if !s.inTx {
+		if stats, ok := s.stats.get(); ok {
+			return stats, nil
+		}
+	}
+	return s.GetUserStatsFresh(ctx)
+}
+
+// GetUserStatsFresh returns user statistics counted now, bypassing the
+// stats cache. Outside WithTx the result also refreshes the cache.
+func (s *UserService) GetUserStatsFresh(ctx context.Context) (*utils.UserStats, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
 	var stats utils.UserStats
 
+	// Users deleted by status aren't hidden by GORM's default scope, so
+	// exclude them explicitly to count the same whichever way a user was
+	// deleted
+	live := func() *gorm.DB { return db.Model(&models.User{}).Scopes(notDeleted) }
+
 	// Total users
-	if err := s.db.Model(&models.User{}).Count(&stats.Total).Error; err != nil {
+	if err := live().Count(&stats.Total).Error; err != nil {
 		return nil, fmt.Errorf("failed to count total users: %w", err)
 	}
 
 	// Active users
-	if err := s.db.Model(&models.User{}).Where("status = ?", models.StatusActive).Count(&stats.Active).Error; err != nil {
+	if err := live().Where("status = ?", models.StatusActive).Count(&stats.Active).Error; err != nil {
 		return nil, fmt.Errorf("failed to count active users: %w", err)
 	}
 
 	// Admin users
-	if err := s.db.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&stats.Admin).Error; err != nil {
+	if err := live().Where("role = ?", models.RoleAdmin).Count(&stats.Admin).Error; err != nil {
 		return nil, fmt.Errorf("failed to count admin users: %w", err)
 	}
 
 	// Regular users
-	if err := s.db.Model(&models.User{}).Where("role = ?", models.RoleUser).Count(&stats.User).Error; err != nil {
+	if err := live().Where("role = ?", models.RoleUser).Count(&stats.User).Error; err != nil {
 		return nil, fmt.Errorf("failed to count regular users: %w", err)
 	}
 
 	// Guest users
-	if err := s.db.Model(&models.User{}).Where("role = ?", models.RoleGuest).Count(&stats.Guest).Error; err != nil {
+	if err := live().Where("role = ?", models.RoleGuest).Count(&stats.Guest).Error; err != nil {
 		return nil, fmt.Errorf("failed to count guest users: %w", err)
 	}
 
 	// Users with email
-	if err := s.db.Model(&models.User{}).Where("email != ''").Count(&stats.WithEmail).Error; err != nil {
+	if err := live().Where("email != ''").Count(&stats.WithEmail).Error; err != nil {
 		return nil, fmt.Errorf("failed to count users with email: %w", err)
 	}
 
+	// Deleted users, by either path
+	if err := db.Unscoped().Model(&models.User{}).
+		Where("deleted_at IS NOT NULL OR status = ?", models.StatusDeleted).
+		Count(&stats.Deleted).Error; err != nil {
+		return nil, fmt.Errorf("failed to count deleted users: %w", err)
+	}
+	stats.TotalIncludingDeleted = stats.Total + stats.Deleted
+
+	if !s.inTx {
+		s.stats.set(&stats)
+	}
 	return &stats, nil
 }
 
-// AuthenticateUser authenticates a user with username and password
-func (s *UserService) AuthenticateUser(username, password string) (*models.User, error) {
-	user, err := s.GetUserByUsername(username)
+// AuthenticateUser authenticates a user with username and password and
+// records the client IP the login came from. Users with two-factor
+// authentication enabled must also give a second factor.
+func (s *UserService) AuthenticateUser(ctx context.Context, username, password string, factor SecondFactor, clientIP string) (*models.User, error) {
+	return s.authenticate(ctx, models.NormalizeUsername(username), password, factor, clientIP, s.GetUserByUsername)
+}
+
+// AuthenticateByIdentifier authenticates a user by username or email.
+// Identifiers that look like an email address are looked up by email,
+// anything else by username.
+func (s *UserService) AuthenticateByIdentifier(ctx context.Context, identifier, password string, factor SecondFactor, clientIP string) (*models.User, error) {
+	if models.LooksLikeEmail(identifier) {
+		return s.authenticate(ctx, models.NormalizeEmail(identifier), password, factor, clientIP, s.GetUserByEmail)
+	}
+	return s.AuthenticateUser(ctx, identifier, password, factor, clientIP)
+}
+
+// authenticate verifies the password, and the second factor if the user
+// has two-factor authentication enabled, of the user found by lookup. Unknown identifiers still pay for a
+// bcrypt comparison so that timing doesn't reveal which accounts exist.
+func (s *UserService) authenticate(ctx context.Context, identifier, password string, factor SecondFactor, clientIP string, lookup func(context.Context, string) (*models.User, error)) (*models.User, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	failed := LoginFailed{Username: identifier, ClientIP: clientIP, UserAgent: userAgentFrom(ctx)}
+
+	user, err := lookup(ctx, identifier)
 	if err != nil {
-		return nil, errors.New("invalid username or password")
+		models.VerifyDummyPassword(password)
+		s.publish(ctx, failed)
+		return nil, ErrInvalidCredentials
 	}
+	failed.UserID = user.ID
+
+	// The password is compared before any account checks so every branch
+	// performs exactly one password hash comparison
+	passwordOK := user.VerifyPassword(password)
 
 	if !user.IsActive() {
+		s.publish(ctx, failed)
 		return nil, errors.New("user account is not active")
 	}
 
 	if user.IsLocked() {
+		s.publish(ctx, failed)
 		return nil, errors.New("user account is locked")
 	}
 
-	if !user.VerifyPassword(password) {
+	if !passwordOK {
 		user.FailedLoginAttempt()
-		if err := s.db.Save(user).Error; err != nil {
+		if err := s.retryWrite(db, func() error { return db.Save(user).Error }); err != nil {
 			return nil, fmt.Errorf("failed to update failed login attempt: %w", err)
 		}
-		return nil, errors.New("invalid username or password")
+		failed.Locked = user.IsLocked()
+		s.publish(ctx, failed)
+		return nil, ErrInvalidCredentials
+	}
+
+	// The second factor is checked before the login counts as successful,
+	// and a wrong code counts as a failed attempt. Running out of backup
+	// codes doesn't turn it off.
+	if user.TwoFactorEnabled {
+		err := s.verifySecondFactor(db, user, factor)
+		if errors.Is(err, ErrInvalidTwoFactorCode) || errors.Is(err, ErrInvalidBackupCode) {
+			user.FailedLoginAttempt()
+			if err := s.retryWrite(db, func() error { return db.Save(user).Error }); err != nil {
+				return nil, fmt.Errorf("failed to update failed login attempt: %w", err)
+			}
+			failed.Locked = user.IsLocked()
+			s.publish(ctx, failed)
+			return nil, err
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Upgrade hashes made with another algorithm or an outdated cost; the
+	// new hash is saved
+	// together with the login info below
+	if user.NeedsRehash() {
+		if err := user.RehashPassword(password); err != nil {
+			logger.Warn("failed to rehash password", "user_id", user.ID, "error", err)
+		}
 	}
 
 	// Successful login
-	if err := user.Login(); err != nil {
+	if err := user.Login(clientIP); err != nil {
+		s.publish(ctx, failed)
 		return nil, fmt.Errorf("login failed: %w", err)
 	}
 
-	if err := s.db.Save(user).Error; err != nil {
+	if err := s.retryWrite(db, func() error { return db.Save(user).Error }); err != nil {
 		return nil, fmt.Errorf("failed to update login info: %w", err)
 	}
 
+	s.publish(ctx, LoginSucceeded{UserID: user.ID, ClientIP: clientIP, UserAgent: userAgentFrom(ctx)})
 	return user, nil
 }
 
-// ChangePassword changes a user's password
-func (s *UserService) ChangePassword(id uuid.UUID, currentPassword, newPassword string) error {
-	user, err := s.GetUserByID(id)
+// PasswordExpired reports whether the user's password is older than the
+// configured MaxPasswordAge and should be changed
+func (s *UserService) PasswordExpired(user *models.User) bool {
+	return user.PasswordExpired(time.Duration(s.config.MaxPasswordAge) * 24 * time.Hour)
+}
+
+// ChangePassword changes a user's password and invalidates the user's
+// existing tokens. Attempts are throttled per user and both successful and
+// failed attempts are audited.
+func (s *UserService) ChangePassword(ctx context.Context, id uuid.UUID, currentPassword, newPassword string) error {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	user, err := s.GetUserByID(ctx, id)
 	if err != nil {
 		return err
 	}
 
+	if err := s.checkPasswordRateLimit(db, user.ID, passwordChangeActions, s.passwordChangeLimit()); err != nil {
+		return err
+	}
+
 	if !user.VerifyPassword(currentPassword) {
-		return errors.New("current password is incorrect")
+		if err := recordAudit(db, user.ID, AuditActionPasswordChangeFailed, userResource(user.ID), nil); err != nil {
+			return err
+		}
+		return validationError(errors.New("current password is incorrect"))
 	}
 
 	if err := user.SetPassword(newPassword); err != nil {
-		return fmt.Errorf("failed to set new password: %w", err)
+		return validationError(err)
 	}
 
-	if err := s.db.Save(user).Error; err != nil {
-		return fmt.Errorf("failed to update password: %w", err)
-	}
+	// Invalidate access tokens issued with the old password
+	user.TokenVersion++
+	defer tokenVersions.invalidate(user.ID)
 
-	return nil
+	return s.transaction(db, func(tx *gorm.DB) error {
+		if err := tx.Save(user).Error; err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+
+		if err := recordAudit(tx, user.ID, AuditActionPasswordChange, userResource(user.ID), nil); err != nil {
+			return err
+		}
+
+		return revokeUserRefreshTokens(tx, user.ID)
+	})
 }
 
-// ResetPassword resets a user's password (admin function)
-func (s *UserService) ResetPassword(id uuid.UUID, newPassword string) error {
-	user, err := s.GetUserByID(id)
+// ResetPassword resets a user's password (admin function) and invalidates
+// the user's existing tokens. Admin resets are only throttled when
+// AdminPasswordResetLimit is configured.
+func (s *UserService) ResetPassword(ctx context.Context, id, actorID uuid.UUID, newPassword string) error {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	user, err := s.GetUserByID(ctx, id)
 	if err != nil {
 		return err
 	}
 
+	if err := s.checkPasswordRateLimit(db, user.ID, []string{AuditActionPasswordReset}, s.config.AdminPasswordResetLimit); err != nil {
+		return err
+	}
+
 	if err := user.SetPassword(newPassword); err != nil {
-		return fmt.Errorf("failed to set new password: %w", err)
+		return validationError(err)
 	}
 
 	user.ResetLoginAttempts()
 
-	if err := s.db.Save(user).Error; err != nil {
-		return fmt.Errorf("failed to update password: %w", err)
+	// Invalidate access tokens issued with the old password
+	user.TokenVersion++
+	defer tokenVersions.invalidate(user.ID)
+
+	return s.transaction(db, func(tx *gorm.DB) error {
+		if err := tx.Save(user).Error; err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+
+		if err := recordAudit(tx, actorID, AuditActionPasswordReset, userResource(user.ID), nil); err != nil {
+			return err
+		}
+
+		return revokeUserRefreshTokens(tx, user.ID)
+	})
+}
+
+// passwordChangeLimit returns the number of self-service password changes
+// allowed per window
+func (s *UserService) passwordChangeLimit() int {
+	if s.config.PasswordChangeLimit <= 0 {
+		return defaultPasswordChangeLimit
+	}
+	return s.config.PasswordChangeLimit
+}
+
+// AddPermission adds a permission to a user
+func (s *UserService) AddPermission(ctx context.Context, id uuid.UUID, permission string) error {
+	return s.AddPermissions(ctx, id, permission)
+}
+
+// RemovePermission removes a permission from a user
+func (s *UserService) RemovePermission(ctx context.Context, id uuid.UUID, permission string) error {
+	return s.RemovePermissions(ctx, id, permission)
+}
+
+// AddPermissions adds several permissions to a user in one update
+func (s *UserService) AddPermissions(ctx context.Context, id uuid.UUID, permissions ...string) error {
+	permissions, err := models.NormalizePermissions(permissions)
+	if err != nil {
+		return validationError(err)
+	}
+
+	err = s.updatePermissions(ctx, id, func(user *models.User) error {
+		if err := s.checkAllowedPermissions(user.Role, permissions); err != nil {
+			return err
+		}
+		for _, permission := range permissions {
+			user.AddPermission(permission)
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to add permissions: %w", err)
 	}
+	return nil
+}
 
+// RemovePermissions removes several permissions from a user in one update
+func (s *UserService) RemovePermissions(ctx context.Context, id uuid.UUID, permissions ...string) error {
+	err := s.updatePermissions(ctx, id, func(user *models.User) error {
+		for _, permission := range permissions {
+			user.RemovePermission(permission)
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to remove permissions: %w", err)
+	}
 	return nil
 }
 
-// AddPermission adds a permission to a user
-func (s *UserService) AddPermission(id uuid.UUID, permission string) error {
-	user, err := s.GetUserByID(id)
+// CheckPermission reports whether a user is allowed the given permission
+func (s *UserService) CheckPermission(ctx context.Context, id uuid.UUID, permission string) (bool, error) {
+	user, err := s.GetUserByID(ctx, id)
 	if err != nil {
-		return err
+		return false, err
 	}
+	return user.Can(permission), nil
+}
 
-	user.AddPermission(permission)
+// ApplyRoleDefaults merges the default permissions of the user's role into
+// their current permissions
+func (s *UserService) ApplyRoleDefaults(ctx context.Context, id uuid.UUID) error {
+	err := s.updatePermissions(ctx, id, func(user *models.User) error {
+		for _, permission := range s.rolePermissions[user.Role] {
+			user.AddPermission(permission)
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to apply role defaults: %w", err)
+	}
+	return nil
+}
 
-	if err := s.db.Save(user).Error; err != nil {
-		return fmt.Errorf("failed to add permission: %w", err)
+// SetPermissions atomically replaces a user's permissions and records the
+// added and removed permissions in the audit log
+func (s *UserService) SetPermissions(ctx context.Context, id, actorID uuid.UUID, permissions []string) error {
+	permissions, err := models.NormalizePermissions(permissions)
+	if err != nil {
+		return validationError(err)
 	}
 
+	err = s.updatePermissions(ctx, id, func(user *models.User) error {
+		if err := s.checkAllowedPermissions(user.Role, permissions); err != nil {
+			return err
+		}
+		user.SetPermissions(permissions)
+		return nil
+	}, func(tx *gorm.DB, before, after []string) error {
+		added, removed := diffPermissions(before, after)
+		return recordAudit(tx, actorID, AuditActionSetPermissions, userResource(id), map[string]interface{}{
+			"added":   added,
+			"removed": removed,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
 	return nil
 }
 
-// RemovePermission removes a permission from a user
-func (s *UserService) RemovePermission(id uuid.UUID, permission string) error {
-	user, err := s.GetUserByID(id)
+// MaxBulkPermissionIDs caps the number of ids GrantPermissionToUsers accepts
+const MaxBulkPermissionIDs = 500
+
+// GrantPermissionToUsers grants permission to each of the users in one
+// transaction and reports the outcome per user, in the order of ids with
+// duplicates dropped. Unknown and deleted users, and users whose role may
+// not hold the permission, fail without affecting the others; users who
+// already hold it succeed unchanged. The grants that succeed are recorded
+// in a single audit entry. An error is returned only if the request as a
+// whole is invalid or the transaction fails, in which case nothing is
+// granted.
+func (s *UserService) GrantPermissionToUsers(ctx context.Context, ids []uuid.UUID, actorID uuid.UUID, permission string) ([]models.BulkResult, error) {
+	normalized, err := models.NormalizePermissions([]string{permission})
 	if err != nil {
-		return err
+		return nil, validationError(err)
+	}
+	permission = normalized[0]
+	if len(s.permissionCatalog) > 0 && !slices.Contains(s.permissionCatalog, permission) {
+		return nil, validationError(fmt.Errorf("%w: %q", ErrUnknownPermission, permission))
+	}
+	if len(ids) > MaxBulkPermissionIDs {
+		return nil, validationError(fmt.Errorf("at most %d ids can be updated at once", MaxBulkPermissionIDs))
+	}
+
+	seen := make(map[uuid.UUID]bool, len(ids))
+	unique := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) == 0 {
+		return []models.BulkResult{}, nil
+	}
+
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	var results []models.BulkResult
+	err = s.transaction(db, func(tx *gorm.DB) error {
+		// The transaction may be retried, so start from a clean slate
+		results = make([]models.BulkResult, 0, len(unique))
+
+		var users []*models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(notDeleted).
+			Where("id IN ?", unique).Find(&users).Error; err != nil {
+			return fmt.Errorf("failed to find users: %w", err)
+		}
+		byID := make(map[uuid.UUID]*models.User, len(users))
+		for _, user := range users {
+			byID[user.ID] = user
+		}
+
+		var granted []uuid.UUID
+		for _, id := range unique {
+			user, ok := byID[id]
+			if !ok {
+				results = append(results, models.BulkResult{ID: id, Error: ErrUserNotFound.Error()})
+				continue
+			}
+			if err := s.checkAllowedPermissions(user.Role, []string{permission}); err != nil {
+				results = append(results, models.BulkResult{ID: id, Error: err.Error()})
+				continue
+			}
+
+			if !user.HasPermission(permission) {
+				user.AddPermission(permission)
+				if err := tx.Save(user).Error; err != nil {
+					return fmt.Errorf("failed to grant permission: %w", err)
+				}
+				granted = append(granted, id)
+			}
+			results = append(results, models.BulkResult{ID: id, Success: true})
+		}
+
+		if len(granted) == 0 {
+			return nil
+		}
+		return recordAudit(tx, actorID, AuditActionBulkGrantPermissions, "users", map[string]interface{}{
+			"permission": permission,
+			"ids":        granted,
+			"count":      len(granted),
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	user.RemovePermission(permission)
+	return results, nil
+}
+
+// PermissionCatalog returns the permissions that may be granted, or an
+// empty list if permissions are unrestricted
+func (s *UserService) PermissionCatalog() []string {
+	return slices.Clone(s.permissionCatalog)
+}
+
+// checkAllowedPermissions returns ErrUnknownPermission if one of
+// permissions is missing from a configured catalog, and
+// ErrPermissionNotAllowed if role may not hold one of them. Roles absent
+// from the matrix are unrestricted.
+func (s *UserService) checkAllowedPermissions(role models.UserRole, permissions []string) error {
+	if len(s.permissionCatalog) > 0 {
+		for _, permission := range permissions {
+			if !slices.Contains(s.permissionCatalog, models.NormalizePermission(permission)) {
+				return fmt.Errorf("%w: %q", ErrUnknownPermission, permission)
+			}
+		}
+	}
 
-	if err := s.db.Save(user).Error; err != nil {
-		return fmt.Errorf("failed to remove permission: %w", err)
+	allowed, restricted := s.allowedPermissions[role]
+	if !restricted {
+		return nil
 	}
 
+	for _, permission := range permissions {
+		if !slices.Contains(allowed, models.NormalizePermission(permission)) {
+			return fmt.Errorf("%w: %s cannot hold %q", ErrPermissionNotAllowed, role, permission)
+		}
+	}
 	return nil
 }
 
-// ExportUsers exports users to JSON
-func (s *UserService) ExportUsers() ([]byte, error) {
-	users, _, err := s.GetAllUsers(1, 1000) // Get all users (limit to 1000 for safety)
+// updatePermissions re-reads the user inside a transaction, applies mutate
+// and saves the result so concurrent permission changes are not lost.
+// An error from mutate aborts the update. onSaved, if set, runs in the same
+// transaction with the before and after sets.
+func (s *UserService) updatePermissions(ctx context.Context, id uuid.UUID, mutate func(*models.User) error, onSaved func(tx *gorm.DB, before, after []string) error) error {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	return s.transaction(db, func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+
+		before := append([]string(nil), user.Permissions...)
+		if err := mutate(&user); err != nil {
+			return err
+		}
+
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+
+		if onSaved != nil {
+			return onSaved(tx, before, user.Permissions)
+		}
+		return nil
+	})
+}
+
+// diffPermissions returns the permissions present only in after (added)
+// and only in before (removed)
+func diffPermissions(before, after []string) (added, removed []string) {
+	added, removed = []string{}, []string{}
+
+	inBefore := make(map[string]bool, len(before))
+	for _, p := range before {
+		inBefore[p] = true
+	}
+	inAfter := make(map[string]bool, len(after))
+	for _, p := range after {
+		inAfter[p] = true
+		if !inBefore[p] {
+			added = append(added, p)
+		}
+	}
+	for _, p := range before {
+		if !inAfter[p] {
+			removed = append(removed, p)
+		}
+	}
+	return added, removed
+}
+
+// ExportUsers exports users to JSON, leaving out the redact fields
+func (s *UserService) ExportUsers(ctx context.Context, redact []string) ([]byte, error) {
+	redacted, err := exportRedaction(redact)
+	if err != nil {
+		return nil, err
+	}
+
+	users, _, err := s.GetAllUsers(ctx, 1, 1000) // Get all users (limit to 1000 for safety)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get users for export: %w", err)
 	}
 
-	var responses []*models.UserResponse
+	var responses []interface{}
 	for _, user := range users {
-		responses = append(responses, user.ToResponse())
+		response, err := redactResponse(user.ToResponse(), redacted)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal users: %w", err)
+		}
+		responses = append(responses, response)
 	}
 
 	data, err := json.MarshalIndent(responses, "", "  ")
@@ -397,22 +1799,68 @@ func (s *UserService) ExportUsers() ([]byte, error) {
 	return data, nil
 }
 
+// ExportUserData exports everything held about a single user to JSON: the
+// user record (without password hash), their audit log entries and their
+// active sessions
+func (s *UserService) ExportUserData(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	db, cancel := s.withContext(ctx)
+	defer cancel()
+
+	user, err := s.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &models.UserDataExport{
+		User:       user.ToResponse(),
+		AuditLogs:  []utils.AuditLog{},
+		Sessions:   []models.RefreshToken{},
+		ExportedAt: time.Now().UTC(),
+
+		Preferences: user.Preferences.WithDefaults(),
+	}
+
+	if err := db.Where("user_id = ? OR resource = ?", id, userResource(id)).
+		Order("created_at").Find(&export.AuditLogs).Error; err != nil {
+		return nil, fmt.Errorf("failed to get audit logs for export: %w", err)
+	}
+
+	if err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", id, time.Now()).
+		Order("created_at").Find(&export.Sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get sessions for export: %w", err)
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal user data: %w", err)
+	}
+
+	return data, nil
+}
+
 // GetUserActivity returns user activity information
-func (s *UserService) GetUserActivity(id uuid.UUID) (*utils.UserActivity, error) {
-	user, err := s.GetUserByID(id)
+func (s *UserService) GetUserActivity(ctx context.Context, id uuid.UUID) (*utils.UserActivity, error) {
+	user, err := s.GetUserByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
 	activity := &utils.UserActivity{
-		UserID:        user.ID,
-		Username:      user.Username,
-		LastLogin:     user.LastLogin,
-		LoginAttempts: user.LoginAttempts,
-		IsActive:      user.IsActive(),
-		IsLocked:      user.IsLocked(),
-		CreatedAt:     user.CreatedAt,
-		UpdatedAt:     user.UpdatedAt,
+		UserID:            user.ID,
+		Username:          user.Username,
+		LastLogin:         user.LastLogin,
+		LastLoginIP:       user.LastLoginIP,
+		LastSeenAt:        user.LastSeenAt,
+		LoginAttempts:     user.LoginAttempts,
+		IsActive:          user.IsActive(),
+		IsLocked:          user.IsLocked(),
+		LockedAt:          user.LockedAt,
+		SuspensionCause:   string(user.SuspensionCause),
+		PasswordChangedAt: user.PasswordChangedAt,
+		PasswordAgeDays:   int(time.Since(user.PasswordChangedAt).Hours() / 24),
+		PasswordExpired:   s.PasswordExpired(user),
+		CreatedAt:         user.CreatedAt,
+		UpdatedAt:         user.UpdatedAt,
 	}
 
 	return activity, nil
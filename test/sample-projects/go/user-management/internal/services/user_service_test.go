@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/utils"
+)
+
+func TestCreateUserCaseInsensitiveDuplicates(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		username string
+		email    string
+		want     error
+	}{
+		{"same username", "admin", "other@example.com", ErrDuplicateUsername},
+		{"uppercase username", "ADMIN", "other@example.com", ErrDuplicateUsername},
+		{"mixed case username", "Admin", "other@example.com", ErrDuplicateUsername},
+		{"uppercase email", "other", "ADMIN@EXAMPLE.COM", ErrDuplicateEmail},
+		{"mixed case email", "other", "Admin@Example.com", ErrDuplicateEmail},
+		{"distinct", "other", "other@example.com", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, utils.UserServiceConfig{})
+			createTestUser(t, s, "admin")
+
+			_, err := s.CreateUser(ctx, &models.UserRequest{
+				Username: tt.username,
+				Email:    tt.email,
+				Name:     "Other User",
+				Password: "password123",
+			})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("CreateUser(%s, %s) = %v, want %v", tt.username, tt.email, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupsIgnoreCase(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, utils.UserServiceConfig{})
+	created := createTestUser(t, s, "alice")
+
+	tests := []struct {
+		name   string
+		lookup func() (*models.User, error)
+	}{
+		{"username", func() (*models.User, error) { return s.GetUserByUsername(ctx, "ALICE") }},
+		{"email", func() (*models.User, error) { return s.GetUserByEmail(ctx, "Alice@Example.COM") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := tt.lookup()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.ID != created.ID {
+				t.Errorf("found user %s, want %s", user.ID, created.ID)
+			}
+		})
+	}
+}
+
+func TestRecreateAfterDelete(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		username string
+		email    string
+
+		// restoreErr is what restoring the deleted user returns once the
+		// new user holds its identity
+		restoreErr error
+	}{
+		{"same username", "alice", "new@example.com", ErrDuplicateUsername},
+		{"same username in another case", "ALICE", "new@example.com", ErrDuplicateUsername},
+		{"same email", "newalice", "alice@example.com", ErrDuplicateEmail},
+		{"nothing shared", "newalice", "new@example.com", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, utils.UserServiceConfig{})
+			deleted := createTestUser(t, s, "alice")
+			if err := s.DeleteUser(ctx, deleted.ID, ""); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := s.CreateUser(ctx, &models.UserRequest{
+				Username: tt.username,
+				Email:    tt.email,
+				Name:     "New User",
+				Password: "password123",
+			}); err != nil {
+				t.Fatalf("CreateUser after delete = %v, want nil", err)
+			}
+
+			_, err := s.RestoreUser(ctx, deleted.ID, deleted.ID)
+			if !errors.Is(err, tt.restoreErr) {
+				t.Errorf("RestoreUser = %v, want %v", err, tt.restoreErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/example/user-management/internal/utils"
+	"github.com/google/uuid"
+)
+
+// WebhookSignatureHeader carries the hex HMAC-SHA256 of the request body,
+// keyed with the configured webhook secret
+const WebhookSignatureHeader = "X-Webhook-Signature"
+
+// Webhook delivery defaults used when the config leaves them unset
+const (
+	defaultWebhookTimeout    = 5 * time.Second
+	defaultWebhookMaxRetries = 3
+)
+
+// UserEvent is the JSON payload POSTed to webhook endpoints
+type UserEvent struct {
+	Type      string    `json:"type"`
+	UserID    uuid.UUID `json:"user_id"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// NewUserEvent creates an event of the given type for a user, stamped now
+func NewUserEvent(eventType string, userID uuid.UUID) UserEvent {
+	return UserEvent{Type: eventType, UserID: userID, Timestamp: time.Now().UTC()}
+}
+
+// WebhookService POSTs signed user lifecycle events to configured endpoints
+type WebhookService struct {
+	config utils.WebhookConfig
+	client *http.Client
+}
+
+// NewWebhookService creates a new webhook service
+func NewWebhookService(config utils.WebhookConfig) *WebhookService {
+	timeout := time.Duration(config.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = defaultWebhookMaxRetries
+	}
+
+	return &WebhookService{
+		config: config,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Subscribe sends the user lifecycle events published on bus to the
+// configured endpoints
+func (s *WebhookService) Subscribe(bus *EventBus) {
+	Subscribe(bus, func(_ context.Context, e UserCreated) error {
+		s.Emit(NewUserEvent(e.EventType(), e.UserID))
+		return nil
+	})
+	Subscribe(bus, func(_ context.Context, e UserDeleted) error {
+		s.Emit(NewUserEvent(e.EventType(), e.UserID))
+		return nil
+	})
+	Subscribe(bus, func(_ context.Context, e UserSuspended) error {
+		s.Emit(NewUserEvent(e.EventType(), e.UserID))
+		return nil
+	})
+}
+
+// Emit delivers event to every endpoint in the background so lifecycle
+// operations never wait on receivers. Failed deliveries are logged.
+func (s *WebhookService) Emit(event UserEvent) {
+	for _, url := range s.config.URLs {
+		go func(url string) {
+			if err := s.Deliver(context.Background(), url, event); err != nil {
+				logger.Warn("webhook delivery failed", "event", event.Type, "url", url, "error", err)
+			}
+		}(url)
+	}
+}
+
+// Deliver POSTs event to url, retrying with exponential backoff on network
+// errors and 5xx responses
+func (s *WebhookService) Deliver(ctx context.Context, url string, event UserEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	signature := s.Sign(body)
+
+	backoff := 500 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		retry, err := s.post(ctx, url, body, signature)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= s.config.MaxRetries {
+			return fmt.Errorf("after %d attempt(s): %w", attempt, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post makes one delivery attempt and reports whether a failure is worth
+// retrying
+func (s *WebhookService) post(ctx context.Context, url string, body []byte, signature string) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, signature)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return true, fmt.Errorf("receiver returned %s", resp.Status)
+	}
+	if resp.StatusCode >= 300 {
+		return false, fmt.Errorf("receiver returned %s", resp.Status)
+	}
+	return false, nil
+}
+
+// Sign returns the hex HMAC-SHA256 of body keyed with the webhook secret,
+// as sent in WebhookSignatureHeader
+func (s *WebhookService) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(s.config.Secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// encryptedPrefix marks values produced by FieldCipher.Encrypt. The full
+// format is enc:v1:<key id>:<base64 nonce and ciphertext>.
+const encryptedPrefix = "enc:v1:"
+
+// FieldCipher encrypts individual field values with AES-GCM. Each
+// ciphertext names the key it was made with, so old keys can be kept for
+// decryption while new values use the active key.
+type FieldCipher struct {
+	keys        map[string]cipher.AEAD
+	activeKeyID string
+}
+
+// NewFieldCipher creates a cipher from base64-encoded 32-byte keys indexed
+// by key id. New values are encrypted with activeKeyID.
+func NewFieldCipher(keys map[string]string, activeKeyID string) (*FieldCipher, error) {
+	c := &FieldCipher{keys: make(map[string]cipher.AEAD, len(keys)), activeKeyID: activeKeyID}
+
+	for id, encoded := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid encryption key id %q", id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %s is not valid base64: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("encryption key %s must be 32 bytes", id)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		c.keys[id] = aead
+	}
+
+	if _, ok := c.keys[activeKeyID]; !ok {
+		return nil, fmt.Errorf("active encryption key %q is not configured", activeKeyID)
+	}
+
+	return c, nil
+}
+
+// Encrypt encrypts plaintext with the active key
+func (c *FieldCipher) Encrypt(plaintext []byte) (string, error) {
+	aead := c.keys[c.activeKeyID]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	// The key id is authenticated so a ciphertext can't be relabelled
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(c.activeKeyID))
+	return encryptedPrefix + c.activeKeyID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value produced by Encrypt with whichever configured
+// key it names
+func (c *FieldCipher) Decrypt(value string) ([]byte, error) {
+	keyID, encoded, found := strings.Cut(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if !IsEncrypted(value) || !found {
+		return nil, errors.New("value is not encrypted")
+	}
+
+	aead, ok := c.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key %q", keyID)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, errors.New("malformed ciphertext")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return plaintext, nil
+}
+
+// IsEncrypted reports whether value looks like FieldCipher output
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
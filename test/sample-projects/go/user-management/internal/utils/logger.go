@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Log formats accepted by NewLogger
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Logger is the logging backend shared by the access log and the services.
+// *slog.Logger satisfies it, so any slog handler can be plugged in.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// NewLogger returns a logger writing to w in the given format, "json" for
+// one JSON object per line or "text" for human-readable key=value lines.
+// Entries below level ("debug", "info", "warn" or "error"; default info)
+// are dropped. JSON entries carry their timestamp as "ts".
+func NewLogger(w io.Writer, format, level string) Logger {
+	opts := &slog.HandlerOptions{Level: parseLogLevel(level)}
+
+	if format == LogFormatJSON {
+		opts.ReplaceAttr = func(groups []string, attr slog.Attr) slog.Attr {
+			if len(groups) == 0 && attr.Key == slog.TimeKey {
+				attr.Key = "ts"
+			}
+			return attr
+		}
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+func parseLogLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
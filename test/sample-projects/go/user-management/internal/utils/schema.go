@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// JSONSchema is a compiled JSON Schema. It supports the subset of keywords
+// useful for constraining user metadata: type, enum, properties, required,
+// additionalProperties, items, minLength, maxLength, pattern, minimum,
+// maximum, minItems and maxItems. Unknown keywords are ignored.
+type JSONSchema struct {
+	types                []string
+	enum                 []interface{}
+	properties           map[string]*JSONSchema
+	required             []string
+	additionalProperties *bool
+	additionalSchema     *JSONSchema
+	items                *JSONSchema
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+	minimum, maximum     *float64
+	minItems, maxItems   *int
+}
+
+// rawSchema mirrors the JSON form of the supported keywords
+type rawSchema struct {
+	Type                 json.RawMessage       `json:"type"`
+	Enum                 []interface{}         `json:"enum"`
+	Properties           map[string]*rawSchema `json:"properties"`
+	Required             []string              `json:"required"`
+	AdditionalProperties json.RawMessage       `json:"additionalProperties"`
+	Items                *rawSchema            `json:"items"`
+	MinLength            *int                  `json:"minLength"`
+	MaxLength            *int                  `json:"maxLength"`
+	Pattern              string                `json:"pattern"`
+	Minimum              *float64              `json:"minimum"`
+	Maximum              *float64              `json:"maximum"`
+	MinItems             *int                  `json:"minItems"`
+	MaxItems             *int                  `json:"maxItems"`
+}
+
+var schemaTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true,
+	"integer": true, "boolean": true, "null": true,
+}
+
+// CompileJSONSchema parses and compiles a JSON Schema document so it can be
+// reused for every validation
+func CompileJSONSchema(data []byte) (*JSONSchema, error) {
+	var raw rawSchema
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+	return compileSchema(&raw, "#")
+}
+
+func compileSchema(raw *rawSchema, path string) (*JSONSchema, error) {
+	s := &JSONSchema{
+		enum:      raw.Enum,
+		required:  raw.Required,
+		minLength: raw.MinLength,
+		maxLength: raw.MaxLength,
+		minimum:   raw.Minimum,
+		maximum:   raw.Maximum,
+		minItems:  raw.MinItems,
+		maxItems:  raw.MaxItems,
+	}
+
+	if len(raw.Type) > 0 {
+		if err := json.Unmarshal(raw.Type, &s.types); err != nil {
+			var single string
+			if err := json.Unmarshal(raw.Type, &single); err != nil {
+				return nil, fmt.Errorf("%s: type must be a string or array of strings", path)
+			}
+			s.types = []string{single}
+		}
+		for _, t := range s.types {
+			if !schemaTypes[t] {
+				return nil, fmt.Errorf("%s: unknown type %q", path, t)
+			}
+		}
+	}
+
+	if raw.Pattern != "" {
+		re, err := regexp.Compile(raw.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid pattern: %w", path, err)
+		}
+		s.pattern = re
+	}
+
+	if len(raw.Properties) > 0 {
+		s.properties = make(map[string]*JSONSchema, len(raw.Properties))
+		for name, child := range raw.Properties {
+			compiled, err := compileSchema(child, path+"/properties/"+name)
+			if err != nil {
+				return nil, err
+			}
+			s.properties[name] = compiled
+		}
+	}
+
+	if len(raw.AdditionalProperties) > 0 {
+		var allowed bool
+		if err := json.Unmarshal(raw.AdditionalProperties, &allowed); err == nil {
+			s.additionalProperties = &allowed
+		} else {
+			var child rawSchema
+			if err := json.Unmarshal(raw.AdditionalProperties, &child); err != nil {
+				return nil, fmt.Errorf("%s: additionalProperties must be a boolean or schema", path)
+			}
+			compiled, err := compileSchema(&child, path+"/additionalProperties")
+			if err != nil {
+				return nil, err
+			}
+			s.additionalSchema = compiled
+		}
+	}
+
+	if raw.Items != nil {
+		compiled, err := compileSchema(raw.Items, path+"/items")
+		if err != nil {
+			return nil, err
+		}
+		s.items = compiled
+	}
+
+	return s, nil
+}
+
+// Validate checks value against the schema and returns every violation,
+// keyed by the dotted path of the offending field under root. Value must be
+// in decoded-JSON form; other Go values are normalized through JSON first.
+// It returns nil if value conforms.
+func (s *JSONSchema) Validate(root string, value interface{}) *ValidationErrors {
+	if data, err := json.Marshal(value); err == nil {
+		var normalized interface{}
+		if json.Unmarshal(data, &normalized) == nil {
+			value = normalized
+		}
+	}
+
+	errs := NewValidationErrors()
+	s.validate(root, value, errs)
+	if errs.HasErrors() {
+		return errs
+	}
+	return nil
+}
+
+func (s *JSONSchema) validate(path string, value interface{}, errs *ValidationErrors) {
+	if len(s.types) > 0 && !s.matchesType(value) {
+		errs.Add(path, fmt.Sprintf("%s must be of type %s", path, strings.Join(s.types, " or ")))
+		return
+	}
+
+	if len(s.enum) > 0 && !s.inEnum(value) {
+		errs.Add(path, fmt.Sprintf("%s must be one of the allowed values", path))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		s.validateObject(path, v, errs)
+	case []interface{}:
+		if s.minItems != nil && len(v) < *s.minItems {
+			errs.Add(path, fmt.Sprintf("%s must have at least %d items", path, *s.minItems))
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			errs.Add(path, fmt.Sprintf("%s must have at most %d items", path, *s.maxItems))
+		}
+		if s.items != nil {
+			for i, item := range v {
+				s.items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+			}
+		}
+	case string:
+		length := len([]rune(v))
+		if s.minLength != nil && length < *s.minLength {
+			errs.Add(path, fmt.Sprintf("%s must be at least %d characters", path, *s.minLength))
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			errs.Add(path, fmt.Sprintf("%s must be at most %d characters", path, *s.maxLength))
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			errs.Add(path, fmt.Sprintf("%s must match %s", path, s.pattern))
+		}
+	case float64:
+		if s.minimum != nil && v < *s.minimum {
+			errs.Add(path, fmt.Sprintf("%s must be at least %g", path, *s.minimum))
+		}
+		if s.maximum != nil && v > *s.maximum {
+			errs.Add(path, fmt.Sprintf("%s must be at most %g", path, *s.maximum))
+		}
+	}
+}
+
+func (s *JSONSchema) validateObject(path string, obj map[string]interface{}, errs *ValidationErrors) {
+	for _, name := range s.required {
+		if _, ok := obj[name]; !ok {
+			errs.Add(path+"."+name, fmt.Sprintf("%s.%s is required", path, name))
+		}
+	}
+
+	// Visit keys in a stable order so error lists are deterministic
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		child := path + "." + key
+		if schema, ok := s.properties[key]; ok {
+			schema.validate(child, obj[key], errs)
+			continue
+		}
+
+		switch {
+		case s.additionalSchema != nil:
+			s.additionalSchema.validate(child, obj[key], errs)
+		case s.additionalProperties != nil && !*s.additionalProperties:
+			errs.Add(child, fmt.Sprintf("%s is not an allowed property", child))
+		}
+	}
+}
+
+func (s *JSONSchema) matchesType(value interface{}) bool {
+	for _, t := range s.types {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case nil:
+			if t == "null" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *JSONSchema) inEnum(value interface{}) bool {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range s.enum {
+		if candidate, err := json.Marshal(allowed); err == nil && string(candidate) == string(encoded) {
+			return true
+		}
+	}
+	return false
+}
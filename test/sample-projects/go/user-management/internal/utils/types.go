@@ -1,12 +1,22 @@
 package utils
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/mail"
+	"slices"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
 )
 
-// UserStats represents user statistics
+// UserStats represents user statistics. Deleted users are counted only in
+// Deleted and TotalIncludingDeleted, whether they were deleted by status
+// or soft-deleted through DeletedAt; every other count covers live users.
 type UserStats struct {
 	Total     int64 `json:"total"`
 	Active    int64 `json:"active"`
@@ -14,6 +24,23 @@ type UserStats struct {
 	User      int64 `json:"user"`
 	Guest     int64 `json:"guest"`
 	WithEmail int64 `json:"with_email"`
+
+	Deleted               int64 `json:"deleted"`
+	TotalIncludingDeleted int64 `json:"total_including_deleted"`
+}
+
+// Granularities of a signups time series
+const (
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+// TimeBucket is one point of a time series: the count for the period
+// starting at Start, in UTC
+type TimeBucket struct {
+	Start time.Time `json:"start"`
+	Count int64     `json:"count"`
 }
 
 // UserActivity represents user activity information
@@ -21,11 +48,23 @@ type UserActivity struct {
 	UserID        uuid.UUID  `json:"user_id"`
 	Username      string     `json:"username"`
 	LastLogin     *time.Time `json:"last_login"`
+	LastLoginIP   string     `json:"last_login_ip"`
+	LastSeenAt    *time.Time `json:"last_seen_at"`
 	LoginAttempts int        `json:"login_attempts"`
 	IsActive      bool       `json:"is_active"`
 	IsLocked      bool       `json:"is_locked"`
+	LockedAt      *time.Time `json:"locked_at"`
 	CreatedAt     time.Time  `json:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at"`
+
+	// SuspensionCause is lockout, admin or expiry for a suspended user and
+	// empty otherwise
+	SuspensionCause string `json:"suspension_cause,omitempty"`
+
+	// Password age, and whether it exceeds the configured maximum
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+	PasswordAgeDays   int       `json:"password_age_days"`
+	PasswordExpired   bool      `json:"password_expired"`
 }
 
 // PaginatedResponse represents a paginated response
@@ -124,6 +163,201 @@ func (ve *ValidationErrors) Error() string {
 	return "multiple validation errors"
 }
 
+// ValidateEmail checks that an email address is a bare, deliverable-looking
+// address (local@domain.tld) rather than just something the binding accepts
+func ValidateEmail(email string) error {
+	if len(email) > 254 {
+		return errors.New("email must be at most 254 characters")
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return errors.New("invalid email format")
+	}
+
+	at := strings.LastIndex(email, "@")
+	local, domain := email[:at], email[at+1:]
+	if len(local) > 64 {
+		return errors.New("email local part must be at most 64 characters")
+	}
+
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") ||
+		strings.HasSuffix(domain, ".") || strings.Contains(domain, "..") {
+		return errors.New("email domain is invalid")
+	}
+
+	return nil
+}
+
+// ValidateMetadata checks that metadata serializes to at most maxBytes of
+// JSON and nests objects or arrays no deeper than maxDepth
+func ValidateMetadata(metadata map[string]interface{}, maxBytes, maxDepth int) error {
+	if metadata == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("metadata is not valid JSON: %w", err)
+	}
+	if len(data) > maxBytes {
+		return fmt.Errorf("metadata must be at most %d bytes", maxBytes)
+	}
+
+	if jsonDepth(metadata) > maxDepth {
+		return fmt.Errorf("metadata must be nested at most %d levels deep", maxDepth)
+	}
+
+	return nil
+}
+
+// jsonDepth returns the object/array nesting depth of a decoded JSON value
+func jsonDepth(value interface{}) int {
+	depth := 0
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, child := range v {
+			depth = max(depth, jsonDepth(child))
+		}
+	case []interface{}:
+		for _, child := range v {
+			depth = max(depth, jsonDepth(child))
+		}
+	default:
+		return 0
+	}
+	return depth + 1
+}
+
+// PasswordPolicy describes the rules a new password must satisfy
+type PasswordPolicy struct {
+	MinLength     int  `json:"min_length"`
+	RequireUpper  bool `json:"require_upper"`
+	RequireLower  bool `json:"require_lower"`
+	RequireDigit  bool `json:"require_digit"`
+	RequireSymbol bool `json:"require_symbol"`
+}
+
+// DefaultPasswordPolicy is the policy enforced when passwords are set
+var DefaultPasswordPolicy = PasswordPolicy{MinLength: 8}
+
+// PasswordRuleResult reports whether a password satisfies one policy rule
+type PasswordRuleResult struct {
+	Rule      string `json:"rule"`
+	Message   string `json:"message"`
+	Satisfied bool   `json:"satisfied"`
+}
+
+// Check evaluates password against every rule of the policy
+func (p PasswordPolicy) Check(password string) []PasswordRuleResult {
+	classes := classifyPassword(password)
+
+	results := []PasswordRuleResult{{
+		Rule:      "min_length",
+		Message:   fmt.Sprintf("password must be at least %d characters long", p.MinLength),
+		Satisfied: len(password) >= p.MinLength,
+	}}
+	if p.RequireUpper {
+		results = append(results, PasswordRuleResult{"uppercase", "password must contain an uppercase letter", classes.upper})
+	}
+	if p.RequireLower {
+		results = append(results, PasswordRuleResult{"lowercase", "password must contain a lowercase letter", classes.lower})
+	}
+	if p.RequireDigit {
+		results = append(results, PasswordRuleResult{"digit", "password must contain a digit", classes.digit})
+	}
+	if p.RequireSymbol {
+		results = append(results, PasswordRuleResult{"symbol", "password must contain a symbol", classes.symbol})
+	}
+	return results
+}
+
+// Validate returns an error describing the first rule password violates
+func (p PasswordPolicy) Validate(password string) error {
+	for _, result := range p.Check(password) {
+		if !result.Satisfied {
+			return errors.New(result.Message)
+		}
+	}
+	return nil
+}
+
+// passwordClasses records which character classes a password contains
+type passwordClasses struct {
+	upper, lower, digit, symbol bool
+}
+
+func classifyPassword(password string) passwordClasses {
+	var classes passwordClasses
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			classes.upper = true
+		case unicode.IsLower(r):
+			classes.lower = true
+		case unicode.IsDigit(r):
+			classes.digit = true
+		default:
+			classes.symbol = true
+		}
+	}
+	return classes
+}
+
+// commonPasswords are scored 0 regardless of their apparent entropy
+var commonPasswords = map[string]bool{
+	"password": true, "password1": true, "password123": true, "12345678": true,
+	"123456789": true, "qwerty123": true, "iloveyou": true, "admin123": true,
+	"letmein1": true, "welcome1": true, "11111111": true, "abc12345": true,
+}
+
+// PasswordStrength estimates the strength of a password on a 0-4 scale from
+// the size of its character pool and its length. Repeated characters count
+// once per occurrence beyond the second, and well-known passwords score 0.
+func PasswordStrength(password string) int {
+	if password == "" || commonPasswords[strings.ToLower(password)] {
+		return 0
+	}
+
+	seen := make(map[rune]int)
+	length := 0
+	for _, r := range password {
+		seen[r]++
+		if seen[r] <= 2 {
+			length++
+		}
+	}
+
+	classes := classifyPassword(password)
+	pool := 0
+	if classes.upper {
+		pool += 26
+	}
+	if classes.lower {
+		pool += 26
+	}
+	if classes.digit {
+		pool += 10
+	}
+	if classes.symbol {
+		pool += 33
+	}
+
+	bits := float64(length) * math.Log2(float64(pool))
+	switch {
+	case bits < 28:
+		return 0
+	case bits < 36:
+		return 1
+	case bits < 60:
+		return 2
+	case bits < 80:
+		return 3
+	default:
+		return 4
+	}
+}
+
 // DatabaseConfig represents database configuration
 type DatabaseConfig struct {
 	Driver   string `json:"driver"`
@@ -133,6 +367,24 @@ type DatabaseConfig struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 	SSLMode  string `json:"ssl_mode"`
+
+	// Connection pool settings; zero values use the server defaults.
+	// ConnMaxLifetime is in minutes.
+	MaxOpenConns    int `json:"max_open_conns"`
+	MaxIdleConns    int `json:"max_idle_conns"`
+	ConnMaxLifetime int `json:"conn_max_lifetime"`
+
+	// Connecting at startup is retried for up to ConnectTimeout seconds,
+	// waiting ConnectBackoff milliseconds before the first retry and
+	// doubling the wait after each one. Zero values use the server
+	// defaults.
+	ConnectTimeout int `json:"connect_timeout"`
+	ConnectBackoff int `json:"connect_backoff"`
+
+	// AutoMigrate applies schema migrations at startup. Without it the
+	// server only checks the schema exists and migrations are applied by
+	// running it with --migrate.
+	AutoMigrate bool `json:"auto_migrate"`
 }
 
 // ServerConfig represents server configuration
@@ -142,6 +394,23 @@ type ServerConfig struct {
 	ReadTimeout  int    `json:"read_timeout"`
 	WriteTimeout int    `json:"write_timeout"`
 	IdleTimeout  int    `json:"idle_timeout"`
+
+	// MaxBodyBytes caps request bodies; BodyLimits overrides it per route
+	// group ("users", "auth", "admin")
+	MaxBodyBytes int64            `json:"max_body_bytes"`
+	BodyLimits   map[string]int64 `json:"body_limits"`
+
+	// Page size used when a list request doesn't give one, and the cap
+	DefaultPageSize int `json:"default_page_size"`
+	MaxPageSize     int `json:"max_page_size"`
+
+	// MaxConcurrentExports caps how many bulk exports run at once
+	MaxConcurrentExports int `json:"max_concurrent_exports"`
+
+	// ReadOnly starts the server rejecting writes with 503; admins can flip
+	// it at runtime. ReadOnlyBlocksLogin also rejects logins and refreshes.
+	ReadOnly            bool `json:"read_only"`
+	ReadOnlyBlocksLogin bool `json:"read_only_blocks_login"`
 }
 
 // JWTConfig represents JWT configuration
@@ -151,15 +420,183 @@ type JWTConfig struct {
 	RefreshHours     int    `json:"refresh_hours"`
 	Issuer           string `json:"issuer"`
 	SigningAlgorithm string `json:"signing_algorithm"`
+	// ImpersonationMinutes is the lifetime of the tokens admins get when
+	// acting as another user
+	ImpersonationMinutes int `json:"impersonation_minutes"`
+}
+
+// Audit log handling when a user is hard deleted
+const (
+	AuditRetentionAnonymize = "anonymize"
+	AuditRetentionDelete    = "delete"
+)
+
+// UserServiceConfig represents user service behavior configuration
+type UserServiceConfig struct {
+	AuditOnHardDelete string `json:"audit_on_hard_delete"`
+	QueryTimeout      int    `json:"query_timeout"`
+	IdempotencyWindow int    `json:"idempotency_window"`
+	BcryptCost        int    `json:"bcrypt_cost"`
+
+	// PasswordAlgorithm hashes new passwords: bcrypt (the default) or
+	// argon2id. Hashes made with the other algorithm still verify and are
+	// rehashed on the user's next login.
+	PasswordAlgorithm string `json:"password_algorithm"`
+
+	// WriteRetries is how many times a write failing with a transient
+	// database error is retried, waiting WriteRetryBackoff milliseconds
+	// before the first retry and doubling the wait after each one
+	WriteRetries      int `json:"write_retries"`
+	WriteRetryBackoff int `json:"write_retry_backoff"`
+
+	// UnifiedLoginIdentifiers treats usernames and emails as one namespace,
+	// so no username may equal another user's email or the reverse. Enable
+	// it when users log in with either identifier.
+	UnifiedLoginIdentifiers bool `json:"unified_login_identifiers"`
+
+	// HardDeleteByDefault makes the delete endpoint remove users permanently
+	// instead of soft-deleting them
+	HardDeleteByDefault bool `json:"hard_delete_by_default"`
+
+	// BootstrapFirstAdmin makes the first user created on an empty database
+	// an admin, instead of seeding a default admin account
+	BootstrapFirstAdmin bool `json:"bootstrap_first_admin"`
+
+	// MaxPasswordAge is how many days a password stays valid. Logins with
+	// an older password succeed but are flagged must_change_password. 0
+	// disables expiry.
+	MaxPasswordAge int `json:"max_password_age"`
+
+	// Password operations allowed per user within PasswordChangeWindow
+	// minutes. Admin resets are unlimited unless AdminPasswordResetLimit is set.
+	PasswordChangeLimit     int `json:"password_change_limit"`
+	PasswordChangeWindow    int `json:"password_change_window"`
+	AdminPasswordResetLimit int `json:"admin_password_reset_limit"`
+
+	// RolePermissions overrides the default permission set of a role
+	RolePermissions map[string][]string `json:"role_permissions"`
+
+	// RoleAllowedPermissions overrides which permissions a role may be granted
+	RoleAllowedPermissions map[string][]string `json:"role_allowed_permissions"`
+
+	// PermissionCatalog lists every permission that may be granted. Grants
+	// outside it are rejected; an empty catalog leaves permissions
+	// unrestricted.
+	PermissionCatalog []string `json:"permission_catalog"`
+
+	// Soft-deleted users older than DeletedRetention days are purged every
+	// PurgeInterval minutes, PurgeBatchSize at a time. A zero interval
+	// disables the purge job.
+	DeletedRetention int `json:"deleted_retention"`
+	PurgeInterval    int `json:"purge_interval"`
+	PurgeBatchSize   int `json:"purge_batch_size"`
+
+	// Users other than admins inactive for more than AnonymizeAfter days
+	// have their personal data anonymized every AnonymizeInterval minutes.
+	// A zero interval disables the anonymization job.
+	AnonymizeAfter    int `json:"anonymize_after"`
+	AnonymizeInterval int `json:"anonymize_interval"`
+
+	// MinAge is the minimum age for users; 0 means no minimum
+	MinAge int `json:"min_age"`
+
+	// MetadataSchemaFile is an optional JSON Schema file user metadata must
+	// conform to; without one metadata is free-form
+	MetadataSchemaFile string `json:"metadata_schema_file"`
+
+	// UsernamePattern is an optional regular expression usernames must
+	// match. ReservedUsernames may not be registered, regardless of case.
+	UsernamePattern   string   `json:"username_pattern"`
+	ReservedUsernames []string `json:"reserved_usernames"`
+
+	// Limits on user metadata: serialized JSON size in bytes and nesting depth
+	MetadataMaxBytes int `json:"metadata_max_bytes"`
+	MetadataMaxDepth int `json:"metadata_max_depth"`
+
+	// UniqueMetadataKeys lists metadata keys, such as an external id, whose
+	// values must not be shared by two users
+	UniqueMetadataKeys []string `json:"unique_metadata_keys"`
+
+	// Search queries shorter than MinSearchLength characters, ignoring
+	// surrounding whitespace, are handled per ShortSearchPolicy instead of
+	// scanning every user
+	MinSearchLength   int    `json:"min_search_length"`
+	ShortSearchPolicy string `json:"short_search_policy"`
+
+	// SearchSortBy and SearchSortDir order search results when the request
+	// doesn't choose a sort. SearchSortBy is one of SortableFields or
+	// SortRelevance.
+	SearchSortBy  string `json:"search_sort_by"`
+	SearchSortDir string `json:"search_sort_dir"`
+
+	// StatsCacheTTL is how many seconds user statistics are cached for;
+	// 0 disables the cache
+	StatsCacheTTL int `json:"stats_cache_ttl"`
+}
+
+// Short search query policies: reject the query, or return no results
+const (
+	ShortSearchReject = "reject"
+	ShortSearchEmpty  = "empty"
+)
+
+// WebhookConfig represents webhook delivery configuration. Timeout is in
+// seconds per attempt.
+type WebhookConfig struct {
+	URLs       []string `json:"urls"`
+	Secret     string   `json:"secret"`
+	Timeout    int      `json:"timeout"`
+	MaxRetries int      `json:"max_retries"`
+}
+
+// SessionConfig represents refresh session policy. A session is extended
+// each time it is used until it has been idle for IdleTimeout hours or is
+// AbsoluteTimeout hours old. At most MaxSessionsPerUser sessions may be
+// active per user; LimitPolicy decides what a login beyond that does. Zero
+// disables the respective limit.
+type SessionConfig struct {
+	IdleTimeout        int    `json:"idle_timeout"`
+	AbsoluteTimeout    int    `json:"absolute_timeout"`
+	MaxSessionsPerUser int    `json:"max_sessions_per_user"`
+	LimitPolicy        string `json:"limit_policy"`
+}
+
+// Session limit policies: reject the new login, or evict the user's oldest
+// sessions to make room for it
+const (
+	SessionLimitReject = "reject"
+	SessionLimitEvict  = "evict"
+)
+
+// EncryptionConfig represents encryption of sensitive metadata at rest.
+// Keys maps key ids to base64-encoded 32-byte AES keys; values are
+// encrypted with ActiveKey and decrypted with the key they name, so old
+// keys can stay listed while data is rotated.
+type EncryptionConfig struct {
+	Keys         map[string]string `json:"keys"`
+	ActiveKey    string            `json:"active_key"`
+	MetadataKeys []string          `json:"metadata_keys"`
+}
+
+// EventsConfig represents event bus configuration. With Async, subscribers
+// run in the background and their errors are logged.
+type EventsConfig struct {
+	Async bool `json:"async"`
 }
 
 // Config represents application configuration
 type Config struct {
-	Database DatabaseConfig `json:"database"`
-	Server   ServerConfig   `json:"server"`
-	JWT      JWTConfig      `json:"jwt"`
-	LogLevel string         `json:"log_level"`
-	Debug    bool           `json:"debug"`
+	Database   DatabaseConfig    `json:"database"`
+	Server     ServerConfig      `json:"server"`
+	JWT        JWTConfig         `json:"jwt"`
+	Users      UserServiceConfig `json:"users"`
+	Sessions   SessionConfig     `json:"sessions"`
+	Encryption EncryptionConfig  `json:"encryption"`
+	Webhooks   WebhookConfig     `json:"webhooks"`
+	Events     EventsConfig      `json:"events"`
+	LogLevel   string            `json:"log_level"`
+	LogFormat  string            `json:"log_format"`
+	Debug      bool              `json:"debug"`
 }
 
 // SearchParams represents search parameters
@@ -171,37 +608,63 @@ type SearchParams struct {
 	SortDir  string `json:"sort_dir"`
 }
 
+// Page size limits applied by NormalizePagination. They may be changed at
+// startup from the server config.
+var (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// NormalizePagination returns page and pageSize with page at least 1 and
+// pageSize defaulted when unset and capped at MaxPageSize
+func NormalizePagination(page, pageSize int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+	return page, pageSize
+}
+
 // NewSearchParams creates new search parameters with defaults
 func NewSearchParams() *SearchParams {
 	return &SearchParams{
 		Page:     1,
-		PageSize: 20,
+		PageSize: DefaultPageSize,
 		SortBy:   "created_at",
 		SortDir:  "desc",
 	}
 }
 
-// Validate validates search parameters
-func (sp *SearchParams) Validate() error {
-	if sp.Page < 1 {
-		sp.Page = 1
-	}
+// SortableFields are the user columns results may be sorted by
+var SortableFields = []string{"username", "name", "email", "age", "created_at", "updated_at", "last_login"}
 
-	if sp.PageSize < 1 {
-		sp.PageSize = 20
-	}
+// SortRelevance sorts search results by how closely the username matches
+// the query: exact matches, then prefix matches, then the rest
+const SortRelevance = "relevance"
 
-	if sp.PageSize > 100 {
-		sp.PageSize = 100
-	}
+// Validate validates search parameters. SortBy must be one of
+// SortableFields.
+func (sp *SearchParams) Validate() error {
+	sp.Page, sp.PageSize = NormalizePagination(sp.Page, sp.PageSize)
 
 	if sp.SortBy == "" {
 		sp.SortBy = "created_at"
 	}
+	if !slices.Contains(SortableFields, sp.SortBy) {
+		return fmt.Errorf("invalid sort field: %s", sp.SortBy)
+	}
 
-	if sp.SortDir != "asc" && sp.SortDir != "desc" {
+	if sp.SortDir == "" {
 		sp.SortDir = "desc"
 	}
+	if sp.SortDir != "asc" && sp.SortDir != "desc" {
+		return fmt.Errorf("invalid sort direction %q, must be asc or desc", sp.SortDir)
+	}
 
 	return nil
 }
@@ -214,20 +677,32 @@ type FilterParams struct {
 	AgeMax    int       `json:"age_max"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// Range filters for retention tooling; zero values are ignored.
+	// NeverLoggedIn matches users whose last login is unset.
+	CreatedBefore   time.Time `json:"created_before"`
+	CreatedAfter    time.Time `json:"created_after"`
+	LastLoginBefore time.Time `json:"last_login_before"`
+	NeverLoggedIn   bool      `json:"never_logged_in"`
 }
 
 // AuditLog represents an audit log entry
 type AuditLog struct {
-	ID        uuid.UUID              `json:"id"`
-	UserID    uuid.UUID              `json:"user_id"`
-	Action    string                 `json:"action"`
+	ID        uuid.UUID              `json:"id" gorm:"type:uuid;primary_key"`
+	UserID    uuid.UUID              `json:"user_id" gorm:"type:uuid;index"`
+	Action    string                 `json:"action" gorm:"index;not null"`
 	Resource  string                 `json:"resource"`
-	Details   map[string]interface{} `json:"details"`
+	Details   map[string]interface{} `json:"details" gorm:"type:json;serializer:json"`
 	IPAddress string                 `json:"ip_address"`
 	UserAgent string                 `json:"user_agent"`
 	CreatedAt time.Time              `json:"created_at"`
 }
 
+// TableName returns the table name for GORM
+func (a *AuditLog) TableName() string {
+	return "audit_logs"
+}
+
 // Session represents a user session
 type Session struct {
 	ID        uuid.UUID `json:"id"`
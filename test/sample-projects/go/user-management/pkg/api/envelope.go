@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/example/user-management/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ResponseEnvelopeHeader lets a client ask for bare resources: sending
+// "X-Response-Envelope: false" returns the data of successful responses
+// without the success/message wrapper. Errors are always enveloped.
+const ResponseEnvelopeHeader = "X-Response-Envelope"
+
+// respond writes resp as JSON, unwrapping successful responses for clients
+// that opted out of the envelope. A bare response without data is sent as
+// 204 No Content.
+func respond(c *gin.Context, status int, resp *utils.APIResponse) {
+	body := envelope(c, resp)
+	if body == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(status, body)
+}
+
+// envelope returns the body to send for resp: resp itself, or its data if
+// it is a success and the client opted out of the envelope
+func envelope(c *gin.Context, resp *utils.APIResponse) interface{} {
+	c.Writer.Header().Add("Vary", ResponseEnvelopeHeader)
+
+	if !resp.Success {
+		return resp
+	}
+	if enveloped, err := strconv.ParseBool(c.GetHeader(ResponseEnvelopeHeader)); err != nil || enveloped {
+		return resp
+	}
+	return resp.Data
+}
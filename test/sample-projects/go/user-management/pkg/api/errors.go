@@ -0,0 +1,28 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/example/user-management/internal/services"
+)
+
+// statusForError maps a service error to the HTTP status code to respond with
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrEmailNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrDuplicateUsername), errors.Is(err, services.ErrDuplicateEmail),
+		errors.Is(err, services.ErrDuplicateMetadata):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrValidation), errors.Is(err, services.ErrPermissionNotAllowed),
+		errors.Is(err, services.ErrUnknownPermission), errors.Is(err, services.ErrInvalidTwoFactorCode):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrSessionLimitReached):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrPasswordRateLimited):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/example/user-management/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// respondWithETag writes resp as JSON with an ETag derived from its
+// serialized form, so identical responses share a tag and any change to the
+// resource yields a new one. A matching If-None-Match gets 304 instead.
+func respondWithETag(c *gin.Context, resp *utils.APIResponse) {
+	body := envelope(c, resp)
+	data, err := json.Marshal(body)
+	if err != nil {
+		c.JSON(http.StatusOK, body)
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// notModified sets Last-Modified and answers 304 Not Modified if the
+// client's If-Modified-Since copy is still current, reporting whether it
+// did. HTTP dates have second precision, so lastModified is truncated. A
+// zero lastModified, meaning nothing matched, is never cached.
+func notModified(c *gin.Context, lastModified time.Time) bool {
+	if lastModified.IsZero() {
+		return false
+	}
+
+	lastModified = lastModified.UTC().Truncate(time.Second)
+	c.Header("Last-Modified", lastModified.Format(http.TimeFormat))
+
+	since, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+	if err != nil || lastModified.After(since) {
+		return false
+	}
+
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag.
+// Weak validators match their strong counterpart.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/example/user-management/internal/auth"
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/services"
+	"github.com/example/user-management/internal/utils"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	models.PasswordCost = bcrypt.MinCost
+	os.Exit(m.Run())
+}
+
+// testServer serves the routes under test over a fresh database
+type testServer struct {
+	router       *gin.Engine
+	db           *gorm.DB
+	userService  *services.UserService
+	tokenService *services.TokenService
+	jwtManager   *auth.JWTManager
+}
+
+// newTestServer wires the handlers the way cmd/server does, for the routes
+// the tests exercise
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "users.db")), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := services.RunMigrations(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+
+	srv := &testServer{
+		db:           db,
+		userService:  services.NewUserService(db, utils.UserServiceConfig{}),
+		tokenService: services.NewTokenService(db, utils.SessionConfig{}),
+		jwtManager:   auth.NewJWTManager(utils.JWTConfig{SecretKey: "test-secret", Issuer: "user-management"}),
+	}
+	handler := NewUserHandler(srv.userService, srv.tokenService, srv.jwtManager)
+	authenticated := AuthMiddleware(srv.jwtManager, srv.tokenService)
+
+	srv.router = gin.New()
+	srv.router.Use(ImpersonationAuditMiddleware(srv.userService))
+	v1 := srv.router.Group("/api/v1")
+	v1.POST("/users", handler.CreateUser)
+	v1.PUT("/users/:id", authenticated, handler.UpdateUser)
+	v1.GET("/auth/me", authenticated, handler.Me)
+	v1.POST("/auth/change-password", authenticated, handler.ChangePassword)
+	v1.POST("/admin/users/:id/logout-all", authenticated, AdminMiddleware(), handler.LogoutAll)
+	v1.GET("/admin/users/by-role/:role", authenticated, AdminMiddleware(), handler.GetUsersByRole)
+	v1.PUT("/admin/read-only", authenticated, AdminMiddleware(), NewReadOnlyMode(srv.userService, false, false, nil, nil).Update)
+	return srv
+}
+
+// createUser creates an active user with the given password and role
+func (srv *testServer) createUser(t *testing.T, username, password string, role models.UserRole) *models.User {
+	t.Helper()
+
+	user, err := srv.userService.CreateUser(context.Background(), &models.UserRequest{
+		Username: username,
+		Email:    username + "@example.com",
+		Name:     "Test User",
+		Age:      30,
+		Password: password,
+	})
+	if err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+	if role != user.Role {
+		if err := srv.db.Model(user).Update("role", role).Error; err != nil {
+			t.Fatalf("set role of %s: %v", username, err)
+		}
+	}
+	return user
+}
+
+// token returns an access token for user
+func (srv *testServer) token(t *testing.T, user *models.User) string {
+	t.Helper()
+
+	token, _, err := srv.jwtManager.GenerateToken(user)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	return token
+}
+
+// do sends a request with an optional bearer token and JSON body
+func (srv *testServer) do(t *testing.T, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("encode body: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	return w
+}
+
+// authenticates reports whether username can log in with password
+func (srv *testServer) authenticates(t *testing.T, username, password string) bool {
+	t.Helper()
+
+	_, err := srv.userService.AuthenticateUser(context.Background(), username, password, services.SecondFactor{}, "127.0.0.1")
+	return err == nil
+}
+
+// checkStatus fails the test unless the response has the wanted status
+func checkStatus(t *testing.T, w *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if w.Code != want {
+		t.Fatalf("status = %d, want %d; body: %s", w.Code, want, w.Body)
+	}
+}
@@ -0,0 +1,19 @@
+package api
+
+import (
+	"log/slog"
+
+	"github.com/example/user-management/internal/utils"
+)
+
+// logger receives warnings from middleware and handlers that must not fail
+// the request
+var logger utils.Logger = slog.Default()
+
+// SetLogger sets the logger the API writes warnings to, typically the one
+// the access log uses so all output shares a format
+func SetLogger(l utils.Logger) {
+	if l != nil {
+		logger = l
+	}
+}
@@ -0,0 +1,322 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/user-management/internal/auth"
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/services"
+	"github.com/example/user-management/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	contextUserIDKey    = "user_id"
+	contextRoleKey      = "user_role"
+	contextRequestIDKey = "request_id"
+
+	contextImpersonatorKey       = "impersonated_by"
+	contextShadowedKey           = "shadowed"
+	contextImpersonationAuditKey = "impersonation_audit"
+)
+
+// RequestIDHeader carries the request id in requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// AuthMiddleware requires a valid bearer token whose version matches the
+// user's current token version and stores the authenticated identity in the
+// request context
+func AuthMiddleware(jwtManager *auth.JWTManager, tokenService *services.TokenService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		tokenString, found := strings.CutPrefix(header, "Bearer ")
+		if !found || tokenString == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, utils.NewErrorResponse("Authentication required", errors.New("missing bearer token")))
+			return
+		}
+
+		claims, err := jwtManager.ValidateToken(tokenString, auth.TokenTypeAccess)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, utils.NewErrorResponse("Authentication required", err))
+			return
+		}
+
+		version, err := tokenService.TokenVersion(c.Request.Context(), claims.UserID)
+		if err != nil {
+			if errors.Is(err, services.ErrUserNotFound) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, utils.NewErrorResponse("Authentication required", err))
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to validate token", err))
+			return
+		}
+		if claims.Version != version {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, utils.NewErrorResponse("Authentication required", errors.New("token has been revoked")))
+			return
+		}
+
+		shadowed, err := tokenService.IsShadowed(c.Request.Context(), claims.UserID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to validate token", err))
+			return
+		}
+
+		c.Set(contextUserIDKey, claims.UserID)
+		c.Set(contextRoleKey, claims.Role)
+		c.Set(contextShadowedKey, shadowed)
+		if claims.ImpersonatedBy != nil {
+			c.Set(contextImpersonatorKey, *claims.ImpersonatedBy)
+			if err := auditImpersonation(c, claims.UserID, *claims.ImpersonatedBy); err != nil {
+				logger.Error("failed to audit impersonated request", "user_id", claims.UserID.String(), "admin_id", claims.ImpersonatedBy.String(), "error", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to audit impersonated request", err))
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// AdminMiddleware requires the authenticated user to be an admin.
+// It must be installed after AuthMiddleware.
+func AdminMiddleware() gin.HandlerFunc {
+	return RequireRole(models.RoleAdmin)
+}
+
+// RequireRole requires the authenticated user's role to rank at or above
+// role, so requiring user also admits admins. It must be installed after
+// AuthMiddleware.
+func RequireRole(role models.UserRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasRole(c, role) {
+			message := "Insufficient role"
+			if role == models.RoleAdmin {
+				message = "Admin access required"
+			}
+			c.AbortWithStatusJSON(http.StatusForbidden, utils.NewErrorResponse(message, fmt.Errorf("requires role %s or higher", role)))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// BodyLimitMiddleware rejects request bodies larger than maxBytes with
+// 413 Request Entity Too Large. A non-positive limit disables the check.
+func BodyLimitMiddleware(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maxBytes <= 0 || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > maxBytes {
+			abortBodyTooLarge(c, maxBytes)
+			return
+		}
+
+		// Buffer the body so handlers see a clean JSON read and the limit
+		// error is reported here rather than as a generic bind failure
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes))
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				abortBodyTooLarge(c, maxBytes)
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request", err))
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
+
+func abortBodyTooLarge(c *gin.Context, maxBytes int64) {
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge,
+		utils.NewErrorResponse("Request body too large", fmt.Errorf("request body must be at most %d bytes", maxBytes)))
+}
+
+// ConcurrencyLimitMiddleware allows at most limit requests through at once.
+// Further requests wait up to wait for a slot and then get 429 Too Many
+// Requests. A slot is released when the handler returns, which includes the
+// client disconnecting and cancelling the request context. A non-positive
+// limit disables the check.
+func ConcurrencyLimitMiddleware(limit int, wait time.Duration) gin.HandlerFunc {
+	if limit <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	slots := make(chan struct{}, limit)
+	return func(c *gin.Context) {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case slots <- struct{}{}:
+		case <-timer.C:
+			c.Header("Retry-After", strconv.Itoa(int(max(wait.Seconds(), 1))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, utils.NewErrorResponse("Too many concurrent requests", errors.New("try again later")))
+			return
+		case <-c.Request.Context().Done():
+			c.Abort()
+			return
+		}
+		defer func() { <-slots }()
+
+		c.Next()
+	}
+}
+
+// RequestIDMiddleware tags each request with an id, reusing the client's
+// X-Request-ID when it sends a reasonable one, and echoes it in the response
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = uuid.NewString()
+		}
+
+		c.Set(contextRequestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// AccessLogMiddleware writes one structured entry per request to logger
+// once the handler chain has finished. It should run after
+// RequestIDMiddleware so entries carry the request id.
+func AccessLogMiddleware(logger utils.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+
+		c.Next()
+
+		fields := []any{
+			"method", c.Request.Method,
+			"path", path,
+			"status", c.Writer.Status(),
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"client_ip", c.ClientIP(),
+			"request_id", c.GetString(contextRequestIDKey),
+		}
+		if userID, ok := currentUserID(c); ok {
+			fields = append(fields, "user_id", userID.String())
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, "error", c.Errors.String())
+		}
+
+		logger.Info("request", fields...)
+	}
+}
+
+// ActivityMiddleware records the last-seen time of authenticated users.
+// It runs after the handler chain so it sees the identity set by
+// AuthMiddleware on any route; failures are logged and never fail the request.
+func ActivityMiddleware(userService *services.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		// Support staff acting as the user aren't the user being active
+		if _, impersonated := impersonator(c); impersonated {
+			return
+		}
+
+		if err := userService.TouchLastSeen(c.Request.Context(), userID); err != nil {
+			logger.Warn("failed to record activity", "user_id", userID.String(), "error", err)
+		}
+	}
+}
+
+// impersonationAuditor records one request made with an impersonation token
+type impersonationAuditor func(c *gin.Context, userID, adminID uuid.UUID) error
+
+// ImpersonationAuditMiddleware makes every request made with an
+// impersonation token write an audit entry, attributed to the admin. Only
+// AuthMiddleware knows who is impersonating, so the entry is written there,
+// before the handler runs; if it can't be written the request fails rather
+// than going unaudited.
+func ImpersonationAuditMiddleware(userService *services.UserService) gin.HandlerFunc {
+	var audit impersonationAuditor = func(c *gin.Context, userID, adminID uuid.UUID) error {
+		return userService.RecordImpersonatedRequest(c.Request.Context(), userID, adminID, map[string]interface{}{
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+			"request_id": c.GetString(contextRequestIDKey),
+		})
+	}
+
+	return func(c *gin.Context) {
+		c.Set(contextImpersonationAuditKey, audit)
+		c.Next()
+	}
+}
+
+// auditImpersonation writes the audit entry for an impersonated request
+// through the auditor ImpersonationAuditMiddleware installed, if any
+func auditImpersonation(c *gin.Context, userID, adminID uuid.UUID) error {
+	value, exists := c.Get(contextImpersonationAuditKey)
+	if !exists {
+		return nil
+	}
+	audit, ok := value.(impersonationAuditor)
+	if !ok {
+		return nil
+	}
+	return audit(c, userID, adminID)
+}
+
+// isAdmin reports whether the authenticated user is an admin
+func isAdmin(c *gin.Context) bool {
+	return hasRole(c, models.RoleAdmin)
+}
+
+// hasRole reports whether the authenticated user's role ranks at or above
+// role
+func hasRole(c *gin.Context, role models.UserRole) bool {
+	current, _ := c.Get(contextRoleKey)
+	currentRole, _ := current.(models.UserRole)
+	return currentRole.AtLeast(role)
+}
+
+// impersonator returns the admin acting as the authenticated user when the
+// request carries an impersonation token
+func impersonator(c *gin.Context) (uuid.UUID, bool) {
+	value, exists := c.Get(contextImpersonatorKey)
+	if !exists {
+		return uuid.Nil, false
+	}
+	id, ok := value.(uuid.UUID)
+	return id, ok
+}
+
+// IsShadowed reports whether the authenticated user is shadow banned.
+// Handlers that publish what a user does should hide it from others when
+// this is true, without telling the user.
+func IsShadowed(c *gin.Context) bool {
+	return c.GetBool(contextShadowedKey)
+}
+
+// currentUserID returns the authenticated user ID from the request context
+func currentUserID(c *gin.Context) (uuid.UUID, bool) {
+	value, exists := c.Get(contextUserIDKey)
+	if !exists {
+		return uuid.Nil, false
+	}
+	id, ok := value.(uuid.UUID)
+	return id, ok
+}
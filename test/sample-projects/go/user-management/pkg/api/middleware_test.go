@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/services"
+	"github.com/example/user-management/internal/utils"
+)
+
+func TestImpersonationAuditMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		breakAudit bool
+		wantStatus int
+		wantName   string
+	}{
+		{"audited before the handler", false, http.StatusOK, "Renamed"},
+		{"audit failure stops the request", true, http.StatusInternalServerError, "Test User"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t)
+			admin := srv.createUser(t, "admin", "admin-password", models.RoleAdmin)
+			alice := srv.createUser(t, "alice", "alice-password", models.RoleUser)
+
+			token, _, err := srv.jwtManager.GenerateImpersonationToken(alice, admin.ID)
+			if err != nil {
+				t.Fatalf("generate impersonation token: %v", err)
+			}
+			if tt.breakAudit {
+				if err := srv.db.Migrator().DropTable(&utils.AuditLog{}); err != nil {
+					t.Fatalf("drop audit log: %v", err)
+				}
+			}
+
+			w := srv.do(t, http.MethodPut, "/api/v1/users/"+alice.ID.String(), token, map[string]string{"name": "Renamed"})
+			checkStatus(t, w, tt.wantStatus)
+
+			var user models.User
+			if err := srv.db.First(&user, "id = ?", alice.ID).Error; err != nil {
+				t.Fatalf("load alice: %v", err)
+			}
+			if user.Name != tt.wantName {
+				t.Errorf("name = %q, want %q", user.Name, tt.wantName)
+			}
+
+			if tt.breakAudit {
+				return
+			}
+			var audited int64
+			srv.db.Model(&utils.AuditLog{}).
+				Where("user_id = ? AND action = ? AND resource = ?", admin.ID, services.AuditActionImpersonationRequest, "users/"+alice.ID.String()).
+				Count(&audited)
+			if audited != 1 {
+				t.Errorf("audited requests = %d, want 1", audited)
+			}
+		})
+	}
+}
+
+func TestImpersonationAuditSkipsOwnTokens(t *testing.T) {
+	srv := newTestServer(t)
+	alice := srv.createUser(t, "alice", "alice-password", models.RoleUser)
+
+	// Without impersonation the audit log isn't needed at all
+	if err := srv.db.Migrator().DropTable(&utils.AuditLog{}); err != nil {
+		t.Fatalf("drop audit log: %v", err)
+	}
+
+	w := srv.do(t, http.MethodGet, "/api/v1/auth/me", srv.token(t, alice), nil)
+	checkStatus(t, w, http.StatusOK)
+}
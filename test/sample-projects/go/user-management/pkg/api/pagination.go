@@ -0,0 +1,32 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/example/user-management/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// parsePagination reads the page and page_size query parameters. Missing
+// parameters fall back to the defaults and oversized pages are capped;
+// non-numeric or non-positive values are reported as field errors.
+func parsePagination(c *gin.Context) (page, pageSize int, err error) {
+	q := newQueryParser(c)
+	page = q.Int("page", 1, 1)
+	pageSize = q.Int("page_size", utils.DefaultPageSize, 1)
+	if err := q.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	page, pageSize = utils.NormalizePagination(page, pageSize)
+	return page, pageSize, nil
+}
+
+// setPaginationHeaders mirrors the pagination fields of a list response in
+// headers so clients can read counts without parsing the body
+func setPaginationHeaders(c *gin.Context, resp *utils.PaginatedResponse) {
+	c.Header("X-Total-Count", strconv.FormatInt(resp.Total, 10))
+	c.Header("X-Page", strconv.Itoa(resp.Page))
+	c.Header("X-Page-Size", strconv.Itoa(resp.PageSize))
+	c.Header("X-Total-Pages", strconv.Itoa(resp.TotalPages))
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/example/user-management/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// queryParser reads typed query parameters and collects a field-level
+// error for each one that is present but malformed. Missing or empty
+// parameters take their default without an error.
+type queryParser struct {
+	c    *gin.Context
+	errs *utils.ValidationErrors
+}
+
+func newQueryParser(c *gin.Context) *queryParser {
+	return &queryParser{c: c, errs: utils.NewValidationErrors()}
+}
+
+// Int reads an integer parameter that must be at least min
+func (p *queryParser) Int(name string, def, min int) int {
+	v := p.c.Query(name)
+	if v == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		p.errs.Add(name, fmt.Sprintf("%s must be an integer", name))
+		return def
+	}
+	if n < min {
+		p.errs.Add(name, fmt.Sprintf("%s must be at least %d", name, min))
+		return def
+	}
+	return n
+}
+
+// Bool reads a boolean parameter such as true, false, 1 or 0
+func (p *queryParser) Bool(name string, def bool) bool {
+	v := p.c.Query(name)
+	if v == "" {
+		return def
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		p.errs.Add(name, fmt.Sprintf("%s must be true or false", name))
+		return def
+	}
+	return b
+}
+
+// Time reads an RFC 3339 timestamp parameter, returning the zero time when
+// it is missing
+func (p *queryParser) Time(name string) time.Time {
+	v := p.c.Query(name)
+	if v == "" {
+		return time.Time{}
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		p.errs.Add(name, fmt.Sprintf("%s must be an RFC 3339 timestamp", name))
+		return time.Time{}
+	}
+	return t
+}
+
+// Err returns the collected errors, or nil if every parameter was valid
+func (p *queryParser) Err() error {
+	if p.errs.HasErrors() {
+		return p.errs
+	}
+	return nil
+}
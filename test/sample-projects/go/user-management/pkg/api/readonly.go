@@ -0,0 +1,112 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/example/user-management/internal/services"
+	"github.com/example/user-management/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ReadOnlyMode is a runtime switch that rejects writes during maintenance
+// while reads keep working. Login can optionally be blocked as well.
+type ReadOnlyMode struct {
+	userService *services.UserService
+
+	enabled    atomic.Bool
+	blockLogin atomic.Bool
+
+	// safe routes are allowed even in read-only mode; login routes are
+	// allowed unless blockLogin is set
+	safe  map[string]bool
+	login map[string]bool
+}
+
+// NewReadOnlyMode creates the switch in the given state. safe lists the
+// route paths that never write, or must stay reachable, such as the
+// endpoint that turns read-only mode off; login lists the authentication
+// routes governed by the block-login sub-flag. Changes made at runtime are
+// audited through userService.
+func NewReadOnlyMode(userService *services.UserService, enabled, blockLogin bool, safe, login []string) *ReadOnlyMode {
+	m := &ReadOnlyMode{userService: userService, safe: routeSet(safe), login: routeSet(login)}
+	m.enabled.Store(enabled)
+	m.blockLogin.Store(blockLogin)
+	return m
+}
+
+func routeSet(paths []string) map[string]bool {
+	set := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		set[path] = true
+	}
+	return set
+}
+
+// Middleware rejects mutating requests with 503 Service Unavailable while
+// read-only mode is on. GET, HEAD and OPTIONS requests always pass.
+func (m *ReadOnlyMode) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !m.enabled.Load() || m.allowed(c) {
+			c.Next()
+			return
+		}
+
+		c.Header("Retry-After", "60")
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable,
+			utils.NewErrorResponse("Service is in read-only mode", errors.New("writes are temporarily disabled for maintenance")))
+	}
+}
+
+func (m *ReadOnlyMode) allowed(c *gin.Context) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	path := c.FullPath()
+	if m.safe[path] {
+		return true
+	}
+	return m.login[path] && !m.blockLogin.Load()
+}
+
+// readOnlyStatus is the body of the read-only endpoints
+type readOnlyStatus struct {
+	Enabled    *bool `json:"enabled" binding:"required"`
+	BlockLogin bool  `json:"block_login"`
+}
+
+func (m *ReadOnlyMode) status() readOnlyStatus {
+	enabled := m.enabled.Load()
+	return readOnlyStatus{Enabled: &enabled, BlockLogin: m.blockLogin.Load()}
+}
+
+// Status handles reporting whether read-only mode is on (admin only)
+func (m *ReadOnlyMode) Status(c *gin.Context) {
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Read-only mode retrieved successfully", m.status()))
+}
+
+// Update handles turning read-only mode on or off at runtime (admin only)
+func (m *ReadOnlyMode) Update(c *gin.Context) {
+	var req readOnlyStatus
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationErrorResponse("Invalid request", err))
+		return
+	}
+
+	m.blockLogin.Store(req.BlockLogin)
+	m.enabled.Store(*req.Enabled)
+
+	actorID, _ := currentUserID(c)
+	logger.Info("read-only mode updated", "enabled", *req.Enabled, "block_login", req.BlockLogin, "actor_id", actorID.String())
+
+	// The switch has to work while the database is in trouble, so a failed
+	// audit write is logged rather than undoing the change
+	if err := m.userService.RecordReadOnlyChange(c.Request.Context(), actorID, *req.Enabled, req.BlockLogin); err != nil {
+		logger.Warn("failed to audit read-only mode change", "actor_id", actorID.String(), "error", err)
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Read-only mode updated successfully", m.status()))
+}
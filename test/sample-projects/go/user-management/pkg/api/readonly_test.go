@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/services"
+	"github.com/example/user-management/internal/utils"
+)
+
+func TestReadOnlyUpdateIsAudited(t *testing.T) {
+	srv := newTestServer(t)
+	admin := srv.createUser(t, "admin", "admin-password", models.RoleAdmin)
+
+	w := srv.do(t, http.MethodPut, "/api/v1/admin/read-only", srv.token(t, admin), map[string]bool{
+		"enabled":     true,
+		"block_login": true,
+	})
+	checkStatus(t, w, http.StatusOK)
+
+	var entry utils.AuditLog
+	if err := srv.db.First(&entry, "action = ?", services.AuditActionReadOnlySet).Error; err != nil {
+		t.Fatalf("load audit entry: %v", err)
+	}
+	if entry.UserID != admin.ID {
+		t.Errorf("actor = %s, want %s", entry.UserID, admin.ID)
+	}
+	if entry.Details["enabled"] != true || entry.Details["block_login"] != true {
+		t.Errorf("details = %v, want enabled and block_login true", entry.Details)
+	}
+}
@@ -1,9 +1,15 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/example/user-management/internal/auth"
 	"github.com/example/user-management/internal/models"
 	"github.com/example/user-management/internal/services"
 	"github.com/example/user-management/internal/utils"
@@ -13,80 +19,1250 @@ import (
 
 // UserHandler handles user-related HTTP requests
 type UserHandler struct {
-	userService *services.UserService
+	userService  *services.UserService
+	tokenService *services.TokenService
+	jwtManager   *auth.JWTManager
 }
 
 // NewUserHandler creates a new user handler
-func NewUserHandler(userService *services.UserService) *UserHandler {
+func NewUserHandler(userService *services.UserService, tokenService *services.TokenService, jwtManager *auth.JWTManager) *UserHandler {
 	return &UserHandler{
-		userService: userService,
+		userService:  userService,
+		tokenService: tokenService,
+		jwtManager:   jwtManager,
 	}
 }
 
-// CreateUser handles user creation
+// CreateUser handles user creation. Duplicate usernames or emails are
+// reported as 409 Conflict, invalid input as 400.
 func (h *UserHandler) CreateUser(c *gin.Context) {
 	var req models.UserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationErrorResponse("Invalid request", err))
+		return
+	}
+
+	// Retries carrying the same Idempotency-Key return the original user
+	if key := c.GetHeader("Idempotency-Key"); key != "" {
+		if len(key) > 255 {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid Idempotency-Key", errors.New("key must be at most 255 characters")))
+			return
+		}
+
+		user, replayed, err := h.userService.CreateUserIdempotent(c.Request.Context(), key, &req)
+		if err != nil {
+			c.JSON(statusForError(err), validationErrorResponse("Failed to create user", err))
+			return
+		}
+
+		if replayed {
+			c.Header("Idempotent-Replayed", "true")
+		}
+
+		respond(c, http.StatusCreated, utils.NewSuccessResponse("User created successfully", user.ToResponse()))
+		return
+	}
+
+	user, err := h.userService.CreateUser(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(statusForError(err), validationErrorResponse("Failed to create user", err))
+		return
+	}
+
+	respond(c, http.StatusCreated, utils.NewSuccessResponse("User created successfully", user.ToResponse()))
+}
+
+// CreateUserAsAdmin handles privileged user creation by an admin, who may
+// set the status, permissions and email verification of the new user
+func (h *UserHandler) CreateUserAsAdmin(c *gin.Context) {
+	var req models.AdminUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationErrorResponse("Invalid request", err))
+		return
+	}
+
+	actorID, _ := currentUserID(c)
+
+	user, err := h.userService.CreateUserAsAdmin(c.Request.Context(), actorID, &req)
+	if err != nil {
+		c.JSON(statusForError(err), validationErrorResponse("Failed to create user", err))
+		return
+	}
+
+	respond(c, http.StatusCreated, utils.NewSuccessResponse("User created successfully", user.ToResponse()))
+}
+
+// ImportUsers handles bulk user creation from a JSON array. The import is
+// all-or-nothing; with ?dry_run=true it is validated and rolled back so the
+// report previews the outcome without persisting anything.
+func (h *UserHandler) ImportUsers(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid dry_run parameter", err))
+		return
+	}
+
+	var reqs []*models.UserRequest
+	if err := c.ShouldBindJSON(&reqs); err != nil {
+		c.JSON(http.StatusBadRequest, validationErrorResponse("Invalid request", err))
+		return
+	}
+	if len(reqs) == 0 {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request", errors.New("at least one user is required")))
+		return
+	}
+
+	report, err := h.userService.ImportUsers(c.Request.Context(), reqs, dryRun)
+	if err != nil {
+		resp := utils.NewErrorResponse("Failed to import users", err)
+		resp.Data = report
+		c.JSON(statusForError(err), resp)
+		return
+	}
+
+	if dryRun {
+		respond(c, http.StatusOK, utils.NewSuccessResponse("Dry run completed, nothing was imported", report))
+		return
+	}
+
+	respond(c, http.StatusCreated, utils.NewSuccessResponse("Users imported successfully", report))
+}
+
+// GetUser handles getting a single user. Responses carry an ETag and
+// If-None-Match is answered with 304 when the user is unchanged.
+func (h *UserHandler) GetUser(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid user ID", err))
+		return
+	}
+
+	user, err := h.userService.GetUserByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to get user", err))
+		return
+	}
+
+	respondWithETag(c, utils.NewSuccessResponse("User retrieved successfully", user.ToResponse()))
+}
+
+// BatchGetUsers handles fetching several users by id at once. The response
+// maps each found id to its user; unknown ids are left out.
+func (h *UserHandler) BatchGetUsers(c *gin.Context) {
+	var req struct {
+		IDs []uuid.UUID `json:"ids" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request", err))
+		return
+	}
+
+	users, err := h.userService.GetUsersByIDs(c.Request.Context(), req.IDs)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to get users", err))
+		return
+	}
+
+	responses := make(map[uuid.UUID]*models.UserResponse, len(users))
+	for id, user := range users {
+		responses[id] = user.ToResponse()
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Users retrieved successfully", responses))
+}
+
+// GetUserAsAdmin handles getting a single user for an admin, including
+// deleted users, which are flagged as such in the response
+func (h *UserHandler) GetUserAsAdmin(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid user ID", err))
+		return
+	}
+
+	user, err := h.userService.GetUserByIDUnscoped(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to get user", err))
+		return
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("User retrieved successfully", user.ToAdminResponse()))
+}
+
+// GetUserByEmail handles looking up a user by email for an admin. The
+// lookup is case-insensitive and also matches verified secondary addresses.
+func (h *UserHandler) GetUserByEmail(c *gin.Context) {
+	email := models.NormalizeEmail(c.Query("email"))
+	if err := utils.ValidateEmail(email); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid email", err))
+		return
+	}
+
+	user, err := h.userService.GetUserByEmail(c.Request.Context(), email)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to get user", err))
+		return
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("User retrieved successfully", user.ToResponse()))
+}
+
+// GetUsers handles getting users with pagination. Responses carry
+// Last-Modified, the latest change to any user, and honor If-Modified-Since.
+func (h *UserHandler) GetUsers(c *gin.Context) {
+	page, pageSize, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, validationErrorResponse("Invalid query parameters", err))
+		return
+	}
+	view, err := parseView(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid view", err))
+		return
+	}
+
+	filter, err := parseUserFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, validationErrorResponse("Invalid filter", err))
+		return
+	}
+
+	lastModified, err := h.userService.UsersLastModified(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get users", err))
+		return
+	}
+	if notModified(c, lastModified) {
+		return
+	}
+
+	users, total, err := h.userService.FilterUsers(c.Request.Context(), filter, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get users", err))
+		return
+	}
+
+	paginatedResponse := utils.NewPaginatedResponse(userList(users, view), page, pageSize, total)
+	setPaginationHeaders(c, paginatedResponse)
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Users retrieved successfully", paginatedResponse))
+}
+
+// parseUserFilter reads list filters from the query string. Timestamps use
+// RFC 3339. Every malformed parameter is reported as a field error.
+func parseUserFilter(c *gin.Context) (*utils.FilterParams, error) {
+	q := newQueryParser(c)
+	filter := &utils.FilterParams{
+		Role:            c.Query("role"),
+		Status:          c.Query("status"),
+		AgeMin:          q.Int("age_min", 0, 0),
+		AgeMax:          q.Int("age_max", 0, 0),
+		CreatedBefore:   q.Time("created_before"),
+		CreatedAfter:    q.Time("created_after"),
+		LastLoginBefore: q.Time("last_login_before"),
+		NeverLoggedIn:   q.Bool("never_logged_in", false),
+	}
+
+	if err := q.Err(); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// UpdateUser handles user updates (self or admin)
+func (h *UserHandler) UpdateUser(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid user ID", err))
+		return
+	}
+
+	actorID, _ := currentUserID(c)
+	if actorID != id && !isAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.NewErrorResponse("Access denied", errors.New("cannot update another user")))
+		return
+	}
+
+	var update models.UserUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request", err))
+		return
+	}
+
+	user, err := h.userService.UpdateUser(c.Request.Context(), id, actorID, update)
+	if err != nil {
+		c.JSON(statusForError(err), validationErrorResponse("Failed to update user", err))
+		return
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("User updated successfully", user.ToResponse()))
+}
+
+// UpdateMetadata handles merging and removing individual metadata keys
+// (self or admin)
+func (h *UserHandler) UpdateMetadata(c *gin.Context) {
+	id, ok := metadataOwner(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Set    map[string]interface{} `json:"set"`
+		Remove []string               `json:"remove"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request", err))
+		return
+	}
+
+	if len(req.Set) == 0 && len(req.Remove) == 0 {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Set or remove is required", nil))
+		return
+	}
+
+	user, err := h.userService.UpdateMetadata(c.Request.Context(), id, req.Set, req.Remove)
+	if err != nil {
+		c.JSON(statusForError(err), validationErrorResponse("Failed to update metadata", err))
+		return
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Metadata updated successfully", user.ToResponse()))
+}
+
+// GetPreferences handles getting a user's preferences, with defaults for
+// the ones they haven't set (self or admin)
+func (h *UserHandler) GetPreferences(c *gin.Context) {
+	id, ok := preferencesOwner(c)
+	if !ok {
+		return
+	}
+
+	preferences, err := h.userService.GetPreferences(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to get preferences", err))
+		return
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Preferences retrieved successfully", preferences))
+}
+
+// UpdatePreferences handles a partial update of a user's preferences; only
+// the fields in the body change (self or admin)
+func (h *UserHandler) UpdatePreferences(c *gin.Context) {
+	id, ok := preferencesOwner(c)
+	if !ok {
+		return
+	}
+
+	var req models.UserPreferences
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request", err))
+		return
+	}
+
+	preferences, err := h.userService.UpdatePreferences(c.Request.Context(), id, req)
+	if err != nil {
+		c.JSON(statusForError(err), validationErrorResponse("Failed to update preferences", err))
+		return
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Preferences updated successfully", preferences))
+}
+
+// metadataOwner parses the user id of a metadata route and checks that the
+// caller is that user or an admin. It writes the error response and
+// returns false otherwise.
+func metadataOwner(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid user ID", err))
+		return uuid.Nil, false
+	}
+
+	if userID, _ := currentUserID(c); userID != id && !isAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.NewErrorResponse("Access denied", errors.New("cannot manage another user's metadata")))
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// preferencesOwner parses the user id of a preferences route and checks
+// that the caller is that user or an admin. It writes the error response
+// and returns false otherwise.
+func preferencesOwner(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid user ID", err))
+		return uuid.Nil, false
+	}
+
+	if userID, _ := currentUserID(c); userID != id && !isAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.NewErrorResponse("Access denied", errors.New("cannot manage another user's preferences")))
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// DeleteUser handles user deletion. Users may delete themselves and admins
+// anyone. Whether users are soft- or hard-deleted follows the service
+// configuration; admins may override it with ?hard=.
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid user ID", err))
+		return
+	}
+
+	if userID, _ := currentUserID(c); userID != id && !isAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.NewErrorResponse("Access denied", errors.New("cannot delete another user")))
+		return
+	}
+
+	// The body is optional; it may carry the reason for the deletion
+	var req struct {
+		Reason string `json:"reason" binding:"max=500"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, validationErrorResponse("Invalid request", err))
+			return
+		}
+	}
+
+	hard := h.userService.HardDeleteByDefault()
+	if v := c.Query("hard"); v != "" {
+		if !isAdmin(c) {
+			c.JSON(http.StatusForbidden, utils.NewErrorResponse("Admin access required", errors.New("only admins may choose the deletion mode")))
+			return
+		}
+		if hard, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid hard parameter", err))
+			return
+		}
+	}
+
+	if hard {
+		actorID, _ := currentUserID(c)
+		if err := h.userService.HardDeleteUser(c.Request.Context(), id, actorID, req.Reason); err != nil {
+			c.JSON(statusForError(err), utils.NewErrorResponse("Failed to delete user", err))
+			return
+		}
+		respond(c, http.StatusOK, utils.NewSuccessResponse("User permanently deleted", nil))
+		return
+	}
+
+	if err := h.userService.DeleteUser(c.Request.Context(), id, req.Reason); err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to delete user", err))
+		return
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("User deleted successfully", nil))
+}
+
+// SearchUsers handles user search. Like GetUsers it sets Last-Modified to
+// the latest change to any user, not just the matches, and supports
+// If-Modified-Since.
+func (h *UserHandler) SearchUsers(c *gin.Context) {
+	query := c.Query("q")
+	page, pageSize, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, validationErrorResponse("Invalid query parameters", err))
+		return
+	}
+	view, err := parseView(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid view", err))
+		return
+	}
+
+	var fields []string
+	if fieldsParam := c.Query("fields"); fieldsParam != "" {
+		for _, field := range strings.Split(fieldsParam, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	lastModified, err := h.userService.SearchUsersLastModified(c.Request.Context(), query, fields)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to search users", err))
+		return
+	}
+	if notModified(c, lastModified) {
+		return
+	}
+
+	params := &utils.SearchParams{
+		Query:    query,
+		Page:     page,
+		PageSize: pageSize,
+		SortBy:   c.Query("sort_by"),
+		SortDir:  c.Query("sort_dir"),
+	}
+	users, total, err := h.userService.SearchUsers(c.Request.Context(), params, fields)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to search users", err))
+		return
+	}
+
+	paginatedResponse := utils.NewPaginatedResponse(userList(users, view), page, pageSize, total)
+	setPaginationHeaders(c, paginatedResponse)
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Search completed successfully", paginatedResponse))
+}
+
+// SearchByMetadata handles listing users by a metadata key/value pair
+func (h *UserHandler) SearchByMetadata(c *gin.Context) {
+	key := c.Query("key")
+	if key == "" {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Key parameter is required", nil))
+		return
+	}
+	page, pageSize, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, validationErrorResponse("Invalid query parameters", err))
+		return
+	}
+	view, err := parseView(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid view", err))
+		return
+	}
+
+	users, total, err := h.userService.SearchByMetadata(c.Request.Context(), key, c.Query("value"), page, pageSize)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to search users", err))
+		return
+	}
+
+	paginatedResponse := utils.NewPaginatedResponse(userList(users, view), page, pageSize, total)
+	setPaginationHeaders(c, paginatedResponse)
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Search completed successfully", paginatedResponse))
+}
+
+// GetUsersByRole handles listing the users with a role, paginated (admin
+// only)
+func (h *UserHandler) GetUsersByRole(c *gin.Context) {
+	page, pageSize, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, validationErrorResponse("Invalid query parameters", err))
+		return
+	}
+	view, err := parseView(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid view", err))
+		return
+	}
+
+	users, total, err := h.userService.GetUsersByRole(c.Request.Context(), models.UserRole(c.Param("role")), page, pageSize)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to get users", err))
+		return
+	}
+
+	paginatedResponse := utils.NewPaginatedResponse(userList(users, view), page, pageSize, total)
+	setPaginationHeaders(c, paginatedResponse)
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Users retrieved successfully", paginatedResponse))
+}
+
+// CheckAvailability handles checking whether a username and/or email is free
+func (h *UserHandler) CheckAvailability(c *gin.Context) {
+	username := c.Query("username")
+	email := c.Query("email")
+
+	if username == "" && email == "" {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Username or email parameter is required", nil))
+		return
+	}
+
+	response := map[string]interface{}{}
+
+	if username != "" {
+		available, err := h.userService.IsUsernameAvailable(c.Request.Context(), username)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to check availability", err))
+			return
+		}
+		response["username_available"] = available
+	}
+
+	if email != "" {
+		available, err := h.userService.IsEmailAvailable(c.Request.Context(), email)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to check availability", err))
+			return
+		}
+		response["email_available"] = available
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Availability checked successfully", response))
+}
+
+// GetUserStats handles getting user statistics. They may come from the
+// stats cache unless the fresh query parameter is true.
+func (h *UserHandler) GetUserStats(c *gin.Context) {
+	q := newQueryParser(c)
+	fresh := q.Bool("fresh", false)
+	if err := q.Err(); err != nil {
+		c.JSON(http.StatusBadRequest, validationErrorResponse("Invalid query parameters", err))
+		return
+	}
+
+	getStats := h.userService.GetUserStats
+	if fresh {
+		getStats = h.userService.GetUserStatsFresh
+	}
+	stats, err := getStats(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get user statistics", err))
+		return
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Statistics retrieved successfully", stats))
+}
+
+// AgeDistribution handles counting users per age range. The buckets query
+// parameter lists the ascending range boundaries, e.g. buckets=18,25,35,50.
+func (h *UserHandler) AgeDistribution(c *gin.Context) {
+	var buckets []int
+	for _, value := range strings.Split(c.Query("buckets"), ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		boundary, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, validationErrorResponse("Invalid query parameters", fmt.Errorf("invalid age bucket boundary: %s", value)))
+			return
+		}
+		buckets = append(buckets, boundary)
+	}
+
+	distribution, err := h.userService.AgeDistribution(c.Request.Context(), buckets)
+	if err != nil {
+		c.JSON(statusForError(err), validationErrorResponse("Failed to get age distribution", err))
+		return
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Age distribution retrieved successfully", distribution))
+}
+
+// SignupsOverTime handles counting signups per period. The from and to
+// query parameters are RFC 3339 timestamps defaulting to the last 30 days,
+// and granularity is day (the default), week or month.
+func (h *UserHandler) SignupsOverTime(c *gin.Context) {
+	q := newQueryParser(c)
+	from, to := q.Time("from"), q.Time("to")
+	if err := q.Err(); err != nil {
+		c.JSON(http.StatusBadRequest, validationErrorResponse("Invalid query parameters", err))
+		return
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -30)
+	}
+
+	granularity := c.DefaultQuery("granularity", utils.GranularityDay)
+	series, err := h.userService.SignupsOverTime(c.Request.Context(), from, to, granularity)
+	if err != nil {
+		c.JSON(statusForError(err), validationErrorResponse("Failed to get signups", err))
+		return
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Signups retrieved successfully", series))
+}
+
+// DeletedSummary handles reporting how many soft-deleted users await the
+// purge job and how long the oldest has been waiting (admin only)
+func (h *UserHandler) DeletedSummary(c *gin.Context) {
+	count, oldest, err := h.userService.DeletedSummary(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to summarize deleted users", err))
+		return
+	}
+
+	summary := map[string]interface{}{
+		"count":             count,
+		"oldest_deleted_at": oldest,
+		"oldest_age_days":   nil,
+	}
+	if oldest != nil {
+		summary["oldest_age_days"] = int(time.Since(*oldest).Hours() / 24)
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Deleted user summary retrieved successfully", summary))
+}
+
+// Export formats selected with the format query parameter
+const (
+	exportFormatJSON = "json"
+	exportFormatXLSX = "xlsx"
+
+	xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// ExportUsers handles user export. The format query parameter selects json
+// (the default) or xlsx; redact lists fields to leave out of either.
+func (h *UserHandler) ExportUsers(c *gin.Context) {
+	var redact []string
+	if redactParam := c.Query("redact"); redactParam != "" {
+		for _, field := range strings.Split(redactParam, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				redact = append(redact, field)
+			}
+		}
+	}
+
+	var (
+		data        []byte
+		err         error
+		contentType string
+		filename    string
+	)
+	switch format := c.DefaultQuery("format", exportFormatJSON); format {
+	case exportFormatJSON:
+		data, err = h.userService.ExportUsers(c.Request.Context(), redact)
+		contentType, filename = "application/json", "users.json"
+	case exportFormatXLSX:
+		h.exportUsersXLSX(c, redact)
+		return
+	default:
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid export format",
+			fmt.Errorf("invalid format %q, must be %s or %s", format, exportFormatJSON, exportFormatXLSX)))
+		return
+	}
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to export users", err))
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// exportUsersXLSX streams the XLSX export into the response. Failures
+// before the workbook is written still get an error response; a failure
+// while writing it can only cut the download short, and is logged.
+func (h *UserHandler) exportUsersXLSX(c *gin.Context, redact []string) {
+	c.Header("Content-Type", xlsxContentType)
+	c.Header("Content-Disposition", "attachment; filename=users.xlsx")
+
+	err := h.userService.ExportUsersXLSX(c.Request.Context(), c.Writer, redact)
+	if err == nil {
+		return
+	}
+	if c.Writer.Written() {
+		c.Error(err)
+		c.Abort()
+		return
+	}
+
+	c.Writer.Header().Del("Content-Type")
+	c.Writer.Header().Del("Content-Disposition")
+	c.JSON(statusForError(err), utils.NewErrorResponse("Failed to export users", err))
+}
+
+// ExportUserData handles exporting all data held about a single user.
+// Users may export their own data; admins may export anyone's.
+func (h *UserHandler) ExportUserData(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid user ID", err))
+		return
+	}
+
+	if userID, _ := currentUserID(c); userID != id && !isAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.NewErrorResponse("Access denied", errors.New("cannot export another user's data")))
+		return
+	}
+
+	data, err := h.userService.ExportUserData(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to export user data", err))
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=user-"+id.String()+".json")
+	c.Data(http.StatusOK, "application/json", data)
+}
+
+// LoginHistory handles listing a user's recent login attempts, newest
+// first. Users may see their own history; admins may see anyone's.
+func (h *UserHandler) LoginHistory(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid user ID", err))
+		return
+	}
+
+	if userID, _ := currentUserID(c); userID != id && !isAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.NewErrorResponse("Access denied", errors.New("cannot view another user's login history")))
+		return
+	}
+
+	page, pageSize, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, validationErrorResponse("Invalid query parameters", err))
+		return
+	}
+	events, total, err := h.userService.LoginHistory(c.Request.Context(), id, page, pageSize)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to get login history", err))
+		return
+	}
+
+	paginatedResponse := utils.NewPaginatedResponse(events, page, pageSize, total)
+	setPaginationHeaders(c, paginatedResponse)
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Login history retrieved successfully", paginatedResponse))
+}
+
+// ListEmails handles listing a user's email addresses. Users may list
+// their own; admins may list anyone's.
+func (h *UserHandler) ListEmails(c *gin.Context) {
+	id, ok := emailOwner(c)
+	if !ok {
+		return
+	}
+
+	emails, err := h.userService.ListEmails(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to get emails", err))
+		return
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Emails retrieved successfully", emails))
+}
+
+// AddEmail handles adding an email address to a user. Only admins can add
+// an address as already verified.
+func (h *UserHandler) AddEmail(c *gin.Context) {
+	id, ok := emailOwner(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Email    string `json:"email" binding:"required"`
+		Verified bool   `json:"verified"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationErrorResponse("Invalid request", err))
+		return
+	}
+	if req.Verified && !isAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.NewErrorResponse("Access denied", errors.New("only admins can add verified emails")))
+		return
+	}
+
+	actorID, _ := currentUserID(c)
+	email, err := h.userService.AddEmail(c.Request.Context(), id, actorID, req.Email, req.Verified)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to add email", err))
+		return
+	}
+
+	respond(c, http.StatusCreated, utils.NewSuccessResponse("Email added successfully", email))
+}
+
+// SetPrimaryEmail handles making one of a user's verified addresses primary
+func (h *UserHandler) SetPrimaryEmail(c *gin.Context) {
+	id, ok := emailOwner(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Email string `json:"email" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationErrorResponse("Invalid request", err))
+		return
+	}
+
+	actorID, _ := currentUserID(c)
+	user, err := h.userService.SetPrimaryEmail(c.Request.Context(), id, actorID, req.Email)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to set primary email", err))
+		return
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Primary email updated successfully", user.ToResponse()))
+}
+
+// RemoveEmail handles removing a secondary email address from a user
+func (h *UserHandler) RemoveEmail(c *gin.Context) {
+	id, ok := emailOwner(c)
+	if !ok {
+		return
+	}
+
+	actorID, _ := currentUserID(c)
+	if err := h.userService.RemoveEmail(c.Request.Context(), id, actorID, c.Param("email")); err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to remove email", err))
+		return
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Email removed successfully", nil))
+}
+
+// emailOwner parses the user id of an email route and checks that the
+// caller is that user or an admin. It writes the error response and
+// returns false otherwise.
+func emailOwner(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid user ID", err))
+		return uuid.Nil, false
+	}
+
+	if userID, _ := currentUserID(c); userID != id && !isAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.NewErrorResponse("Access denied", errors.New("cannot manage another user's emails")))
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// Login handles user authentication. The username field also accepts the
+// user's email address; users with backup codes must send one as
+// backup_code.
+func (h *UserHandler) Login(c *gin.Context) {
+	var req struct {
+		Username   string `json:"username" binding:"required"`
+		Password   string `json:"password" binding:"required"`
+		Code       string `json:"code"`
+		BackupCode string `json:"backup_code"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request", err))
+		return
+	}
+
+	ctx := services.WithUserAgent(c.Request.Context(), c.Request.UserAgent())
+	user, err := h.userService.AuthenticateByIdentifier(ctx, req.Username, req.Password, services.SecondFactor{
+		Code:       req.Code,
+		BackupCode: req.BackupCode,
+	}, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("Authentication failed", err))
+		return
+	}
+
+	token, claims, err := h.jwtManager.GenerateToken(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to generate token", err))
+		return
+	}
+
+	refreshToken, refreshClaims, err := h.jwtManager.GenerateRefreshToken(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to generate refresh token", err))
+		return
+	}
+
+	if err := h.tokenService.StoreRefreshToken(c.Request.Context(), refreshClaims.ID, user.ID, refreshClaims.ExpiresAt.Time); err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to start session", err))
+		return
+	}
+
+	response := map[string]interface{}{
+		"user":                 user.ToResponse(),
+		"token":                token,
+		"expires":              claims.ExpiresAt.Time,
+		"refresh_token":        refreshToken,
+		"refresh_expires":      refreshClaims.ExpiresAt.Time,
+		"must_change_password": h.userService.PasswordExpired(user),
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Login successful", response))
+}
+
+// RefreshToken handles issuing a new access token from a refresh token
+func (h *UserHandler) RefreshToken(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request", err))
+		return
+	}
+
+	claims, err := h.jwtManager.ValidateToken(req.RefreshToken, auth.TokenTypeRefresh)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("Invalid refresh token", err))
+		return
+	}
+
+	if _, err := h.tokenService.GetSession(c.Request.Context(), claims.ID); err != nil {
+		message := "Invalid refresh token"
+		if errors.Is(err, services.ErrSessionIdleExpired) || errors.Is(err, services.ErrSessionAbsoluteExpired) {
+			message = "Session expired, please log in again"
+		}
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse(message, err))
+		return
+	}
+
+	user, err := h.userService.GetUserByID(c.Request.Context(), claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("Invalid refresh token", err))
+		return
+	}
+
+	if !user.IsActive() {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("Invalid refresh token", errors.New("user account is not active")))
+		return
+	}
+
+	token, accessClaims, err := h.jwtManager.GenerateToken(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to generate token", err))
+		return
+	}
+
+	if err := h.tokenService.ExtendSession(c.Request.Context(), claims.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to extend session", err))
+		return
+	}
+
+	response := map[string]interface{}{
+		"token":   token,
+		"expires": accessClaims.ExpiresAt.Time,
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Token refreshed successfully", response))
+}
+
+// Logout handles user logout by revoking the supplied refresh token
+func (h *UserHandler) Logout(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request", err))
 		return
 	}
 
-	user, err := h.userService.CreateUser(&req)
+	claims, err := h.jwtManager.ValidateToken(req.RefreshToken, auth.TokenTypeRefresh)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Failed to create user", err))
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("Invalid refresh token", err))
 		return
 	}
 
-	c.JSON(http.StatusCreated, utils.NewSuccessResponse("User created successfully", user.ToResponse()))
+	if err := h.tokenService.RevokeRefreshToken(c.Request.Context(), claims.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to logout", err))
+		return
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Logout successful", nil))
 }
 
-// GetUser handles getting a single user
-func (h *UserHandler) GetUser(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := uuid.Parse(idStr)
+// PasswordStrength handles scoring a candidate password for strength
+// meters. Nothing is stored and the password is never logged.
+func (h *UserHandler) PasswordStrength(c *gin.Context) {
+	var req struct {
+		Password string `json:"password"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request", err))
+		return
+	}
+
+	rules := utils.DefaultPasswordPolicy.Check(req.Password)
+	valid := true
+	for _, rule := range rules {
+		valid = valid && rule.Satisfied
+	}
+
+	response := map[string]interface{}{
+		"score": utils.PasswordStrength(req.Password),
+		"valid": valid,
+		"rules": rules,
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Password strength estimated", response))
+}
+
+// Me handles returning the authenticated user. The user is loaded from the
+// database so role and permission changes show up before the token expires.
+func (h *UserHandler) Me(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("Authentication required", errors.New("missing user identity")))
+		return
+	}
+
+	user, err := h.userService.GetUserByID(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid user ID", err))
+		status := statusForError(err)
+		if errors.Is(err, services.ErrUserNotFound) {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, utils.NewErrorResponse("Failed to get user", err))
+		return
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("User retrieved successfully", user.ToResponse()))
+}
+
+// ChangePassword handles password change for the authenticated user.
+// The target user is always taken from the token, never from the body.
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("Authentication required", errors.New("missing user identity")))
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required,min=8"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request", err))
+		return
+	}
+
+	if err := h.userService.ChangePassword(c.Request.Context(), userID, req.CurrentPassword, req.NewPassword); err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to change password", err))
+		return
+	}
+
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Password changed successfully", nil))
+}
+
+// SetupTwoFactor starts two-factor setup for the current user and returns
+// the authenticator secret to enroll
+func (h *UserHandler) SetupTwoFactor(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("Authentication required", errors.New("missing user identity")))
+		return
+	}
+
+	var req struct {
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request", err))
 		return
 	}
 
-	user, err := h.userService.GetUserByID(id)
+	secret, uri, err := h.userService.SetupTwoFactor(c.Request.Context(), userID, req.Password)
 	if err != nil {
-		c.JSON(http.StatusNotFound, utils.NewErrorResponse("User not found", err))
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to set up two-factor authentication", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, utils.NewSuccessResponse("User retrieved successfully", user.ToResponse()))
+	c.Header("Cache-Control", "no-store")
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Add the secret to your authenticator app, then confirm a code to enable two-factor authentication", map[string]interface{}{
+		"secret": secret,
+		"uri":    uri,
+	}))
 }
 
-// GetUsers handles getting users with pagination
-func (h *UserHandler) GetUsers(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+// EnableTwoFactor confirms two-factor setup with a code from the
+// authenticator app and returns the first backup codes
+func (h *UserHandler) EnableTwoFactor(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("Authentication required", errors.New("missing user identity")))
+		return
+	}
 
-	if page < 1 {
-		page = 1
+	var req struct {
+		Code string `json:"code" binding:"required"`
 	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 20
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request", err))
+		return
 	}
 
-	users, total, err := h.userService.GetAllUsers(page, pageSize)
+	codes, err := h.userService.EnableTwoFactor(c.Request.Context(), userID, req.Code)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get users", err))
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to enable two-factor authentication", err))
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Two-factor authentication enabled; store the backup codes now, they will not be shown again", map[string]interface{}{
+		"backup_codes": codes,
+	}))
+}
+
+// DisableTwoFactor turns two-factor authentication off for the current user
+func (h *UserHandler) DisableTwoFactor(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("Authentication required", errors.New("missing user identity")))
+		return
+	}
+
+	var req struct {
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request", err))
 		return
 	}
 
-	var responses []*models.UserResponse
-	for _, user := range users {
-		responses = append(responses, user.ToResponse())
+	if err := h.userService.DisableTwoFactor(c.Request.Context(), userID, req.Password); err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to disable two-factor authentication", err))
+		return
 	}
 
-	paginatedResponse := utils.NewPaginatedResponse(responses, page, pageSize, total)
-	c.JSON(http.StatusOK, utils.NewSuccessResponse("Users retrieved successfully", paginatedResponse))
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Two-factor authentication disabled", nil))
 }
 
-// UpdateUser handles user updates
-func (h *UserHandler) UpdateUser(c *gin.Context) {
+// RegenerateBackupCodes issues a new set of 2FA backup codes for the
+// current user, replacing any unused ones. The codes are only ever shown in
+// this response.
+func (h *UserHandler) RegenerateBackupCodes(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("Authentication required", errors.New("missing user identity")))
+		return
+	}
+
+	var req struct {
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request", err))
+		return
+	}
+
+	codes, err := h.userService.RegenerateBackupCodes(c.Request.Context(), userID, req.Password)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to generate backup codes", err))
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Backup codes generated; store them now, they will not be shown again", map[string]interface{}{
+		"backup_codes": codes,
+	}))
+}
+
+// ResetPassword handles password reset (admin only)
+func (h *UserHandler) ResetPassword(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
@@ -94,23 +1270,28 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	var updates map[string]interface{}
-	if err := c.ShouldBindJSON(&updates); err != nil {
+	var req struct {
+		NewPassword string `json:"new_password" binding:"required,min=8"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request", err))
 		return
 	}
 
-	user, err := h.userService.UpdateUser(id, updates)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Failed to update user", err))
+	actorID, _ := currentUserID(c)
+
+	if err := h.userService.ResetPassword(c.Request.Context(), id, actorID, req.NewPassword); err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to reset password", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, utils.NewSuccessResponse("User updated successfully", user.ToResponse()))
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Password reset successfully", nil))
 }
 
-// DeleteUser handles user deletion
-func (h *UserHandler) DeleteUser(c *gin.Context) {
+// LogoutAll handles force-logging-out a user by revoking all their sessions
+// and invalidating access tokens already issued
+func (h *UserHandler) LogoutAll(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
@@ -118,123 +1299,207 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	if err := h.userService.DeleteUser(id); err != nil {
-		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to delete user", err))
+	actorID, _ := currentUserID(c)
+
+	if err := h.tokenService.RevokeAllForUser(c.Request.Context(), id, actorID); err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to revoke sessions", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, utils.NewSuccessResponse("User deleted successfully", nil))
+	respond(c, http.StatusOK, utils.NewSuccessResponse("All sessions revoked successfully", nil))
 }
 
-// SearchUsers handles user search
-func (h *UserHandler) SearchUsers(c *gin.Context) {
-	query := c.Query("q")
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+// ActivateUser handles activating a user account (admin only)
+func (h *UserHandler) ActivateUser(c *gin.Context) {
+	h.changeStatus(c, h.userService.ActivateUser, "User activated successfully")
+}
+
+// DeactivateUser handles deactivating a user account (admin only)
+func (h *UserHandler) DeactivateUser(c *gin.Context) {
+	h.changeStatus(c, h.userService.DeactivateUser, "User deactivated successfully")
+}
+
+// SuspendUser handles suspending a user account (admin only)
+func (h *UserHandler) SuspendUser(c *gin.Context) {
+	h.changeStatus(c, h.userService.SuspendUser, "User suspended successfully")
+}
+
+// ShadowUser handles shadow banning a user (admin only)
+func (h *UserHandler) ShadowUser(c *gin.Context) {
+	h.setShadowed(c, true, "User shadow banned successfully")
+}
+
+// UnshadowUser handles lifting a user's shadow ban (admin only)
+func (h *UserHandler) UnshadowUser(c *gin.Context) {
+	h.setShadowed(c, false, "User shadow ban lifted successfully")
+}
 
-	if page < 1 {
-		page = 1
+func (h *UserHandler) setShadowed(c *gin.Context, shadowed bool, message string) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid user ID", err))
+		return
 	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 20
+
+	actorID, _ := currentUserID(c)
+
+	user, err := h.userService.SetShadowed(c.Request.Context(), id, actorID, shadowed)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to update shadow ban", err))
+		return
 	}
 
-	users, total, err := h.userService.SearchUsers(query, page, pageSize)
+	respond(c, http.StatusOK, utils.NewSuccessResponse(message, user.ToAdminResponse()))
+}
+
+// UnlockUser handles clearing a user's lockout without a password reset
+// (admin only)
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+	h.changeStatus(c, h.userService.UnlockUser, "User unlocked successfully")
+}
+
+func (h *UserHandler) changeStatus(c *gin.Context, change func(ctx context.Context, id, actorID uuid.UUID) (*models.User, error), message string) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to search users", err))
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid user ID", err))
 		return
 	}
 
-	var responses []*models.UserResponse
-	for _, user := range users {
-		responses = append(responses, user.ToResponse())
+	actorID, _ := currentUserID(c)
+
+	user, err := change(c.Request.Context(), id, actorID)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to change user status", err))
+		return
 	}
 
-	paginatedResponse := utils.NewPaginatedResponse(responses, page, pageSize, total)
-	c.JSON(http.StatusOK, utils.NewSuccessResponse("Search completed successfully", paginatedResponse))
+	respond(c, http.StatusOK, utils.NewSuccessResponse(message, user.ToResponse()))
 }
 
-// GetUserStats handles getting user statistics
-func (h *UserHandler) GetUserStats(c *gin.Context) {
-	stats, err := h.userService.GetUserStats()
+// Impersonate handles issuing a token that lets the calling admin act as
+// another user (admin only). The token is short-lived and can't be
+// refreshed; its issuance and every request made with it are audited.
+func (h *UserHandler) Impersonate(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get user statistics", err))
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid user ID", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, utils.NewSuccessResponse("Statistics retrieved successfully", stats))
-}
+	adminID, _ := currentUserID(c)
+	user, err := h.userService.ImpersonationTarget(c.Request.Context(), id, adminID)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to impersonate user", err))
+		return
+	}
 
-// ExportUsers handles user export
-func (h *UserHandler) ExportUsers(c *gin.Context) {
-	data, err := h.userService.ExportUsers()
+	token, claims, err := h.jwtManager.GenerateImpersonationToken(user, adminID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to export users", err))
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to generate token", err))
 		return
 	}
 
-	c.Header("Content-Type", "application/json")
-	c.Header("Content-Disposition", "attachment; filename=users.json")
-	c.Data(http.StatusOK, "application/json", data)
+	if err := h.userService.RecordImpersonationStart(c.Request.Context(), user.ID, adminID, claims.ID, claims.ExpiresAt.Time); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to impersonate user", err))
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Impersonation token issued", map[string]interface{}{
+		"user":            user.ToResponse(),
+		"token":           token,
+		"expires":         claims.ExpiresAt.Time,
+		"impersonated_by": adminID,
+	}))
 }
 
-// Login handles user authentication
-func (h *UserHandler) Login(c *gin.Context) {
+// RestoreUser handles bringing a deleted user back (admin only). It fails
+// with 409 if the user's username or email has been taken since.
+func (h *UserHandler) RestoreUser(c *gin.Context) {
+	h.changeStatus(c, h.userService.RestoreUser, "User restored successfully")
+}
+
+// UpdateStatus handles moving a user to the status given in the body.
+// Transitions the status state machine forbids are rejected with 400.
+func (h *UserHandler) UpdateStatus(c *gin.Context) {
 	var req struct {
-		Username string `json:"username" binding:"required"`
-		Password string `json:"password" binding:"required"`
+		Status models.UserStatus `json:"status" binding:"required,oneof=active inactive suspended"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request", err))
+		c.JSON(http.StatusBadRequest, validationErrorResponse("Invalid request", err))
 		return
 	}
 
-	user, err := h.userService.AuthenticateUser(req.Username, req.Password)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("Authentication failed", err))
-		return
+	h.changeStatus(c, func(ctx context.Context, id, actorID uuid.UUID) (*models.User, error) {
+		return h.userService.UpdateStatus(ctx, id, actorID, req.Status)
+	}, "User status updated successfully")
+}
+
+// BulkUpdateStatus handles moving many users to one status at once (admin
+// only)
+func (h *UserHandler) BulkUpdateStatus(c *gin.Context) {
+	var req struct {
+		IDs    []uuid.UUID       `json:"ids" binding:"required"`
+		Status models.UserStatus `json:"status" binding:"required,oneof=active inactive suspended"`
 	}
 
-	// In a real application, you would generate a JWT token here
-	response := map[string]interface{}{
-		"user":    user.ToResponse(),
-		"token":   "dummy-jwt-token", // This would be a real JWT token
-		"expires": "2024-12-31T23:59:59Z",
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationErrorResponse("Invalid request", err))
+		return
 	}
 
-	c.JSON(http.StatusOK, utils.NewSuccessResponse("Login successful", response))
-}
+	actorID, _ := currentUserID(c)
+	updated, err := h.userService.BulkUpdateStatus(c.Request.Context(), req.IDs, actorID, req.Status)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to update user status", err))
+		return
+	}
 
-// Logout handles user logout
-func (h *UserHandler) Logout(c *gin.Context) {
-	// In a real application, you would invalidate the JWT token here
-	c.JSON(http.StatusOK, utils.NewSuccessResponse("Logout successful", nil))
+	respond(c, http.StatusOK, utils.NewSuccessResponse("User status updated successfully", map[string]interface{}{
+		"updated": updated,
+	}))
 }
 
-// ChangePassword handles password change
-func (h *UserHandler) ChangePassword(c *gin.Context) {
+// GrantPermissionToUsers handles granting a permission to many users at
+// once. Users it can't be granted to are reported with the reason without
+// failing the others (admin only).
+func (h *UserHandler) GrantPermissionToUsers(c *gin.Context) {
 	var req struct {
-		UserID          uuid.UUID `json:"user_id" binding:"required"`
-		CurrentPassword string    `json:"current_password" binding:"required"`
-		NewPassword     string    `json:"new_password" binding:"required,min=8"`
+		IDs        []uuid.UUID `json:"ids" binding:"required"`
+		Permission string      `json:"permission" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request", err))
+		c.JSON(http.StatusBadRequest, validationErrorResponse("Invalid request", err))
 		return
 	}
 
-	if err := h.userService.ChangePassword(req.UserID, req.CurrentPassword, req.NewPassword); err != nil {
-		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Failed to change password", err))
+	actorID, _ := currentUserID(c)
+	results, err := h.userService.GrantPermissionToUsers(c.Request.Context(), req.IDs, actorID, req.Permission)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to grant permission", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, utils.NewSuccessResponse("Password changed successfully", nil))
+	granted := 0
+	for _, result := range results {
+		if result.Success {
+			granted++
+		}
+	}
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Permission granted", map[string]interface{}{
+		"granted": granted,
+		"failed":  len(results) - granted,
+		"results": results,
+	}))
 }
 
-// ResetPassword handles password reset (admin only)
-func (h *UserHandler) ResetPassword(c *gin.Context) {
+// AddPermission handles adding permission to user
+func (h *UserHandler) AddPermission(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
@@ -243,7 +1508,7 @@ func (h *UserHandler) ResetPassword(c *gin.Context) {
 	}
 
 	var req struct {
-		NewPassword string `json:"new_password" binding:"required,min=8"`
+		Permission string `json:"permission" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -251,16 +1516,23 @@ func (h *UserHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	if err := h.userService.ResetPassword(id, req.NewPassword); err != nil {
-		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Failed to reset password", err))
+	if err := h.userService.AddPermission(c.Request.Context(), id, req.Permission); err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to add permission", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, utils.NewSuccessResponse("Password reset successfully", nil))
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Permission added successfully", nil))
 }
 
-// AddPermission handles adding permission to user
-func (h *UserHandler) AddPermission(c *gin.Context) {
+// ListPermissionCatalog handles listing the permissions that may be
+// granted (admin only). An empty list means any permission is accepted.
+func (h *UserHandler) ListPermissionCatalog(c *gin.Context) {
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Permission catalog retrieved successfully", h.userService.PermissionCatalog()))
+}
+
+// ListPermissions handles listing a user's permissions. Users may list
+// their own; admins may list anyone's.
+func (h *UserHandler) ListPermissions(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
@@ -268,21 +1540,77 @@ func (h *UserHandler) AddPermission(c *gin.Context) {
 		return
 	}
 
-	var req struct {
-		Permission string `json:"permission" binding:"required"`
+	if userID, _ := currentUserID(c); userID != id && !isAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.NewErrorResponse("Access denied", errors.New("cannot view another user's permissions")))
+		return
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	user, err := h.userService.GetUserByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to get permissions", err))
+		return
+	}
+
+	permissions := user.Permissions
+	if permissions == nil {
+		permissions = []string{}
+	}
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Permissions retrieved successfully", permissions))
+}
+
+// CheckPermission handles asking whether a user holds a permission.
+// Callers may check themselves; admins may check anyone.
+func (h *UserHandler) CheckPermission(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid user ID", err))
+		return
+	}
+
+	if userID, _ := currentUserID(c); userID != id && !isAdmin(c) {
+		c.JSON(http.StatusForbidden, utils.NewErrorResponse("Access denied", errors.New("cannot check another user's permissions")))
+		return
+	}
+
+	permission := c.Query("permission")
+	if permission == "" {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Permission parameter is required", nil))
+		return
+	}
+
+	allowed, err := h.userService.CheckPermission(c.Request.Context(), id, permission)
+	if err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to check permission", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"allowed": allowed})
+}
+
+// SetPermissions handles replacing all of a user's permissions
+func (h *UserHandler) SetPermissions(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid user ID", err))
+		return
+	}
+
+	var permissions []string
+	if err := c.ShouldBindJSON(&permissions); err != nil {
 		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request", err))
 		return
 	}
 
-	if err := h.userService.AddPermission(id, req.Permission); err != nil {
-		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Failed to add permission", err))
+	actorID, _ := currentUserID(c)
+
+	if err := h.userService.SetPermissions(c.Request.Context(), id, actorID, permissions); err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to set permissions", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, utils.NewSuccessResponse("Permission added successfully", nil))
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Permissions updated successfully", nil))
 }
 
 // RemovePermission handles removing permission from user
@@ -300,10 +1628,10 @@ func (h *UserHandler) RemovePermission(c *gin.Context) {
 		return
 	}
 
-	if err := h.userService.RemovePermission(id, permission); err != nil {
-		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Failed to remove permission", err))
+	if err := h.userService.RemovePermission(c.Request.Context(), id, permission); err != nil {
+		c.JSON(statusForError(err), utils.NewErrorResponse("Failed to remove permission", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, utils.NewSuccessResponse("Permission removed successfully", nil))
+	respond(c, http.StatusOK, utils.NewSuccessResponse("Permission removed successfully", nil))
 }
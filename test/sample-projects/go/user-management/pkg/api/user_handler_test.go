@@ -0,0 +1,211 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/example/user-management/internal/services"
+	"github.com/example/user-management/internal/utils"
+	"github.com/google/uuid"
+)
+
+func TestChangePasswordUsesTokenIdentity(t *testing.T) {
+	tests := []struct {
+		name            string
+		currentPassword string
+		wantStatus      int
+		aliceAfter      string
+	}{
+		{"own current password", "alice-password", http.StatusOK, "new-password-1"},
+		{"target's current password", "bob-password", http.StatusBadRequest, "alice-password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t)
+			alice := srv.createUser(t, "alice", "alice-password", models.RoleUser)
+			bob := srv.createUser(t, "bob", "bob-password", models.RoleUser)
+
+			// Naming bob in the body must not make the change apply to him
+			w := srv.do(t, http.MethodPost, "/api/v1/auth/change-password", srv.token(t, alice), map[string]string{
+				"user_id":          bob.ID.String(),
+				"current_password": tt.currentPassword,
+				"new_password":     "new-password-1",
+			})
+			checkStatus(t, w, tt.wantStatus)
+
+			if !srv.authenticates(t, "bob", "bob-password") {
+				t.Error("bob's password changed")
+			}
+			if !srv.authenticates(t, "alice", tt.aliceAfter) {
+				t.Errorf("alice cannot log in with %q", tt.aliceAfter)
+			}
+		})
+	}
+}
+
+func TestChangePasswordRequiresToken(t *testing.T) {
+	srv := newTestServer(t)
+	srv.createUser(t, "alice", "alice-password", models.RoleUser)
+
+	w := srv.do(t, http.MethodPost, "/api/v1/auth/change-password", "", map[string]string{
+		"current_password": "alice-password",
+		"new_password":     "new-password-1",
+	})
+	checkStatus(t, w, http.StatusUnauthorized)
+
+	if !srv.authenticates(t, "alice", "alice-password") {
+		t.Error("alice's password changed")
+	}
+}
+
+func TestCreateUserStatus(t *testing.T) {
+	srv := newTestServer(t)
+	srv.createUser(t, "alice", "alice-password", models.RoleUser)
+
+	tests := []struct {
+		name       string
+		username   string
+		email      string
+		password   string
+		wantStatus int
+	}{
+		{"new user", "bob", "bob@example.com", "bob-password", http.StatusCreated},
+		{"duplicate username", "alice", "other@example.com", "bob-password", http.StatusConflict},
+		{"duplicate username in other case", "ALICE", "other@example.com", "bob-password", http.StatusConflict},
+		{"duplicate email", "carol", "alice@example.com", "bob-password", http.StatusConflict},
+		{"duplicate email in other case", "carol", "Alice@Example.com", "bob-password", http.StatusConflict},
+		{"username too short", "cj", "cj@example.com", "bob-password", http.StatusBadRequest},
+		{"invalid email", "dave", "not-an-email", "bob-password", http.StatusBadRequest},
+		{"password too short", "erin", "erin@example.com", "short", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := srv.do(t, http.MethodPost, "/api/v1/users", "", map[string]interface{}{
+				"username": tt.username,
+				"email":    tt.email,
+				"name":     "Test User",
+				"age":      30,
+				"password": tt.password,
+			})
+			checkStatus(t, w, tt.wantStatus)
+		})
+	}
+}
+
+func TestLogoutAllRevokesTokens(t *testing.T) {
+	srv := newTestServer(t)
+	admin := srv.createUser(t, "admin", "admin-password", models.RoleAdmin)
+	bob := srv.createUser(t, "bob", "bob-password", models.RoleUser)
+	bobToken := srv.token(t, bob)
+	checkStatus(t, srv.do(t, http.MethodGet, "/api/v1/auth/me", bobToken, nil), http.StatusOK)
+
+	// Only admins may force a logout
+	w := srv.do(t, http.MethodPost, "/api/v1/admin/users/"+bob.ID.String()+"/logout-all", bobToken, nil)
+	checkStatus(t, w, http.StatusForbidden)
+
+	w = srv.do(t, http.MethodPost, "/api/v1/admin/users/"+bob.ID.String()+"/logout-all", srv.token(t, admin), nil)
+	checkStatus(t, w, http.StatusOK)
+
+	checkStatus(t, srv.do(t, http.MethodGet, "/api/v1/auth/me", bobToken, nil), http.StatusUnauthorized)
+
+	// Other users' tokens are unaffected, and bob can log in again
+	checkStatus(t, srv.do(t, http.MethodGet, "/api/v1/auth/me", srv.token(t, admin), nil), http.StatusOK)
+	relogged, err := srv.userService.AuthenticateUser(context.Background(), "bob", "bob-password", services.SecondFactor{}, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("bob cannot log in again: %v", err)
+	}
+	checkStatus(t, srv.do(t, http.MethodGet, "/api/v1/auth/me", srv.token(t, relogged), nil), http.StatusOK)
+
+	var entries []utils.AuditLog
+	if err := srv.db.Where("action = ? AND resource = ?", services.AuditActionLogoutAll, "users/"+bob.ID.String()).
+		Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].UserID != admin.ID {
+		t.Errorf("audit entries = %+v, want one by admin %s", entries, admin.ID)
+	}
+}
+
+func TestLogoutAllUnknownUser(t *testing.T) {
+	srv := newTestServer(t)
+	admin := srv.createUser(t, "admin", "admin-password", models.RoleAdmin)
+
+	w := srv.do(t, http.MethodPost, "/api/v1/admin/users/"+uuid.NewString()+"/logout-all", srv.token(t, admin), nil)
+	checkStatus(t, w, http.StatusNotFound)
+}
+
+func TestUpdateUserIgnoresStatus(t *testing.T) {
+	srv := newTestServer(t)
+	alice := srv.createUser(t, "alice", "alice-password", models.RoleUser)
+	token := srv.token(t, alice)
+
+	for _, status := range []models.UserStatus{models.StatusInactive, models.StatusSuspended, models.StatusDeleted} {
+		w := srv.do(t, http.MethodPut, "/api/v1/users/"+alice.ID.String(), token, map[string]interface{}{
+			"name":   "Alice",
+			"status": status,
+		})
+		checkStatus(t, w, http.StatusOK)
+
+		user, err := srv.userService.GetUserByID(context.Background(), alice.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Status != models.StatusActive || user.Name != "Alice" {
+			t.Errorf("after update with status %s: status = %s, name = %q; want active, \"Alice\"", status, user.Status, user.Name)
+		}
+	}
+}
+
+func TestGetUsersByRole(t *testing.T) {
+	srv := newTestServer(t)
+	admin := srv.createUser(t, "admin", "admin-password", models.RoleAdmin)
+	for _, name := range []string{"alice", "bob", "carol"} {
+		srv.createUser(t, name, name+"-password", models.RoleUser)
+	}
+	srv.createUser(t, "guest", "guest-password", models.RoleGuest)
+	token := srv.token(t, admin)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantTotal  string
+		wantPage   int
+	}{
+		{"first page", "/api/v1/admin/users/by-role/user?page_size=2", http.StatusOK, "3", 2},
+		{"last page", "/api/v1/admin/users/by-role/user?page=2&page_size=2", http.StatusOK, "3", 1},
+		{"other role", "/api/v1/admin/users/by-role/admin", http.StatusOK, "1", 1},
+		{"unknown role", "/api/v1/admin/users/by-role/owner", http.StatusBadRequest, "", 0},
+		{"bad page", "/api/v1/admin/users/by-role/user?page=0", http.StatusBadRequest, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := srv.do(t, http.MethodGet, tt.path, token, nil)
+			checkStatus(t, w, tt.wantStatus)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			if got := w.Header().Get("X-Total-Count"); got != tt.wantTotal {
+				t.Errorf("X-Total-Count = %s, want %s", got, tt.wantTotal)
+			}
+			var body struct {
+				Data struct {
+					Data []models.UserResponse `json:"data"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if len(body.Data.Data) != tt.wantPage {
+				t.Errorf("page has %d users, want %d", len(body.Data.Data), tt.wantPage)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/example/user-management/internal/utils"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// Report binding errors by JSON field name rather than Go field name
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+}
+
+// validationErrorResponse builds an error response whose Data lists the
+// failing fields when err carries per-field details, either from request
+// binding or from model validation
+func validationErrorResponse(message string, err error) *utils.APIResponse {
+	resp := utils.NewErrorResponse(message, err)
+
+	var bindErrs validator.ValidationErrors
+	if errors.As(err, &bindErrs) {
+		details := utils.NewValidationErrors()
+		for _, fe := range bindErrs {
+			details.Add(fe.Field(), validationMessage(fe))
+		}
+		resp.Data = details
+		return resp
+	}
+
+	var details *utils.ValidationErrors
+	if errors.As(err, &details) {
+		resp.Data = details
+	}
+
+	return resp
+}
+
+// validationMessage turns a failed binding constraint into a readable message
+func validationMessage(fe validator.FieldError) string {
+	field := fe.Field()
+	isString := fe.Kind() == reflect.String
+
+	switch fe.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", field)
+	case "min":
+		if isString {
+			return fmt.Sprintf("%s must be at least %s characters", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at least %s", field, fe.Param())
+	case "max":
+		if isString {
+			return fmt.Sprintf("%s must be at most %s characters", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at most %s", field, fe.Param())
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", field)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, strings.Join(strings.Fields(fe.Param()), ", "))
+	default:
+		return fmt.Sprintf("%s is invalid", field)
+	}
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/example/user-management/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// List views selected with the view query parameter
+const (
+	viewSummary = "summary"
+	viewFull    = "full"
+)
+
+// parseView reads the view query parameter of a list request. Lists default
+// to the summary view.
+func parseView(c *gin.Context) (string, error) {
+	switch view := c.DefaultQuery("view", viewSummary); view {
+	case viewSummary, viewFull:
+		return view, nil
+	default:
+		return "", fmt.Errorf("invalid view %q, must be %s or %s", view, viewSummary, viewFull)
+	}
+}
+
+// userList renders users for a list response in the given view
+func userList(users []*models.User, view string) interface{} {
+	if view == viewFull {
+		var responses []*models.UserResponse
+		for _, user := range users {
+			responses = append(responses, user.ToResponse())
+		}
+		return responses
+	}
+
+	var summaries []*models.UserSummary
+	for _, user := range users {
+		summaries = append(summaries, user.ToSummary())
+	}
+	return summaries
+}